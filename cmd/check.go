@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/cli"
+	"github.com/juandiegopalomino/cloudgrep/pkg/policy"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+var runCheckCmd = cli.Check
+
+type checkOptions struct {
+	config        string
+	regions       []string
+	profiles      []string
+	skipRefresh   bool
+	output        string
+	maxViolations int
+	minCoverage   float64
+}
+
+func (cO *checkOptions) run(ctx context.Context, out io.Writer) error {
+	//check the output before the scan
+	if !slices.Contains(policy.Outputs, cO.output) {
+		return fmt.Errorf("unknown output '%v', must be one of %v", cO.output, policy.Outputs)
+	}
+	rO := rootOptions{
+		config:      cO.config,
+		regions:     cO.regions,
+		profiles:    cO.profiles,
+		skipRefresh: cO.skipRefresh,
+	}
+	cfg, err := rO.loadConfig()
+	if err != nil {
+		return err
+	}
+	report, err := runCheckCmd(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	if err := policy.WriteReport(out, cO.output, report); err != nil {
+		return err
+	}
+	//fail if a threshold is crossed
+	if violations := len(report.Violations()); cO.maxViolations >= 0 && violations > cO.maxViolations {
+		return fmt.Errorf("compliance check failed: %v violation(s), the maximum is %v", violations, cO.maxViolations)
+	}
+	if report.Coverage < cO.minCoverage {
+		return fmt.Errorf("compliance check failed: the coverage is %v%%, the minimum is %v%%", report.Coverage, cO.minCoverage)
+	}
+	return nil
+}
+
+// NewCheckCommand returns the check subcommand
+func NewCheckCommand(out io.Writer) *cobra.Command {
+	var cO checkOptions
+	var checkCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Check the tag policies, without starting the web server",
+		Long: `The check command evaluates the tag policies defined in the config file and prints the violations.
+It exits with an error if a threshold is crossed, by default if there is any violation.
+
+The cloud resources are scanned first, unless --skip-refresh is set: the resources already in the datastore are checked.
+The output formats are:
+- table: the violations and the coverage of each policy
+- json: the compliance report, same as the /api/compliance API
+- junit: a JUnit XML report, with a test suite per policy and a failed test case per resource in violation
+- sarif: a SARIF 2.1.0 log, with a rule per policy and a result per violation`,
+		Example: `  cloudgrep check -c policies.yaml
+  cloudgrep check -c policies.yaml --skip-refresh --output junit > compliance.xml
+  cloudgrep check -c policies.yaml --max-violations -1 --min-coverage 95`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cO.run(cmd.Context(), out)
+		},
+	}
+
+	flags := checkCmd.Flags()
+	flags.StringVarP(&cO.config, "config", "c", "", "Config file defining the policies")
+	flags.StringSliceVarP(&cO.regions, "regions", "r", []string(nil), "Comma separated list of regions to scan, or \"all\"")
+	flags.StringSliceVar(&cO.profiles, "profiles", []string(nil), "Comma separated list of AWS profiles to scan.")
+	flags.BoolVar(&cO.skipRefresh, "skip-refresh", false, "Skip the scan, check the resources already in the datastore")
+	flags.StringVarP(&cO.output, "output", "o", policy.OutputTable, fmt.Sprintf("Output format: %v", strings.Join(policy.Outputs, ", ")))
+	flags.IntVar(&cO.maxViolations, "max-violations", 0, "Fail if there are more violations, -1 to disable")
+	flags.Float64Var(&cO.minCoverage, "min-coverage", 0, "Fail if the percentage of compliant resources is lower")
+	return checkCmd
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCheckCommand(t *testing.T) {
+	var actualConfig config.Config
+	//the report has 2 violations and a coverage of 50%
+	violations := []model.Violation{
+		{Policy: "team", ResourceId: "i-123", Key: "team", Rule: model.RuleRequired, Message: "missing tag 'team'"},
+		{Policy: "team", ResourceId: "i-124", Key: "team", Rule: model.RuleRequired, Message: "missing tag 'team'"},
+	}
+	report := model.ComplianceReport{
+		ResourcesCount: 4, CompliantCount: 2, Coverage: 50,
+		Policies: []model.PolicyCompliance{{Name: "team", ResourcesCount: 4, CompliantCount: 2, Coverage: 50, Violations: violations}},
+	}
+
+	originalCmd := runCheckCmd
+	runCheckCmd = func(ctx context.Context, cfg config.Config, logger *zap.Logger) (model.ComplianceReport, error) {
+		actualConfig = cfg
+		return report, nil
+	}
+	defer func() {
+		runCheckCmd = originalCmd
+	}()
+
+	testCases := []struct {
+		name   string
+		args   []string
+		output string
+		err    string
+	}{
+		{"Default", []string{"check"}, "2 violation(s)", "2 violation(s), the maximum is 0"},
+		{"MaxViolations", []string{"check", "--max-violations", "2"}, "2 violation(s)", ""},
+		{"NoMaxViolations", []string{"check", "--max-violations", "-1", "--min-coverage", "50"}, "2 violation(s)", ""},
+		{"MinCoverage", []string{"check", "--max-violations", "-1", "--min-coverage", "75"}, "2 violation(s)", "the coverage is 50%, the minimum is 75%"},
+		{"JUnit", []string{"check", "-o", "junit", "--max-violations", "5"}, `<testsuites name="cloudgrep" tests="2" failures="2">`, ""},
+		{"SkipRefresh", []string{"check", "--skip-refresh", "-o", "json", "--max-violations", "5"}, `"coverage": 50`, ""},
+		{"UnknownOutput", []string{"check", "-o", "yaml"}, "", "unknown output 'yaml'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actualConfig = config.Config{}
+			buf := new(bytes.Buffer)
+			rootCmd := NewRootCmd(buf)
+			rootCmd.SetArgs(tc.args)
+			rootCmd.SetErr(new(bytes.Buffer))
+			err := rootCmd.Execute()
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+			require.Contains(t, buf.String(), tc.output)
+		})
+	}
+	//the config is loaded with the options
+	rootCmd := NewRootCmd(new(bytes.Buffer))
+	rootCmd.SetArgs([]string{"check", "--skip-refresh", "-r", "us-west-2", "--max-violations", "-1"})
+	require.NoError(t, rootCmd.Execute())
+	require.True(t, actualConfig.Datastore.SkipRefresh)
+	require.Equal(t, []string{"us-west-2"}, actualConfig.Providers[0].Regions)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+)
+
+//openSQLiteFile opens a database file created by cloudgrep, read-only: the file is not modified, its schema is not migrated
+func openSQLiteFile(ctx context.Context, path string) (datastore.Datastore, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("can't open the database: %w", err)
+	}
+	cfg, err := config.GetDefault()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Datastore.Type = "sqlite"
+	cfg.Datastore.DataSourceName = path
+	cfg.Datastore.ReadOnly = true
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("can't open the database '%v': %w", path, err)
+	}
+	return ds, nil
+}
+
+//openConfigDatastore opens the datastore of a config file, the default config is used if the file is not set
+func openConfigDatastore(ctx context.Context, configFile string) (datastore.Datastore, error) {
+	var cfg config.Config
+	var err error
+	if configFile != "" {
+		cfg, err = config.ReadFile(configFile)
+	} else {
+		cfg, err = config.GetDefault()
+	}
+	if err != nil {
+		return nil, err
+	}
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup datastore: %w", err)
+	}
+	return ds, nil
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputText = "text"
+	outputJson = "json"
+)
+
+type diffOptions struct {
+	config string
+	from   string
+	to     string
+	query  string
+	output string
+}
+
+func (dO *diffOptions) run(ctx context.Context, out io.Writer, args []string) error {
+	if dO.output != outputText && dO.output != outputJson {
+		return fmt.Errorf("unknown output '%v', must be %v or %v", dO.output, outputText, outputJson)
+	}
+	var from, to datastore.Datastore
+	var err error
+	if len(args) == 2 {
+		//compare two databases
+		if from, err = openSQLiteFile(ctx, args[0]); err != nil {
+			return err
+		}
+		if to, err = openSQLiteFile(ctx, args[1]); err != nil {
+			return err
+		}
+	} else {
+		//compare two runs of the configured datastore
+		if dO.from == "" {
+			return fmt.Errorf("the --from run is required when no database files are provided")
+		}
+		if from, err = openConfigDatastore(ctx, dO.config); err != nil {
+			return err
+		}
+		to = from
+	}
+	var query []byte
+	if dO.query != "" {
+		query = []byte(dO.query)
+	}
+	diff, err := datastore.Diff(ctx, from, dO.from, to, dO.to, query)
+	if err != nil {
+		return err
+	}
+	if dO.output == outputJson {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+	return printDiff(out, diff)
+}
+
+//printDiff prints one line per resource change, followed by the tag and raw data changes of the modified resources
+func printDiff(out io.Writer, diff model.ResourcesDiff) error {
+	printResource := func(prefix string, r *model.Resource) {
+		fmt.Fprintf(out, "%v %v %v (%v)\n", prefix, r.Type, r.EffectiveDisplayId(), r.Region)
+	}
+	for _, r := range diff.Added {
+		printResource("+", r)
+	}
+	for _, r := range diff.Removed {
+		printResource("-", r)
+	}
+	for _, change := range diff.Modified {
+		printResource("~", change.Resource)
+		for _, tag := range change.Tags {
+			switch tag.Change {
+			case model.ChangeAdded:
+				fmt.Fprintf(out, "    tags.%v: added %q\n", tag.Key, tag.To)
+			case model.ChangeRemoved:
+				fmt.Fprintf(out, "    tags.%v: removed %q\n", tag.Key, tag.From)
+			default:
+				fmt.Fprintf(out, "    tags.%v: %q -> %q\n", tag.Key, tag.From, tag.To)
+			}
+		}
+		for _, data := range change.RawData {
+			from, err := json.Marshal(data.From)
+			if err != nil {
+				return err
+			}
+			to, err := json.Marshal(data.To)
+			if err != nil {
+				return err
+			}
+			switch data.Change {
+			case model.ChangeAdded:
+				fmt.Fprintf(out, "    rawData.%v: added %s\n", data.Path, to)
+			case model.ChangeRemoved:
+				fmt.Fprintf(out, "    rawData.%v: removed %s\n", data.Path, from)
+			default:
+				fmt.Fprintf(out, "    rawData.%v: %s -> %s\n", data.Path, from, to)
+			}
+		}
+	}
+	fmt.Fprintf(out, "%v added, %v removed, %v modified\n", len(diff.Added), len(diff.Removed), len(diff.Modified))
+	return nil
+}
+
+// NewDiffCommand returns the diff subcommand
+func NewDiffCommand(out io.Writer) *cobra.Command {
+	var dO diffOptions
+	var diffCmd = &cobra.Command{
+		Use:   "diff [from.db to.db]",
+		Short: "Show the resources changed between two scans",
+		Long: `The diff command lists the resources added, removed and modified between two scans.
+For a modified resource, the tags changed and the raw data values changed are shown.
+
+The scans can be:
+- two runs of the configured datastore, set with --from and --to (the run ids are returned by the /api/runs API). The history must be enabled.
+- two SQLite database files created by cloudgrep, passed as arguments. --from and --to can select a run in each file.
+
+If --to is not set, the current resources are used.`,
+		Example: `  cloudgrep diff --from 6fd67489-d852-4962-95bc-eea01159993f
+  cloudgrep diff --from 2022-06-20T14:00:00Z --query '{"filter":{"core.type":"ec2.Instance"}}'
+  cloudgrep diff yesterday.db today.db --output json`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 0 && len(args) != 2 {
+				return fmt.Errorf("expected 0 or 2 database files, got %v", len(args))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dO.run(cmd.Context(), out, args)
+		},
+	}
+
+	flags := diffCmd.Flags()
+	flags.StringVarP(&dO.config, "config", "c", "", "Config file (default is https://github.com/juandiegopalomino/cloudgrep/blob/main/pkg/config/config.yaml)")
+	flags.StringVar(&dO.from, "from", "", "Run id or RFC 3339 timestamp of the previous scan")
+	flags.StringVar(&dO.to, "to", "", "Run id or RFC 3339 timestamp of the new scan (default is the current resources)")
+	flags.StringVarP(&dO.query, "query", "q", "", "Query to select the resources to compare, same format as the /api/resources body")
+	flags.StringVarP(&dO.output, "output", "o", outputText, "Output format: text or json")
+	return diffCmd
+}
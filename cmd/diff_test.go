@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore/testdata"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+//writeDB creates a SQLite database containing some resources
+func writeDB(t *testing.T, path string, resources model.Resources) {
+	ctx := context.Background()
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore.DataSourceName = path
+	ds, err := datastore.NewDatastore(ctx, cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	require.NoError(t, ds.WriteResources(ctx, resources))
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+}
+
+func TestDiffCommand(t *testing.T) {
+	resources := testdata.GetResources(t)
+	r1, r2, r3 := resources[0], resources[1], resources[2]
+	r1Updated := *r1
+	r1Updated.Tags = r1.Tags.Delete("team").Add("team", "new-team")
+
+	dir := t.TempDir()
+	fromDB := filepath.Join(dir, "from.db")
+	toDB := filepath.Join(dir, "to.db")
+	writeDB(t, fromDB, model.Resources{r1, r2})
+	writeDB(t, toDB, model.Resources{&r1Updated, r3})
+
+	t.Run("Text", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs([]string{"diff", fromDB, toDB})
+		require.NoError(t, rootCmd.Execute())
+		require.Equal(t, `+ s3.Bucket a-bucket-123 (us-east-1)
+- test.Instance i-124 (us-east-1)
+~ test.Instance i-123 (us-east-1)
+    tags.team: "infra" -> "new-team"
+1 added, 1 removed, 1 modified
+`, buf.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs([]string{"diff", fromDB, toDB, "-o", "json", "-q", `{"filter":{"core.type":"test.Instance"}}`})
+		require.NoError(t, rootCmd.Execute())
+		var diff model.ResourcesDiff
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &diff))
+		require.Equal(t, 0, len(diff.Added))
+		require.Equal(t, 1, len(diff.Removed))
+		require.Equal(t, 1, len(diff.Modified))
+	})
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		//the database files are not modified
+		before, err := os.ReadFile(fromDB)
+		require.NoError(t, err)
+		rootCmd := NewRootCmd(new(bytes.Buffer))
+		rootCmd.SetArgs([]string{"diff", fromDB, toDB})
+		require.NoError(t, rootCmd.Execute())
+		after, err := os.ReadFile(fromDB)
+		require.NoError(t, err)
+		require.Equal(t, before, after)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		testCases := [][]string{
+			{"diff", fromDB},
+			{"diff", fromDB, filepath.Join(dir, "not-found.db")},
+			{"diff", fromDB, toDB, "-o", "yaml"},
+			{"diff"},
+		}
+		for _, args := range testCases {
+			rootCmd := NewRootCmd(new(bytes.Buffer))
+			rootCmd.SetArgs(args)
+			rootCmd.SetErr(new(bytes.Buffer))
+			require.Error(t, rootCmd.Execute(), args)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/aws"
+	"github.com/spf13/cobra"
+)
+
+type iamPolicyOptions struct {
+	types []string
+}
+
+func (iO *iamPolicyOptions) run(out io.Writer) error {
+	actions, err := aws.IAMActions(iO.types)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(model.NewPolicyDocument(actions))
+}
+
+// NewIAMPolicyCommand returns the iam-policy subcommand
+func NewIAMPolicyCommand(out io.Writer) *cobra.Command {
+	var iO iamPolicyOptions
+	var iamPolicyCmd = &cobra.Command{
+		Use:   "iam-policy",
+		Short: "Print the read-only IAM policy required to fetch the AWS resources",
+		Long: `The iam-policy command prints the IAM policy allowing the read-only actions cloudgrep calls to fetch the AWS resources.
+The policy covers all the supported resource types, or only the types selected with --types.
+A type can be a pattern, for example "ec2.*".
+
+The IAM actions denied during the last scan are returned by the /api/permissions API.`,
+		Example: `  cloudgrep iam-policy > cloudgrep-policy.json
+  cloudgrep iam-policy --types "ec2.*,s3.Bucket"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return iO.run(out)
+		},
+	}
+
+	flags := iamPolicyCmd.Flags()
+	flags.StringSliceVarP(&iO.types, "types", "t", []string(nil), "Comma separated list of resource types or patterns, all the supported types by default")
+	return iamPolicyCmd
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIAMPolicyCommand(t *testing.T) {
+	run := func(args ...string) (model.PolicyDocument, error) {
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs(append([]string{"iam-policy"}, args...))
+		if err := rootCmd.Execute(); err != nil {
+			return model.PolicyDocument{}, err
+		}
+		var policy model.PolicyDocument
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &policy))
+		return policy, nil
+	}
+
+	//all the types
+	policy, err := run()
+	require.NoError(t, err)
+	require.Len(t, policy.Statement, 1)
+	actions := policy.Statement[0].Action
+	assert.Contains(t, actions, "ec2:DescribeInstances")
+	assert.Contains(t, actions, "s3:ListAllMyBuckets")
+	assert.Contains(t, actions, "elasticloadbalancing:DescribeTags")
+	assert.Equal(t, "*", policy.Statement[0].Resource)
+
+	//a subset of the types
+	policy, err = run("--types", "s3.Bucket,sns.*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ec2:DescribeRegions", "s3:GetBucketLocation", "s3:GetBucketTagging", "s3:ListAllMyBuckets", "sns:ListTagsForResource", "sns:ListTopics"}, policy.Statement[0].Action)
+
+	_, err = run("--types", "unknown.Type")
+	assert.ErrorContains(t, err, "unknown resource type unknown.Type")
+}
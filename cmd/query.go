@@ -0,0 +1,359 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	outputTable  = "table"
+	outputNdjson = "ndjson"
+	outputCsv    = "csv"
+)
+
+var queryOutputs = []string{outputTable, outputJson, outputNdjson, outputCsv}
+
+var defaultQueryColumns = []string{"core.id", "core.type", "core.region", "core.account_id"}
+
+type queryOptions struct {
+	config  string
+	db      string
+	query   string
+	filters []string
+	types   []string
+	sort    []string
+	asOf    string
+	columns []string
+	limit   int
+	output  string
+}
+
+//buildQuery returns the JSON query sent to the datastore
+//the filters are added to the filter of the --query flag, the values of a same key are combined with $or
+func (qO *queryOptions) buildQuery() ([]byte, error) {
+	query := make(map[string]interface{})
+	if qO.query != "" {
+		if err := json.Unmarshal([]byte(qO.query), &query); err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+	}
+	var keys []string
+	values := make(map[string][]string)
+	addFilter := func(key string, value string) {
+		if _, found := values[key]; !found {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], value)
+	}
+	for _, t := range qO.types {
+		addFilter("core.type", t)
+	}
+	for _, f := range qO.filters {
+		key, value, found := strings.Cut(f, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid filter '%v', expected key=value", f)
+		}
+		addFilter(key, value)
+	}
+	if len(keys) > 0 {
+		var and []interface{}
+		if filter, found := query["filter"]; found {
+			and = append(and, filter)
+		}
+		for _, key := range keys {
+			if len(values[key]) == 1 {
+				and = append(and, map[string]interface{}{key: values[key][0]})
+				continue
+			}
+			var or []interface{}
+			for _, value := range values[key] {
+				or = append(or, map[string]interface{}{key: value})
+			}
+			and = append(and, map[string]interface{}{"$or": or})
+		}
+		if len(and) == 1 {
+			query["filter"] = and[0]
+		} else {
+			query["filter"] = map[string]interface{}{"$and": and}
+		}
+	}
+	if len(qO.sort) > 0 {
+		query["sort"] = qO.sort
+	}
+	if qO.asOf != "" {
+		query["asOf"] = qO.asOf
+	}
+	return json.Marshal(query)
+}
+
+func (qO *queryOptions) run(ctx context.Context, out io.Writer) error {
+	if !slices.Contains(queryOutputs, qO.output) {
+		return fmt.Errorf("unknown output '%v', must be one of %v", qO.output, queryOutputs)
+	}
+	for _, column := range qO.columns {
+		if err := validateColumn(column); err != nil {
+			return err
+		}
+	}
+	query, err := qO.buildQuery()
+	if err != nil {
+		return err
+	}
+	var ds datastore.Datastore
+	if qO.db != "" {
+		ds, err = openSQLiteFile(ctx, qO.db)
+	} else {
+		ds, err = openConfigDatastore(ctx, qO.config)
+	}
+	if err != nil {
+		return err
+	}
+	w, err := newResourceWriter(out, qO.output, qO.columns)
+	if err != nil {
+		return err
+	}
+	err = datastore.PageResources(ctx, ds, query, qO.limit, func(resources model.Resources) error {
+		for _, r := range resources {
+			if err := w.write(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.close()
+}
+
+//validateColumn checks that a column is a core field, a tag or a raw data path
+func validateColumn(column string) error {
+	group, name, _ := strings.Cut(column, ".")
+	switch group {
+	case "core":
+		if model.FindCoreField(name) != nil {
+			return nil
+		}
+	case "tags", "properties":
+		if name != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown column '%v', expected core.<field>, tags.<key> or properties.<path>", column)
+}
+
+//coreColumnsHelp lists the core columns, ex: "core.id, core.type or core.region"
+func coreColumnsHelp() string {
+	names := make([]string, len(model.CoreFields))
+	for i, f := range model.CoreFields {
+		names[i] = "core." + f.Name
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+//columnValue returns the value of a resource column, a raw data path with more than one value returns the values separated by a comma
+func columnValue(r *model.Resource, column string) (string, error) {
+	group, name, _ := strings.Cut(column, ".")
+	switch group {
+	case "core":
+		return model.FindCoreField(name).Value(r), nil
+	case "tags":
+		if tag := r.Tags.Find(name); tag != nil {
+			return tag.Value, nil
+		}
+		return "", nil
+	}
+	properties, err := r.Properties()
+	if err != nil {
+		return "", err
+	}
+	var values []string
+	for _, p := range properties {
+		if p.Path == name {
+			values = append(values, p.Value)
+		}
+	}
+	return strings.Join(values, ","), nil
+}
+
+//resourceWriter writes the resources in an output format, one resource at a time
+type resourceWriter struct {
+	output  string
+	columns []string
+	ndjson  *json.Encoder
+	csv     *csv.Writer
+	table   *tabwriter.Writer
+	//for the json output, the first resource opens the array
+	count int
+	out   io.Writer
+}
+
+func newResourceWriter(out io.Writer, output string, columns []string) (*resourceWriter, error) {
+	w := &resourceWriter{output: output, columns: columns, out: out}
+	switch output {
+	case outputNdjson:
+		w.ndjson = json.NewEncoder(out)
+	case outputCsv:
+		w.csv = csv.NewWriter(out)
+		if err := w.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	case outputTable:
+		w.table = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(w.table, strings.Join(header, "\t"))
+	}
+	return w, nil
+}
+
+//row returns the values of the selected columns
+func (w *resourceWriter) row(r *model.Resource) ([]string, error) {
+	row := make([]string, len(w.columns))
+	for i, column := range w.columns {
+		value, err := columnValue(r, column)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = value
+	}
+	return row, nil
+}
+
+//object returns the value written by the JSON outputs, the full resource if no column is selected
+func (w *resourceWriter) object(r *model.Resource) (interface{}, error) {
+	if len(w.columns) == 0 {
+		return r, nil
+	}
+	row, err := w.row(r)
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]string, len(row))
+	for i, column := range w.columns {
+		object[column] = row[i]
+	}
+	return object, nil
+}
+
+func (w *resourceWriter) write(r *model.Resource) error {
+	defer func() { w.count++ }()
+	switch w.output {
+	case outputJson, outputNdjson:
+		object, err := w.object(r)
+		if err != nil {
+			return err
+		}
+		if w.output == outputJson {
+			//the resources are streamed, the array is written by hand
+			prefix := ",\n"
+			if w.count == 0 {
+				prefix = "[\n"
+			}
+			if _, err := io.WriteString(w.out, prefix); err != nil {
+				return err
+			}
+			data, err := json.Marshal(object)
+			if err != nil {
+				return err
+			}
+			_, err = w.out.Write(data)
+			return err
+		}
+		return w.ndjson.Encode(object)
+	}
+	row, err := w.row(r)
+	if err != nil {
+		return err
+	}
+	if w.csv != nil {
+		return w.csv.Write(row)
+	}
+	//a tab or a new line would break the table
+	for i, value := range row {
+		if value == "" {
+			value = "-"
+		}
+		row[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(value)
+	}
+	_, err = fmt.Fprintln(w.table, strings.Join(row, "\t"))
+	return err
+}
+
+func (w *resourceWriter) close() error {
+	switch w.output {
+	case outputJson:
+		end := "\n]\n"
+		if w.count == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(w.out, end)
+		return err
+	case outputCsv:
+		w.csv.Flush()
+		return w.csv.Error()
+	case outputTable:
+		return w.table.Flush()
+	}
+	return nil
+}
+
+// NewQueryCommand returns the query subcommand
+func NewQueryCommand(out io.Writer) *cobra.Command {
+	var qO queryOptions
+	var queryCmd = &cobra.Command{
+		Use:   "query",
+		Short: "Query the resources of the datastore, without starting the web server",
+		Long: `The query command prints the resources of the datastore matching a query.
+The resources are read one page at a time, all the matching resources are printed unless --limit is set.
+
+The query uses the same format as the /api/resources body, set with --query.
+--filter and --type add filters to the query: the values of a same key are combined with $or, the keys with $and.
+
+The columns can be:
+- a core field: ` + coreColumnsHelp() + `
+- a tag: tags.<key>
+- a raw data path: properties.<path>, ex: properties.Placement.AvailabilityZone or properties.SecurityGroups[*].GroupId
+
+The output formats are:
+- table: a column per field, an empty value is shown as -
+- csv: a column per field, with a header
+- json: an array of resources, or of objects with the selected columns if --columns is set
+- ndjson: a resource per line, or an object with the selected columns if --columns is set`,
+		Example: `  cloudgrep query --db cloudgrep.db --filter tags.team=billing --type ec2.Instance -o csv
+  cloudgrep query --db cloudgrep.db --columns core.id,tags.env,properties.InstanceType --sort -core.updated_at
+  cloudgrep query -c my_config.yaml --query '{"filter":{"properties.State.Name":"running"}}' -o ndjson`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("columns") && qO.output != outputJson && qO.output != outputNdjson {
+				qO.columns = defaultQueryColumns
+			}
+			return qO.run(cmd.Context(), out)
+		},
+	}
+
+	flags := queryCmd.Flags()
+	flags.StringVarP(&qO.config, "config", "c", "", "Config file (default is https://github.com/juandiegopalomino/cloudgrep/blob/main/pkg/config/config.yaml)")
+	flags.StringVar(&qO.db, "db", "", "SQLite database file created by cloudgrep, instead of the configured datastore")
+	flags.StringVarP(&qO.query, "query", "q", "", "Query to select the resources, same format as the /api/resources body")
+	flags.StringArrayVarP(&qO.filters, "filter", "f", []string(nil), "Filter as key=value, ex: tags.team=billing, can be repeated")
+	flags.StringSliceVarP(&qO.types, "type", "t", []string(nil), "Comma separated list of resource types")
+	flags.StringSliceVar(&qO.sort, "sort", []string(nil), "Comma separated list of fields to sort by, prefixed with - for a descending order")
+	flags.StringVar(&qO.asOf, "as-of", "", "Run id or RFC 3339 timestamp, query the resources of a previous scan (requires the datastore history)")
+	flags.StringSliceVar(&qO.columns, "columns", nil, fmt.Sprintf("Comma separated list of columns (default is %v)", strings.Join(defaultQueryColumns, ",")))
+	flags.IntVar(&qO.limit, "limit", 0, "Maximum number of resources, 0 for all")
+	flags.StringVarP(&qO.output, "output", "o", outputTable, fmt.Sprintf("Output format: %v", strings.Join(queryOutputs, ", ")))
+	return queryCmd
+}
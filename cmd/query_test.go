@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore/testdata"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCommand(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "cloudgrep.db")
+	writeDB(t, db, testdata.GetResources(t))
+
+	query := func(t *testing.T, args ...string) string {
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs(append([]string{"query", "--db", db}, args...))
+		require.NoError(t, rootCmd.Execute())
+		return buf.String()
+	}
+
+	t.Run("Table", func(t *testing.T) {
+		out := query(t)
+		assert.True(t, strings.HasPrefix(out, "CORE.ID       CORE.TYPE      CORE.REGION  CORE.ACCOUNT_ID\n"), out)
+		assert.Equal(t, `CORE.ID       CORE.TYPE      CORE.REGION  TAGS.TEAM
+a-bucket-123  s3.Bucket      us-east-1    -
+i-123         test.Instance  us-east-1    infra
+i-124         test.Instance  us-east-1    dev
+`, query(t, "--columns", "core.id,core.type,core.region,tags.team"))
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		out := query(t, "--type", "test.Instance", "--filter", "tags.team=infra", "--filter", "tags.team=dev",
+			"--columns", "core.id,tags.env,properties.InstanceType", "--sort", "-core.id", "-o", "csv")
+		assert.Equal(t, `core.id,tags.env,properties.InstanceType
+i-124,dev,t3.small
+i-123,prod,t3.small
+`, out)
+	})
+
+	t.Run("SortUpdatedAt", func(t *testing.T) {
+		out := query(t, "--type", "test.Instance", "--columns", "core.id", "--sort", "-core.updated_at", "-o", "csv")
+		assert.ElementsMatch(t, []string{"core.id", "i-123", "i-124"}, strings.Fields(out))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var resources model.Resources
+		require.NoError(t, json.Unmarshal([]byte(query(t, "-o", "json")), &resources))
+		require.Equal(t, 3, len(resources))
+		assert.Equal(t, "a-bucket-123", resources[0].Id)
+		assert.NotEmpty(t, resources[0].RawData)
+
+		//no resource
+		assert.Equal(t, "[]\n", query(t, "-o", "json", "--filter", "core.type=none"))
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		out := query(t, "-o", "ndjson", "--columns", "core.id,tags.team", "--limit", "2", "--query", `{"filter":{"core.type":"test.Instance"}}`)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Equal(t, 2, len(lines))
+		var row map[string]string
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+		assert.Equal(t, map[string]string{"core.id": "i-123", "tags.team": "infra"}, row)
+	})
+
+	t.Run("OptionalCoreColumns", func(t *testing.T) {
+		r := testdata.GetResources(t)[0]
+		r.AccountName = "management"
+		r.OrganizationalUnit = "Root/Production"
+		r.Namespace = "default"
+		r.Module = "module.vpc"
+		r.ManagedBy = model.ManagedByTerraform
+		optionalDB := filepath.Join(t.TempDir(), "optional.db")
+		writeDB(t, optionalDB, model.Resources{r})
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs([]string{"query", "--db", optionalDB, "-o", "csv", "--columns", "core.account_name,core.organizational_unit,core.namespace,core.module,core.managed_by"})
+		require.NoError(t, rootCmd.Execute())
+		assert.Equal(t, `core.account_name,core.organizational_unit,core.namespace,core.module,core.managed_by
+management,Root/Production,default,module.vpc,terraform
+`, buf.String())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		testCases := [][]string{
+			{"query", "--db", db, "-o", "yaml"},
+			{"query", "--db", db, "--columns", "core.unknown"},
+			{"query", "--db", db, "--filter", "no-value"},
+			{"query", "--db", db, "--query", "{"},
+			{"query", "--db", filepath.Join(t.TempDir(), "not-found.db")},
+		}
+		for _, args := range testCases {
+			rootCmd := NewRootCmd(new(bytes.Buffer))
+			rootCmd.SetArgs(args)
+			rootCmd.SetErr(new(bytes.Buffer))
+			require.Error(t, rootCmd.Execute(), args)
+		}
+	})
+}
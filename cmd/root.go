@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"io"
 	"os"
 
@@ -103,15 +104,33 @@ func NewRootCmd(out io.Writer) *cobra.Command {
 	flags.BoolVar(&rO.skipOpen, "skip-open", false, "Skip running the open command to open default browser")
 	flags.BoolVar(&rO.skipRefresh, "skip-refresh", false, "Skip running data refresh on start up")
 
-	rootCmd.AddCommand(NewVersionCommand(out), NewDemoCommand())
+	rootCmd.AddCommand(NewVersionCommand(out), NewDemoCommand(), NewDiffCommand(out), NewCheckCommand(out), NewQueryCommand(out), NewScanCommand(out), NewServeCommand(), NewIAMPolicyCommand(out))
 	rootCmd.Commands()
 	return rootCmd
 }
 
+//exitError is returned by a command to exit with a specific code
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
 func Execute() {
 	err := NewRootCmd(os.Stdout).Execute()
 	if err != nil {
 		util.PrintStackTrace(err, os.Stderr)
+		var exitErr exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
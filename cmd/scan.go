@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/cli"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+var runScanCmd = cli.Scan
+
+//exitCodePartialFailure is the exit code of the scan command when some resources could not be fetched
+const exitCodePartialFailure = 2
+
+type scanOptions struct {
+	config   string
+	db       string
+	regions  []string
+	profiles []string
+	output   string
+}
+
+func (sO *scanOptions) run(ctx context.Context, out io.Writer) error {
+	if sO.output != outputText && sO.output != outputJson {
+		return fmt.Errorf("unknown output '%v', must be %v or %v", sO.output, outputText, outputJson)
+	}
+	rO := rootOptions{
+		config:   sO.config,
+		regions:  sO.regions,
+		profiles: sO.profiles,
+	}
+	cfg, err := rO.loadConfig()
+	if err != nil {
+		return err
+	}
+	if sO.db != "" {
+		cfg.Datastore.Type = "sqlite"
+		cfg.Datastore.DataSourceName = sO.db
+	}
+	//the resources must be refreshed, even if the config skips it for the web server
+	cfg.Datastore.SkipRefresh = false
+	summary, err := runScanCmd(ctx, cfg, logger)
+	if summary.RunId != "" {
+		if printErr := printScanSummary(out, sO.output, summary); printErr != nil {
+			return printErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("scan failed: %w", err)
+	}
+	if summary.Failed() {
+		return exitError{
+			code: exitCodePartialFailure,
+			err:  fmt.Errorf("scan partially failed: %v of %v fetch(es) failed", summary.FailedCount, summary.FetchCount),
+		}
+	}
+	return nil
+}
+
+//printScanSummary prints the resources fetched by type and by region, followed by the failures
+func printScanSummary(out io.Writer, output string, summary model.ScanSummary) error {
+	if output == outputJson {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	printCounts := func(title string, counts []model.ScanCount) {
+		fmt.Fprintf(w, "%v\tRESOURCES\tFETCHES\tFAILED\n", title)
+		for _, c := range counts {
+			name := c.Name
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", name, c.ResourcesCount, c.FetchCount, c.FailedCount)
+		}
+		fmt.Fprintln(w)
+	}
+	printCounts("TYPE", summary.Types)
+	printCounts("REGION", summary.Regions)
+	if len(summary.Failures) > 0 {
+		fmt.Fprintln(w, "PROVIDER\tTYPE\tERROR")
+		for _, event := range summary.Failures {
+			resourceType := event.ResourceType
+			if resourceType == "" {
+				resourceType = "-"
+			}
+			//keep one line per failure
+			message := strings.Join(strings.Fields(event.Error), " ")
+			fmt.Fprintf(w, "%v\t%v\t%v\n", event.ProviderName, resourceType, message)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%v resource(s) scanned in %v, %v of %v fetch(es) failed\n",
+		summary.ResourcesCount, summary.Duration.Round(time.Millisecond), summary.FailedCount, summary.FetchCount)
+	return w.Flush()
+}
+
+// NewScanCommand returns the scan subcommand
+func NewScanCommand(out io.Writer) *cobra.Command {
+	var sO scanOptions
+	var scanCmd = &cobra.Command{
+		Use:   "scan",
+		Short: "Scan the cloud resources once, without starting the web server",
+		Long: `The scan command fetches the cloud resources of the configured providers, writes them to the datastore and exits.
+It prints the number of resources fetched by type and by region, followed by the failures.
+
+The exit status is:
+- 0 if all the resources were fetched
+- 1 if the scan failed
+- 2 if some resources could not be fetched, the other resources are written to the datastore
+
+The datastore can be served later with "cloudgrep --skip-refresh".`,
+		Example: `  cloudgrep scan --db /var/lib/cloudgrep/cloudgrep.db
+  cloudgrep scan -c my_config.yaml --regions all --output json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sO.run(cmd.Context(), out)
+		},
+	}
+
+	flags := scanCmd.Flags()
+	flags.StringVarP(&sO.config, "config", "c", "", "Config file (default is https://github.com/juandiegopalomino/cloudgrep/blob/main/pkg/config/config.yaml)")
+	flags.StringVar(&sO.db, "db", "", "SQLite database file to write, instead of the configured datastore")
+	flags.StringSliceVarP(&sO.regions, "regions", "r", []string(nil), "Comma separated list of regions to scan, or \"all\"")
+	flags.StringSliceVar(&sO.profiles, "profiles", []string(nil), "Comma separated list of AWS profiles to scan.")
+	flags.StringVarP(&sO.output, "output", "o", outputText, "Output format: text or json")
+	return scanCmd
+}
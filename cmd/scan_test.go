@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestScanCommand(t *testing.T) {
+	var actualConfig config.Config
+	var summary model.ScanSummary
+	var scanErr error
+	originalCmd := runScanCmd
+	runScanCmd = func(ctx context.Context, cfg config.Config, logger *zap.Logger) (model.ScanSummary, error) {
+		actualConfig = cfg
+		return summary, scanErr
+	}
+	defer func() {
+		runScanCmd = originalCmd
+	}()
+
+	success := model.NewScanSummary(model.Event{
+		RunId: "run-1", Type: model.EventTypeEngine, Status: model.EventStatusSuccess,
+		ChildEvents: model.Events{
+			{Type: model.EventTypeResource, Status: model.EventStatusSuccess, ResourceType: "ec2.Instance", Region: "us-east-1", ResourcesCount: 3},
+			{Type: model.EventTypeResource, Status: model.EventStatusSuccess, ResourceType: "s3.Bucket", Region: "global", ResourcesCount: 2},
+		},
+	})
+	success.Duration = 1500 * time.Millisecond
+	partial := model.NewScanSummary(model.Event{
+		RunId: "run-2", Type: model.EventTypeEngine, Status: model.EventStatusSuccess,
+		ChildEvents: model.Events{
+			{Type: model.EventTypeResource, Status: model.EventStatusSuccess, ResourceType: "ec2.Instance", Region: "us-east-1", ResourcesCount: 3},
+			{Type: model.EventTypeResource, Status: model.EventStatusFailed, ResourceType: "s3.Bucket", Region: "global", ProviderName: "aws", Error: "access\ndenied"},
+		},
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		summary, scanErr = success, nil
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs([]string{"scan", "--db", "nightly.db", "--regions", "us-east-1"})
+		require.NoError(t, rootCmd.Execute())
+		require.Equal(t, `TYPE          RESOURCES  FETCHES  FAILED
+ec2.Instance  3          1        0
+s3.Bucket     2          1        0
+
+REGION     RESOURCES  FETCHES  FAILED
+global     2          1        0
+us-east-1  3          1        0
+
+5 resource(s) scanned in 1.5s, 0 of 2 fetch(es) failed
+`, buf.String())
+		require.Equal(t, "sqlite", actualConfig.Datastore.Type)
+		require.Equal(t, "nightly.db", actualConfig.Datastore.DataSourceName)
+		require.Equal(t, []string{"us-east-1"}, actualConfig.Regions)
+		require.False(t, actualConfig.Datastore.SkipRefresh)
+	})
+
+	t.Run("PartialFailure", func(t *testing.T) {
+		summary, scanErr = partial, nil
+		buf := new(bytes.Buffer)
+		rootCmd := NewRootCmd(buf)
+		rootCmd.SetArgs([]string{"scan"})
+		rootCmd.SetErr(new(bytes.Buffer))
+		err := rootCmd.Execute()
+		require.ErrorContains(t, err, "scan partially failed: 1 of 2 fetch(es) failed")
+		var exitErr exitError
+		require.True(t, errors.As(err, &exitErr))
+		require.Equal(t, exitCodePartialFailure, exitErr.code)
+		require.Contains(t, buf.String(), "PROVIDER  TYPE       ERROR\naws       s3.Bucket  access denied\n")
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		summary, scanErr = model.ScanSummary{}, errors.New("can't run the provider engine")
+		rootCmd := NewRootCmd(new(bytes.Buffer))
+		rootCmd.SetArgs([]string{"scan", "-o", "json"})
+		rootCmd.SetErr(new(bytes.Buffer))
+		err := rootCmd.Execute()
+		require.ErrorContains(t, err, "scan failed: can't run the provider engine")
+		require.False(t, errors.As(err, &exitError{}))
+	})
+
+	t.Run("UnknownOutput", func(t *testing.T) {
+		rootCmd := NewRootCmd(new(bytes.Buffer))
+		rootCmd.SetArgs([]string{"scan", "-o", "yaml"})
+		rootCmd.SetErr(new(bytes.Buffer))
+		require.ErrorContains(t, rootCmd.Execute(), "unknown output 'yaml'")
+	})
+}
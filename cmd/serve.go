@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+type serveOptions struct {
+	config   string
+	db       string
+	bind     string
+	port     int
+	prefix   string
+	skipOpen bool
+}
+
+func (sO *serveOptions) run(ctx context.Context) error {
+	rO := rootOptions{
+		config:   sO.config,
+		bind:     sO.bind,
+		port:     sO.port,
+		prefix:   sO.prefix,
+		skipOpen: sO.skipOpen,
+	}
+	cfg, err := rO.loadConfig()
+	if err != nil {
+		return err
+	}
+	if sO.db != "" {
+		if _, err := os.Stat(sO.db); err != nil {
+			return fmt.Errorf("can't open the database: %w", err)
+		}
+		cfg.Datastore.Type = "sqlite"
+		cfg.Datastore.DataSourceName = sO.db
+	}
+	//never refresh the resources
+	cfg.Datastore.ReadOnly = true
+	cfg.Datastore.SkipRefresh = true
+	return runCmd(ctx, cfg, logger)
+}
+
+// NewServeCommand returns the serve subcommand
+func NewServeCommand() *cobra.Command {
+	var sO serveOptions
+	var serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a datastore read-only, without scanning the cloud",
+		Long: `The serve command starts the web server on an existing datastore, opened read-only.
+The resources are never refreshed: the cloud is not scanned on startup and the /api/refresh API is disabled.
+The resources, the stats and the status of the last run recorded in the datastore are served.
+
+The datastore is usually a SQLite database file created by "cloudgrep scan", set with --db.`,
+		Example: `  cloudgrep serve --db inventory.db
+  cloudgrep serve --db inventory.db --bind 0.0.0.0 --port 8081 --skip-open`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sO.run(cmd.Context())
+		},
+	}
+
+	flags := serveCmd.Flags()
+	flags.StringVarP(&sO.config, "config", "c", "", "Config file (default is https://github.com/juandiegopalomino/cloudgrep/blob/main/pkg/config/config.yaml)")
+	flags.StringVar(&sO.db, "db", "", "SQLite database file created by cloudgrep, instead of the configured datastore")
+	flags.StringVar(&sO.bind, "bind", "", "Host to bind on")
+	flags.IntVarP(&sO.port, "port", "p", 0, "Port to use")
+	flags.StringVar(&sO.prefix, "prefix", "", "URL prefix to use")
+	flags.BoolVar(&sO.skipOpen, "skip-open", false, "Skip running the open command to open default browser")
+	return serveCmd
+}
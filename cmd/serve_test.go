@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestServeCommand(t *testing.T) {
+	var actualConfig config.Config
+	originalCmd := runCmd
+	runCmd = func(ctx context.Context, cfg config.Config, logger *zap.Logger) error {
+		actualConfig = cfg
+		return nil
+	}
+	defer func() {
+		runCmd = originalCmd
+	}()
+
+	db := filepath.Join(t.TempDir(), "inventory.db")
+	writeDB(t, db, nil)
+	rootCmd := NewRootCmd(new(bytes.Buffer))
+	rootCmd.SetArgs([]string{"serve", "--db", db, "-p", "8081", "--skip-open"})
+	require.NoError(t, rootCmd.Execute())
+	require.Equal(t, "sqlite", actualConfig.Datastore.Type)
+	require.Equal(t, db, actualConfig.Datastore.DataSourceName)
+	require.True(t, actualConfig.Datastore.ReadOnly)
+	require.True(t, actualConfig.Datastore.SkipRefresh)
+	require.Equal(t, 8081, actualConfig.Web.Port)
+	require.True(t, actualConfig.Web.SkipOpen)
+
+	//the database must exist
+	rootCmd = NewRootCmd(new(bytes.Buffer))
+	rootCmd.SetArgs([]string{"serve", "--db", filepath.Join(t.TempDir(), "not-found.db")})
+	rootCmd.SetErr(new(bytes.Buffer))
+	require.ErrorContains(t, rootCmd.Execute(), "can't open the database")
+}
@@ -55,6 +55,10 @@ func loadService(dir string, name string) (Service, error) {
 		return c, err
 	}
 
+	if c.IAMPrefix == "" {
+		c.IAMPrefix = c.EndpointID
+	}
+
 	sort.Slice(c.Types, func(i, j int) bool {
 		return strings.Compare(c.Types[i].Name, c.Types[j].Name) < 0
 	})
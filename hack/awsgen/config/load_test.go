@@ -17,6 +17,8 @@ func TestLoad(t *testing.T) {
 	require.NotNil(t, cfg)
 
 	assert.Len(t, cfg.Services, 1)
+	// the IAM prefix defaults to the endpoint id
+	assert.Equal(t, cfg.Services[0].EndpointID, cfg.Services[0].IAMPrefix)
 
 	errs := cfg.Validate()
 	assert.Len(t, errs, 0)
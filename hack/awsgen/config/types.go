@@ -27,6 +27,11 @@ type Service struct {
 	// Defaults to Name if not specified.
 	EndpointID string `yaml:"endpointId"`
 
+	// IAMPrefix is the service prefix of the IAM actions required to call the service APIs.
+	// For example, for the `elb` service, the IAMPrefix is `elasticloadbalancing`.
+	// Defaults to EndpointID if not specified.
+	IAMPrefix string `yaml:"iamPrefix"`
+
 	// Global controls whether or not all types in this service default to global, but can be overriden on a per-type basis.
 	// A global service is one where resources are not defined in a specific region.
 	Global bool `yaml:"global"`
@@ -60,6 +60,7 @@ func (g Generator) generateServiceRegister(service config.Service) (string, util
 			DisplayIDField: typ.ListAPI.DisplayIDField,
 			Global:         global,
 			Tags:           typ.ListAPI.Tags,
+			IAMActions:     iamActions(service, typ),
 		})
 
 		if !typ.GetTagsAPI.Tags.Zero() {
@@ -78,4 +79,5 @@ type typeRegisterInfo struct {
 	DisplayIDField config.Field
 	Global         bool
 	Tags           *config.TagField
+	IAMActions     []string
 }
@@ -69,6 +69,18 @@ func resourceName(service config.Service, typ config.Type) string {
 	return fmt.Sprintf("%s.%s", service.Name, typ.Name)
 }
 
+// iamActions returns the IAM actions required to fetch a specific type: its list call and its tags call, if any.
+// The IAM prefix of the service is defaulted when the config is loaded.
+func iamActions(svc config.Service, typ config.Type) []string {
+	prefix := svc.IAMPrefix
+	actions := []string{prefix + ":" + typ.ListAPI.Call}
+	if typ.GetTagsAPI.Call != "" {
+		actions = append(actions, prefix+":"+typ.GetTagsAPI.Call)
+	}
+
+	return actions
+}
+
 // registerFuncName returns the name of the Go func that returns type mapping data for a specific service.
 func registerFuncName(svc config.Service) string {
 	return lowerCamelCaseJoin(
@@ -4,6 +4,7 @@
 	"strings"
 	"testing"
 
+	"github.com/juandiegopalomino/cloudgrep/hack/awsgen/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,3 +28,17 @@ func TestAwsServicePackage(t *testing.T) {
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestIAMActions(t *testing.T) {
+	typ := config.Type{
+		ListAPI:    config.ListAPI{Call: "DescribeLoadBalancers"},
+		GetTagsAPI: config.GetTagsAPI{Call: "DescribeTags"},
+	}
+
+	svc := config.Service{Name: "elb", EndpointID: "elasticloadbalancing", IAMPrefix: "elasticloadbalancing"}
+	assert.Equal(t, []string{"elasticloadbalancing:DescribeLoadBalancers", "elasticloadbalancing:DescribeTags"}, iamActions(svc, typ))
+
+	// without a tags call
+	svc = config.Service{Name: "elb", EndpointID: "elb", IAMPrefix: "elb"}
+	assert.Equal(t, []string{"elb:DescribeLoadBalancers"}, iamActions(svc, config.Type{ListAPI: typ.ListAPI}))
+}
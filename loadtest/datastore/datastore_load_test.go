@@ -62,8 +62,8 @@ func TestLoad(t *testing.T) {
 		//this is the primary load test
 		{
 			Resources: 2000,
-			//total includes the mandatory fields (region, type) and should be under 2000
-			Tags:      1996,
+			//there is no limit on the number of tag keys
+			Tags:      3000,
 			BatchSize: 100,
 			Queries:   200,
 		},
@@ -5,6 +5,7 @@
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -17,7 +18,10 @@
 
 type EngineFunc func(context.Context) error
 
-func StartWebServer(ctx context.Context, cfg config.Config, logger *zap.Logger, ds datastore.Datastore, engineF EngineFunc) {
+//NextRunFunc returns the time of the next scheduled refresh, zero if there is none
+type NextRunFunc func() time.Time
+
+func StartWebServer(ctx context.Context, cfg config.Config, logger *zap.Logger, ds datastore.Datastore, engineF EngineFunc, nextRunF NextRunFunc) {
 	router := gin.Default()
 
 	if logger.Core().Enabled(zap.DebugLevel) {
@@ -26,7 +30,7 @@ func StartWebServer(ctx context.Context, cfg config.Config, logger *zap.Logger,
 		gin.SetMode("release")
 	}
 
-	SetupRoutes(router, cfg, logger, ds, engineF)
+	SetupRoutes(router, cfg, logger, ds, engineF, nextRunF)
 
 	fmt.Println("Starting server...")
 	go func() {
@@ -107,9 +111,104 @@ func EngineStatus(c *gin.Context) {
 		badRequest(c, err)
 		return
 	}
+	if nextRunF, ok := c.MustGet("nextRunFunc").(NextRunFunc); ok && nextRunF != nil {
+		if nextRun := nextRunF(); !nextRun.IsZero() {
+			status.NextRunAt = &nextRun
+		}
+	}
 	c.JSON(200, status)
 }
 
+// Runs returns the engine runs, their id can be used to query the resources at the end of a run
+func Runs(c *gin.Context) {
+	ds := c.MustGet("datastore").(datastore.Datastore)
+	runs, err := ds.GetRuns(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	c.JSON(200, runs)
+}
+
+// Diff returns the resources added, removed and modified between two runs
+// the body can contain a query to only compare some resources
+func Diff(c *gin.Context) {
+	ds := c.MustGet("datastore").(datastore.Datastore)
+	from := c.GetString("from")
+	if from == "" {
+		badRequest(c, fmt.Errorf("missing required parameter 'from'"))
+		return
+	}
+	//the current resources are used if 'to' is not set
+	to := c.GetString("to")
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = c.GetRawData()
+		if err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+	diff, err := datastore.Diff(c, ds, from, ds, to, body)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	c.JSON(200, diff)
+}
+
+// Compliance returns the evaluation of the tag policies done after the last refresh
+func Compliance(c *gin.Context) {
+	ds := c.MustGet("datastore").(datastore.Datastore)
+	report, err := ds.GetComplianceReport(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if report == nil {
+		notFoundf(c, "no compliance report: the tag policies are evaluated after a refresh, check that some policies are configured")
+		return
+	}
+	c.JSON(200, report)
+}
+
+// Managed summarizes the resources managed by Terraform or CloudFormation, by resource type and by account
+// the body can contain a query to only summarize some resources
+func Managed(c *gin.Context) {
+	ds := c.MustGet("datastore").(datastore.Datastore)
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		body, err = c.GetRawData()
+		if err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+	summary, err := ds.GetManagedSummary(c, body)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	c.JSON(200, summary)
+}
+
+// Permissions returns the permissions denied during the last run, with a policy granting them
+func Permissions(c *gin.Context) {
+	ds := c.MustGet("datastore").(datastore.Datastore)
+	status, err := ds.EngineStatus(c)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	if status.RunId == "" {
+		notFoundf(c, "no run: the permissions are checked when the resources are refreshed")
+		return
+	}
+	c.JSON(200, model.NewPermissionReport(status))
+}
+
 // Refresh trigger the engine to fetch the resources
 func Refresh(c *gin.Context) {
 
@@ -133,3 +232,8 @@ func Refresh(c *gin.Context) {
 	c.Status(http.StatusOK)
 
 }
+
+// RefreshDisabled rejects the refresh of a read-only datastore
+func RefreshDisabled(c *gin.Context) {
+	errorResponse(c, http.StatusForbidden, fmt.Errorf("the datastore is read-only, the resources can't be refreshed"))
+}
@@ -7,6 +7,7 @@
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -28,6 +29,7 @@ func prepareApiUnitTest(t *testing.T) *mockApi {
 	datastoreConfigs := config.Datastore{
 		Type:           "sqlite",
 		DataSourceName: "file::memory:",
+		History:        config.History{Retention: time.Hour},
 	}
 	cfg, err := config.GetDefault()
 	require.NoError(t, err)
@@ -44,7 +46,7 @@ func prepareApiUnitTest(t *testing.T) *mockApi {
 		ds:        ds,
 		resources: resources,
 	}
-	SetupRoutes(router, cfg, logger, ds, mockApi.runEngine)
+	SetupRoutes(router, cfg, logger, ds, mockApi.runEngine, nil)
 
 	//write the resources
 	require.NotZero(t, len(resources))
@@ -302,15 +304,268 @@ func TestResourceFieldsRoute(t *testing.T) {
 		require.Equal(t, http.StatusOK, w.Code)
 		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
 		fields := response.FieldGroups
-		require.Equal(t, len(fields), 2)
+		require.Equal(t, len(fields), 3)
 		//check number of groups
-		require.Equal(t, 2, len(fields))
+		require.Equal(t, 3, len(fields))
 		//check fields by group
 		require.Equal(t, 3, len(fields.FindGroup("core").Fields))
 		require.Equal(t, 10, len(fields.FindGroup("tags").Fields))
+		require.Equal(t, 11, len(fields.FindGroup("properties").Fields))
 	})
 }
 
+func TestRunsRoute(t *testing.T) {
+	m := prepareApiUnitTest(t)
+	path := "/api/runs"
+
+	//remove a resource in a 2nd run
+	m.resources = m.resources[:2]
+	require.NoError(t, m.runEngine(m.ctx))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	m.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var runs model.Events
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &runs))
+	require.Equal(t, 2, len(runs))
+	require.Equal(t, "success", runs[0].Status)
+
+	//query the resources of each run
+	for i, expectedCount := range []int{2, 3} {
+		w = httptest.NewRecorder()
+		body := strings.NewReader(fmt.Sprintf(`{"asOf":"%v"}`, runs[i].RunId))
+		req, err := http.NewRequest("POST", "/api/resources", body)
+		require.NoError(t, err)
+		m.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var response model.ResourcesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, expectedCount, response.Count)
+	}
+
+	//unknown run
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/api/resources", strings.NewReader(`{"asOf":"unknown"}`))
+	m.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDiffRoute(t *testing.T) {
+	m := prepareApiUnitTest(t)
+	path := "/api/diff"
+	runs, err := m.ds.GetRuns(m.ctx)
+	require.NoError(t, err)
+	run1 := runs[0].RunId
+
+	//remove a resource in a 2nd run
+	removed := m.resources[2]
+	m.resources = m.resources[:2]
+	require.NoError(t, m.runEngine(m.ctx))
+
+	t.Run("Removed", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		q := req.URL.Query()
+		q.Add("from", run1)
+		req.URL.RawQuery = q.Encode()
+		m.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var diff model.ResourcesDiff
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+		require.Equal(t, 0, len(diff.Added))
+		testingutil.AssertEqualsResources(t, model.Resources{removed}, diff.Removed)
+		require.Equal(t, 0, len(diff.Modified))
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		body := strings.NewReader(`{"filter":{"core.type":"test.Instance"}}`)
+		req, _ := http.NewRequest("POST", path, body)
+		q := req.URL.Query()
+		q.Add("from", run1)
+		q.Add("to", runs[0].RunId)
+		req.URL.RawQuery = q.Encode()
+		m.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var diff model.ResourcesDiff
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &diff))
+		require.True(t, diff.Empty())
+	})
+
+	t.Run("MissingFrom", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		m.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestComplianceRoute(t *testing.T) {
+	m := prepareApiUnitTest(t)
+	path := "/api/compliance"
+
+	//no report yet
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	m.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	report := model.ComplianceReport{
+		RunId:          "run-1",
+		ResourcesCount: 3,
+		CompliantCount: 2,
+		Coverage:       66.67,
+		Policies:       []model.PolicyCompliance{{Name: "team", ResourcesCount: 3, CompliantCount: 2, Coverage: 66.67}},
+	}
+	require.NoError(t, m.ds.WriteComplianceReport(m.ctx, report))
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", path, nil)
+	m.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body model.ComplianceReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Equal(t, "run-1", body.RunId)
+	require.Equal(t, 66.67, body.Coverage)
+	require.Equal(t, "team", body.Policies[0].Name)
+}
+
+func TestManagedRoute(t *testing.T) {
+	m := prepareApiUnitTest(t)
+	path := "/api/managed"
+	get := func(method string, query string) model.ManagedSummary {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, strings.NewReader(query))
+		m.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body model.ManagedSummary
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	//the resources written by the test engine are not managed
+	summary := get("GET", "")
+	require.Equal(t, len(m.resources), summary.Total.ResourcesCount)
+	require.Equal(t, len(m.resources), summary.Total.UnmanagedCount)
+	require.Equal(t, float64(0), summary.Total.Coverage)
+	require.NotEmpty(t, summary.Types)
+	require.NotEmpty(t, summary.Accounts)
+
+	r := *m.resources[0]
+	r.ManagedBy = model.ManagedByTerraform
+	require.NoError(t, m.ds.WriteResources(m.ctx, model.Resources{&r}))
+	summary = get("POST", fmt.Sprintf(`{"filter":{"core.type":"%v"}}`, r.Type))
+	require.Equal(t, 1, summary.Total.TerraformCount)
+	require.Len(t, summary.Types, 1)
+	require.Equal(t, r.Type, summary.Types[0].Name)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(`{"filter":`))
+	m.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPermissionsRoute(t *testing.T) {
+	m := prepareApiUnitTest(t)
+	path := "/api/permissions"
+	get := func() model.PermissionReport {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		m.router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body model.PermissionReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	//no permission missing
+	report := get()
+	require.Empty(t, report.Actions)
+	require.Empty(t, report.Policy.Statement)
+
+	//a run with a denied action
+	require.NoError(t, m.ds.WriteEvent(m.ctx, model.NewEngineEventStart()))
+	event := model.NewResourceEventEnd("aws-us-east-1", "ec2.Instance", fmt.Errorf("denied"))
+	event.AccountId, event.Region = "123456789012", "us-east-1"
+	event.ErrorKind, event.ErrorCode, event.ErrorOperation, event.ErrorAction = model.ErrorKindPermission, "UnauthorizedOperation", "DescribeInstances", "ec2:DescribeInstances"
+	require.NoError(t, m.ds.WriteEvent(m.ctx, event))
+	require.NoError(t, m.ds.WriteEvent(m.ctx, model.NewEngineEventEnd(nil)))
+	report = get()
+	require.Equal(t, []string{"ec2:DescribeInstances"}, report.Actions)
+	require.Equal(t, []string{"us-east-1"}, report.Missing[0].Regions)
+	require.Equal(t, []string{"ec2:DescribeInstances"}, report.Policy.Statement[0].Action)
+}
+
+func TestEngineStatusNextRun(t *testing.T) {
+	m := prepareApiUnitTest(t)
+	get := func() map[string]interface{} {
+		record := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", "/api/enginestatus", nil)
+		require.NoError(t, err)
+		m.router.ServeHTTP(record, req)
+		require.Equal(t, http.StatusOK, record.Code)
+		body := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(record.Body.Bytes(), &body))
+		return body
+	}
+	//no schedule
+	_, found := get()["nextRunAt"]
+	require.False(t, found)
+
+	nextRun := time.Date(2022, 6, 20, 14, 0, 0, 0, time.UTC)
+	m.router = gin.Default()
+	SetupRoutes(m.router, config.Config{}, zaptest.NewLogger(t), m.ds, m.runEngine, func() time.Time { return nextRun })
+	body := get()
+	require.Equal(t, "success", body["status"])
+	require.Equal(t, "2022-06-20T14:00:00Z", body["nextRunAt"])
+}
+
+func TestReadOnlyRoutes(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore = config.Datastore{Type: "sqlite", DataSourceName: filepath.Join(t.TempDir(), "inventory.db")}
+
+	//write a run, then serve the database read-only
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+	m := mockApi{ctx: ctx, ds: ds, resources: testdata.GetResources(t)}
+	require.NoError(t, m.runEngine(ctx))
+	cfg.Datastore.ReadOnly = true
+	ds, err = datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+	router := gin.Default()
+	engineCalled := false
+	SetupRoutes(router, cfg, logger, ds, func(ctx context.Context) error {
+		engineCalled = true
+		return nil
+	}, nil)
+
+	get := func(path string) map[string]interface{} {
+		record := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", path, nil)
+		require.NoError(t, err)
+		router.ServeHTTP(record, req)
+		require.Equal(t, http.StatusOK, record.Code)
+		body := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(record.Body.Bytes(), &body))
+		return body
+	}
+	require.Equal(t, 3.0, get("/api/resources")["count"])
+	require.Equal(t, 3.0, get("/api/stats")["resourcesCount"])
+	require.Equal(t, "success", get("/api/enginestatus")["status"])
+
+	//the refresh is rejected
+	record := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/api/refresh", nil)
+	require.NoError(t, err)
+	router.ServeHTTP(record, req)
+	require.Equal(t, http.StatusForbidden, record.Code)
+	require.Contains(t, record.Body.String(), "the datastore is read-only")
+	require.False(t, engineCalled)
+}
+
 func TestRefreshPostRoute(t *testing.T) {
 	refreshPath := "/api/refresh"
 	engineStatusPath := "/api/enginestatus"
@@ -10,14 +10,15 @@
 	"go.uber.org/zap"
 )
 
-func setupMiddlewares(group *gin.RouterGroup, cfg config.Config, logger *zap.Logger, ds datastore.Datastore, engineF EngineFunc) {
+func setupMiddlewares(group *gin.RouterGroup, cfg config.Config, logger *zap.Logger, ds datastore.Datastore, engineF EngineFunc, nextRunF NextRunFunc) {
 	if logger.Core().Enabled(zap.DebugLevel) {
 		group.Use(logAllQueryParams(cfg, logger), logAllRequests(cfg, logger))
 	}
 	var values = map[string]interface{}{
-		"logger":     logger,
-		"datastore":  ds,
-		"engineFunc": engineF,
+		"logger":      logger,
+		"datastore":   ds,
+		"engineFunc":  engineF,
+		"nextRunFunc": nextRunF,
 	}
 	group.Use(setSharedObjects(values))
 	group.Use(setParams(cfg, logger))
@@ -52,8 +53,14 @@ func setParams(cfg config.Config, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Query("id")
 		c.Set("id", id)
+		from := c.Query("from")
+		c.Set("from", from)
+		to := c.Query("to")
+		c.Set("to", to)
 		logger.Sugar().Debugw("Request params:",
 			zap.String("id", id),
+			zap.String("from", from),
+			zap.String("to", to),
 		)
 	}
 }
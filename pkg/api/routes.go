@@ -8,14 +8,14 @@
 	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
 )
 
-func SetupRoutes(router *gin.Engine, cfg config.Config, logger *zap.Logger, ds datastore.Datastore, engineF EngineFunc) {
+func SetupRoutes(router *gin.Engine, cfg config.Config, logger *zap.Logger, ds datastore.Datastore, engineF EngineFunc, nextRunF NextRunFunc) {
 	root := router.Group(cfg.Web.Prefix)
 
 	root.GET("/", gin.WrapH(GetHome(cfg.Web.Prefix)))
 	root.GET("/static/*path", gin.WrapH(GetAssets(cfg.Web.Prefix)))
 
 	api := root.Group("/api")
-	setupMiddlewares(api, cfg, logger, ds, engineF)
+	setupMiddlewares(api, cfg, logger, ds, engineF, nextRunF)
 
 	healthz := root.Group("/healthz")
 	healthz.Use(setSharedObjects(map[string]interface{}{
@@ -29,5 +29,16 @@ func SetupRoutes(router *gin.Engine, cfg config.Config, logger *zap.Logger, ds d
 	api.POST("/resources", Resources)
 	api.GET("/stats", Stats)
 	api.GET("/enginestatus", EngineStatus)
-	api.POST("/refresh", Refresh)
+	api.GET("/runs", Runs)
+	api.GET("/diff", Diff)
+	api.POST("/diff", Diff)
+	api.GET("/compliance", Compliance)
+	api.GET("/managed", Managed)
+	api.POST("/managed", Managed)
+	api.GET("/permissions", Permissions)
+	if cfg.Datastore.ReadOnly {
+		api.POST("/refresh", RefreshDisabled)
+	} else {
+		api.POST("/refresh", Refresh)
+	}
 }
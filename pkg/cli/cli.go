@@ -8,6 +8,7 @@
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/juandiegopalomino/cloudgrep/pkg/model"
@@ -19,13 +20,16 @@
 	"github.com/juandiegopalomino/cloudgrep/pkg/config"
 	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
 	"github.com/juandiegopalomino/cloudgrep/pkg/engine"
+	"github.com/juandiegopalomino/cloudgrep/pkg/policy"
+	"github.com/juandiegopalomino/cloudgrep/pkg/scheduler"
 	"github.com/juandiegopalomino/cloudgrep/pkg/util"
 )
 
 type cli struct {
-	cfg    config.Config
-	logger *zap.Logger
-	ds     datastore.Datastore
+	cfg       config.Config
+	logger    *zap.Logger
+	ds        datastore.Datastore
+	evaluator *policy.Evaluator
 }
 
 func Run(ctx context.Context, cfg config.Config, logger *zap.Logger) error {
@@ -36,21 +40,30 @@ func Run(ctx context.Context, cfg config.Config, logger *zap.Logger) error {
 	//send amplitude event
 	amplitude.SendEvent(logger, amplitude.EventLoad, nil)
 
-	//init the storage to contain cloud data
-	var err error
-	cli := cli{cfg: cfg, logger: logger}
-	cli.ds, err = datastore.NewDatastore(ctx, cfg, logger)
+	cli, err := newCli(ctx, cfg, logger)
 	if err != nil {
-		return fmt.Errorf("failed to setup datastore: %w", err)
+		return err
 	}
 
-	//start the providers to collect cloud data
-	if !cfg.Datastore.SkipRefresh {
+	//check the refresh schedule before the first refresh
+	var sched *scheduler.Scheduler
+	if !cfg.Datastore.ReadOnly {
+		sched, err = scheduler.NewScheduler(cfg.Datastore, logger, cli.ds, cli.runEngine)
+		if err != nil {
+			return err
+		}
+	}
+
+	//start the providers to collect cloud data, a read-only datastore is never refreshed
+	if !cfg.Datastore.SkipRefresh && !cfg.Datastore.ReadOnly {
 		if err := cli.runEngine(ctx); err != nil {
 			return err
 		}
 	}
-	api.StartWebServer(ctx, cfg, logger, cli.ds, cli.runEngine)
+	api.StartWebServer(ctx, cfg, logger, cli.ds, cli.runEngine, sched.NextRun)
+	if sched != nil {
+		sched.Start(ctx)
+	}
 
 	url := fmt.Sprintf("http://%v:%v/%v", cfg.Web.Host, cfg.Web.Port, cfg.Web.Prefix)
 	url = strings.Trim(url, "/")
@@ -63,10 +76,82 @@ func Run(ctx context.Context, cfg config.Config, logger *zap.Logger) error {
 	return nil
 }
 
+func newCli(ctx context.Context, cfg config.Config, logger *zap.Logger) (*cli, error) {
+	//check the tag policies before starting
+	var err error
+	cli := cli{cfg: cfg, logger: logger}
+	cli.evaluator, err = policy.NewEvaluator(cfg.Policies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag policies: %w", err)
+	}
+
+	//init the storage to contain cloud data
+	cli.ds, err = datastore.NewDatastore(ctx, cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup datastore: %w", err)
+	}
+	return &cli, nil
+}
+
+//Check evaluates the tag policies without starting the web server
+//the resources are refreshed first, unless the datastore is configured to skip the refresh
+func Check(ctx context.Context, cfg config.Config, logger *zap.Logger) (model.ComplianceReport, error) {
+	cli, err := newCli(ctx, cfg, logger)
+	if err != nil {
+		return model.ComplianceReport{}, err
+	}
+	if cli.evaluator.Empty() {
+		return model.ComplianceReport{}, fmt.Errorf("no tag policy to check, define some policies in the config file")
+	}
+	if !cfg.Datastore.SkipRefresh {
+		//the policies are evaluated at the end of the refresh
+		if err := cli.runEngine(ctx); err != nil {
+			return model.ComplianceReport{}, err
+		}
+		report, err := cli.ds.GetComplianceReport(ctx)
+		if err != nil {
+			return model.ComplianceReport{}, err
+		}
+		if report == nil {
+			return model.ComplianceReport{}, fmt.Errorf("the tag policies were not evaluated")
+		}
+		return *report, nil
+	}
+	//evaluate the resources of the last run
+	runs, err := cli.ds.GetRuns(ctx)
+	if err != nil {
+		return model.ComplianceReport{}, err
+	}
+	runId := ""
+	if len(runs) > 0 {
+		runId = runs[0].RunId
+	}
+	return cli.evaluator.EvaluateDatastore(ctx, cli.ds, runId)
+}
+
+//Scan runs the providers once without starting the web server, it returns a summary of the run
+//an error is returned if the engine could not run, a partial failure is reported in the summary
+func Scan(ctx context.Context, cfg config.Config, logger *zap.Logger) (model.ScanSummary, error) {
+	cli, err := newCli(ctx, cfg, logger)
+	if err != nil {
+		return model.ScanSummary{}, err
+	}
+	start := time.Now()
+	runErr := cli.runEngine(ctx)
+	status, err := cli.ds.EngineStatus(ctx)
+	if err != nil {
+		return model.ScanSummary{}, multierror.Append(runErr, err)
+	}
+	summary := model.NewScanSummary(status)
+	summary.Duration = time.Since(start)
+	return summary, runErr
+}
+
 //runEngine runs the providers to collect the cloud resources
 //it returns when it's done fetching
 func (cli *cli) runEngine(ctx context.Context) error {
-	if err := cli.ds.WriteEvent(ctx, model.NewEngineEventStart()); err != nil {
+	start := model.NewEngineEventStart()
+	if err := cli.ds.WriteEvent(ctx, start); err != nil {
 		return err
 	}
 	eng, errors := engine.NewEngine(ctx, cli.cfg, cli.logger, cli.ds)
@@ -86,6 +171,12 @@ func (cli *cli) runEngine(ctx context.Context) error {
 	if err := cli.ds.WriteEvent(ctx, model.NewEngineEventEnd(errors)); err != nil {
 		errors = multierror.Append(errors, err)
 	}
+	//evaluate the tag policies once the resources are refreshed
+	if cli.evaluator != nil && !cli.evaluator.Empty() {
+		if _, err := cli.evaluator.EvaluateDatastore(ctx, cli.ds, start.RunId); err != nil {
+			errors = multierror.Append(errors, err)
+		}
+	}
 	return errors
 }
 
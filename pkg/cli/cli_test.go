@@ -2,9 +2,16 @@
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
 	"testing"
 
 	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore/testdata"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	providerutil "github.com/juandiegopalomino/cloudgrep/pkg/testingutil/provider"
 	"github.com/juandiegopalomino/cloudgrep/pkg/util/amplitude"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
@@ -26,3 +33,79 @@ func TestAmplitudeSent(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "LOAD", event["event_type"])
 }
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore.DataSourceName = filepath.Join(t.TempDir(), "cloudgrep.db")
+	cfg.Datastore.SkipRefresh = true
+
+	//write some resources as if they were scanned before
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+	run := model.NewEngineEventStart()
+	require.NoError(t, ds.WriteEvent(ctx, run))
+	require.NoError(t, ds.WriteResources(ctx, testdata.GetResources(t)))
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+	//no policy to check
+	_, err = Check(ctx, cfg, logger)
+	require.ErrorContains(t, err, "no tag policy to check")
+
+	//invalid policy
+	cfg.Policies = []config.Policy{{Name: "team"}}
+	_, err = Check(ctx, cfg, logger)
+	require.ErrorContains(t, err, "invalid tag policies")
+
+	cfg.Policies = []config.Policy{{Name: "team", Tags: []config.TagRule{{Key: "team", Required: true}}}}
+	report, err := Check(ctx, cfg, logger)
+	require.NoError(t, err)
+	require.Equal(t, run.RunId, report.RunId)
+	require.Equal(t, 3, report.ResourcesCount)
+	require.Equal(t, 2, report.CompliantCount)
+	require.Equal(t, 1, len(report.Violations()))
+	require.Equal(t, "a-bucket-123", report.Violations()[0].ResourceId)
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore.DataSourceName = filepath.Join(t.TempDir(), "cloudgrep.db")
+	cfg.Providers = []config.Provider{{Cloud: "fake-scan"}}
+	//the Bar resources of the 2nd provider can't be fetched
+	provider.RegisterExtraProviders("fake-scan", []provider.Provider{
+		&providerutil.FakeProvider{ID: "p1", RegionID: "us-east-1", Foo: providerutil.FakeProviderResourceConfig{Count: 2}},
+		&providerutil.FakeProvider{ID: "p2", RegionID: "us-west-2", Foo: providerutil.FakeProviderResourceConfig{Count: 1},
+			Bar: providerutil.FakeProviderResourceConfig{ErrorAfterCount: errors.New("access denied")}},
+	})
+
+	summary, err := Scan(ctx, cfg, logger)
+	require.NoError(t, err)
+	require.True(t, summary.Failed())
+	require.Equal(t, 3, summary.ResourcesCount)
+	require.Equal(t, 4, summary.FetchCount)
+	require.Equal(t, 1, summary.FailedCount)
+	require.Equal(t, []model.ScanCount{
+		{Name: "p1.Bar", FetchCount: 1},
+		{Name: "p1.Foo", ResourcesCount: 2, FetchCount: 1},
+		{Name: "p2.Bar", FetchCount: 1, FailedCount: 1},
+		{Name: "p2.Foo", ResourcesCount: 1, FetchCount: 1},
+	}, summary.Types)
+	require.Equal(t, []model.ScanCount{
+		{Name: "us-east-1", ResourcesCount: 2, FetchCount: 2},
+		{Name: "us-west-2", ResourcesCount: 1, FetchCount: 2, FailedCount: 1},
+	}, summary.Regions)
+	require.Equal(t, 1, len(summary.Failures))
+	require.Equal(t, "access denied", summary.Failures[0].Error)
+
+	//the resources are written to the datastore file
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+	stats, err := ds.Stats(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.ResourcesCount)
+}
@@ -4,7 +4,9 @@
 	_ "embed"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -23,6 +25,12 @@ type Config struct {
 	Datastore Datastore `yaml:"datastore"`
 	// Web is the web specs to be used
 	Web Web `yaml:"web"`
+	// Policies are the tag policies evaluated after each refresh
+	Policies []Policy `yaml:"policies"`
+	// Engine is the way the resources are fetched
+	Engine Engine `yaml:"engine"`
+	// IaC finds the resources managed by an Infrastructure as Code tool, the result is the core field managed_by
+	IaC IaC `yaml:"iac"`
 	// Adding regions as where cli regions override is stored
 	Regions []string
 	// Adding regions as where cli profiles override is stored
@@ -31,12 +39,165 @@ type Config struct {
 
 // Provider represents a cloud provider cloudgrep will scan w/ the current credentials
 type Provider struct {
-	// Cloud is the type of the cloud provider (currently only AWS is supported)
+	// Cloud is the type of the cloud provider: aws, gcp, azure, kubernetes or terraform
 	Cloud string `yaml:"cloud"`
 	// Regions is the list of different regions within the cloud provider to scan
 	Regions []string `yaml:"regions"`
 	// Profile is the AWS profile to use, if not set use the default profile
 	Profile string `yaml:"profile"`
+	// RateLimit limits the API calls of the provider
+	RateLimit RateLimit `yaml:"rateLimit"`
+	// AssumeRole scans other accounts by assuming a role in each of them
+	AssumeRole AssumeRole `yaml:"assumeRole"`
+	// Organization scans the accounts of the AWS organization, the RoleArnTemplate of AssumeRole is assumed in each of them
+	Organization Organization `yaml:"organization"`
+	// Endpoints overrides the endpoints of the AWS APIs, ex: to use LocalStack or VPC interface endpoints
+	Endpoints Endpoints `yaml:"endpoints"`
+	// GCP are the options of a gcp provider
+	GCP GCP `yaml:"gcp"`
+	// Azure are the options of an azure provider
+	Azure Azure `yaml:"azure"`
+	// Kubernetes are the options of a kubernetes provider
+	Kubernetes Kubernetes `yaml:"kubernetes"`
+	// Terraform are the options of a terraform provider
+	Terraform Terraform `yaml:"terraform"`
+}
+
+// GCP represents the options of a gcp provider
+type GCP struct {
+	// Projects are the ids of the GCP projects to scan, the default is the project of the credentials
+	Projects []string `yaml:"projects"`
+	// CredentialsFile is the path of a GCP service account key file, the application default credentials are used if not set
+	CredentialsFile string `yaml:"credentialsFile"`
+	// Endpoint is the endpoint of the Cloud Asset API, the default is https://cloudasset.googleapis.com
+	Endpoint string `yaml:"endpoint"`
+}
+
+// Azure represents the options of an azure provider
+type Azure struct {
+	// Subscriptions are the ids of the Azure subscriptions to scan
+	Subscriptions []string `yaml:"subscriptions"`
+	// ManagementGroups are the ids of the Azure management groups whose subscriptions are scanned
+	// All the subscriptions of the credentials are scanned if there is no subscription and no management group
+	ManagementGroups []string `yaml:"managementGroups"`
+	// TenantId is the Azure tenant of the service principal, the default is AZURE_TENANT_ID
+	TenantId string `yaml:"tenantId"`
+	// ClientId is the application id of the Azure service principal, the default is AZURE_CLIENT_ID
+	// The credentials of the Azure CLI are used if there is no client id
+	ClientId string `yaml:"clientId"`
+	// ClientSecret is the secret of the Azure service principal, the default is AZURE_CLIENT_SECRET
+	// The environment variables are expanded, ex: "${MY_CLIENT_SECRET}"
+	ClientSecret string `yaml:"clientSecret"`
+	// Endpoint is the endpoint of Azure Resource Manager, the default is https://management.azure.com
+	Endpoint string `yaml:"endpoint"`
+}
+
+// Kubernetes represents the options of a kubernetes provider, the API servers are the servers of the kubeconfig clusters
+type Kubernetes struct {
+	// Kubeconfig is the path of the kubeconfig, the default is KUBECONFIG or ~/.kube/config
+	Kubeconfig string `yaml:"kubeconfig"`
+	// Contexts are the kubeconfig contexts of the clusters to scan, the default is the current context
+	Contexts []string `yaml:"contexts"`
+	// Kinds are the kinds of the objects to list, as group/version/Kind, ex: apps/v1/Deployment, v1/Service
+	// The default kinds are the deployments, stateful sets, services, ingresses, persistent volume claims and nodes
+	Kinds []string `yaml:"kinds"`
+}
+
+// Terraform represents the options of a terraform provider
+type Terraform struct {
+	// States are the paths of the Terraform state files, the directories are searched recursively for .tfstate files
+	States []string `yaml:"states"`
+}
+
+// AssumeRole represents the IAM roles assumed to scan other accounts, the credentials of the profile are used to assume them
+type AssumeRole struct {
+	// RoleArns are the ARNs of the roles to assume, one per account
+	RoleArns []string `yaml:"roleArns"`
+	// RoleArnTemplate is the ARN of the role to assume in each of the Accounts, "{account}" is replaced by the account id
+	RoleArnTemplate string `yaml:"roleArnTemplate"`
+	// Accounts are the ids of the accounts where the RoleArnTemplate is assumed
+	Accounts []string `yaml:"accounts"`
+	// ExternalId is the external id required by the trust policy of the roles, if any
+	ExternalId string `yaml:"externalId"`
+	// SessionName is the name of the role sessions, the default is "cloudgrep"
+	SessionName string `yaml:"sessionName"`
+	// SourceProfile is the AWS profile used to assume the roles, the default is Profile
+	SourceProfile string `yaml:"sourceProfile"`
+}
+
+// Organization represents the accounts of the AWS organization to scan, the profile must be in the management account
+// The accounts can use a shell pattern, ex: "prod-*"
+type Organization struct {
+	// Enabled lists the active accounts of the organization, they replace the Accounts of AssumeRole
+	Enabled bool `yaml:"enabled"`
+	// OrganizationalUnits are the ids or the paths of the organizational units to scan, ex: "Root/Production", all the accounts if not set
+	OrganizationalUnits []string `yaml:"organizationalUnits"`
+	// IncludeAccounts are the ids or names of the accounts to scan, all the accounts if not set
+	IncludeAccounts []string `yaml:"includeAccounts"`
+	// ExcludeAccounts are the ids or names of the accounts to skip
+	ExcludeAccounts []string `yaml:"excludeAccounts"`
+}
+
+// Endpoints represents the endpoints used instead of the default AWS endpoints
+type Endpoints struct {
+	// URL is the endpoint of all the services, ex: http://localhost:4566
+	URL string `yaml:"url"`
+	// Services are the endpoints of some services, they take precedence over URL
+	// The key is the service id, case insensitive and without spaces, ex: ec2, s3, elasticloadbalancingv2
+	Services map[string]string `yaml:"services"`
+	// S3UsePathStyle puts the bucket name in the path of the S3 requests instead of the host name
+	S3UsePathStyle bool `yaml:"s3UsePathStyle"`
+	// InsecureSkipVerify disables the verification of the TLS certificates, only use it for testing
+	InsecureSkipVerify bool `yaml:"insecureSkipVerify"`
+	// CABundle is the path of the PEM file of the certificate authorities trusted in addition to the system ones
+	CABundle string `yaml:"caBundle"`
+}
+
+// RateLimit represents the limits on the API calls of a provider, for each service in each region of an account
+// The rate is reduced when the API calls are throttled, then increased again after successful calls
+type RateLimit struct {
+	// RequestsPerSecond is the maximum rate of the API calls, 0 means no limit until the API calls are throttled
+	RequestsPerSecond float64 `yaml:"requestsPerSecond"`
+	// Burst is the number of API calls which can be made at once, the default is the rate
+	Burst int `yaml:"burst"`
+	// MaxAttempts is the maximum number of attempts of an API call, including the first one
+	MaxAttempts int `yaml:"maxAttempts"`
+	// MaxBackoff is the maximum delay before retrying an API call
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+}
+
+// Validate checks that the options of the provider are the options of its cloud
+func (p Provider) Validate() error {
+	sections := []struct {
+		cloud   string
+		name    string
+		options interface{}
+	}{
+		{cloud: "aws", name: "assumeRole", options: p.AssumeRole},
+		{cloud: "aws", name: "organization", options: p.Organization},
+		{cloud: "aws", name: "endpoints", options: p.Endpoints},
+		{cloud: "gcp", name: "gcp", options: p.GCP},
+		{cloud: "azure", name: "azure", options: p.Azure},
+		{cloud: "kubernetes", name: "kubernetes", options: p.Kubernetes},
+		{cloud: "terraform", name: "terraform", options: p.Terraform},
+	}
+	for _, section := range sections {
+		if section.cloud != p.Cloud && !reflect.ValueOf(section.options).IsZero() {
+			return fmt.Errorf("%v can only be set for the %v cloud, not %v", section.name, section.cloud, p.Cloud)
+		}
+	}
+	return nil
+}
+
+// Locations returns the regions of a provider filtering the locations of the resources it fetches, ex: gcp
+// nil keeps all the locations, like the region "all"
+func (p Provider) Locations() []string {
+	for _, region := range p.Regions {
+		if region == "all" {
+			return nil
+		}
+	}
+	return p.Regions
 }
 
 func (p *Provider) String() string {
@@ -48,12 +209,107 @@ func (p *Provider) String() string {
 
 // Datastore represents the specs cloudgrep uses for creating and/or connecting to the datastore/database used.
 type Datastore struct {
-	// Type is the kind of datastore to be used by cloudgrep (currently only supports SQLite)
+	// Type is the kind of datastore to be used by cloudgrep: sqlite or postgres
 	Type string `yaml:"type"`
 	// SkipRefresh determines whether to refresh the data (i.e. scan the cloud) on startup.
 	SkipRefresh bool `yaml:"skipRefresh"`
-	// DataSourceName is the Type-specific data source name or uri for connecting to the desired data source
+	// RefreshInterval refreshes the data periodically while the web server is running, the refresh is disabled if not set.
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+	// RefreshSchedule refreshes the data at the times of a cron expression, ex: "0 */6 * * *", it can't be set with RefreshInterval.
+	RefreshSchedule string `yaml:"refreshSchedule"`
+	// ReadOnly opens the datastore without writing to it, the data is not refreshed and the schema is not migrated.
+	ReadOnly bool `yaml:"readOnly"`
+	// DataSourceName is the Type-specific data source name or uri for connecting to the desired data source.
+	// The environment variables are expanded, ex: "host=localhost user=cloudgrep password=${PGPASSWORD} dbname=cloudgrep"
 	DataSourceName string `yaml:"dataSourceName"`
+	// History keeps the previous versions of the resources
+	History History `yaml:"history"`
+}
+
+// History represents the specs for keeping the resources of the previous runs, they can be queried with "asOf"
+type History struct {
+	// Retention is how long the history is kept, the history is disabled if not set
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Policy represents the tags expected on some resources
+type Policy struct {
+	// Name identifies the policy in the compliance report
+	Name string `yaml:"name"`
+	// Description explains the purpose of the policy
+	Description string `yaml:"description"`
+	// Scope selects the resources the policy applies to
+	Scope PolicyScope `yaml:"scope"`
+	// Tags are the rules for each tag key
+	Tags []TagRule `yaml:"tags"`
+}
+
+// PolicyScope selects the resources a policy applies to, an empty list matches all the resources
+// The values can use a shell pattern, ex: "ec2.*"
+type PolicyScope struct {
+	// Types are the resource types, ex: ec2.Instance
+	Types []string `yaml:"types"`
+	// Accounts are the account ids
+	Accounts []string `yaml:"accounts"`
+	// Regions are the regions
+	Regions []string `yaml:"regions"`
+}
+
+// TagRule represents the constraints on a tag
+type TagRule struct {
+	// Key is the tag key
+	Key string `yaml:"key"`
+	// Required determines whether the tag must be defined, the other rules only apply if the tag is defined
+	Required bool `yaml:"required"`
+	// Values are the allowed values
+	Values []string `yaml:"values"`
+	// Pattern is a regular expression the value must match
+	Pattern string `yaml:"pattern"`
+	// Case is the case of the value: lower or upper
+	Case string `yaml:"case"`
+}
+
+// IaC represents the sources used to find the resources managed by an IaC tool
+// The resources with the tag aws:cloudformation:stack-name are managed by CloudFormation
+type IaC struct {
+	// TerraformStates are the paths of the Terraform state files, the directories are searched recursively for .tfstate files
+	// A resource is managed by Terraform if its id or its ARN is in a state, the states are read again at each refresh
+	TerraformStates []string `yaml:"terraformStates"`
+}
+
+// Engine represents the specs cloudgrep uses for fetching the resources
+type Engine struct {
+	// Concurrency limits the number of resource types fetched at the same time
+	Concurrency Concurrency `yaml:"concurrency"`
+	// BatchSize is the number of resources written to the datastore at once while fetching
+	BatchSize int `yaml:"batchSize"`
+	// FlushInterval is the maximum time the fetched resources are kept before being written to the datastore
+	FlushInterval time.Duration `yaml:"flushInterval"`
+	// Timeouts stops the fetches taking too long, the resources fetched before the timeout are kept
+	Timeouts Timeouts `yaml:"timeouts"`
+}
+
+// Timeouts represents the maximum durations of the fetches, a timeout of 0 means no timeout
+type Timeouts struct {
+	// Run is the maximum duration of a refresh, the fetches still running are stopped
+	Run time.Duration `yaml:"run"`
+	// Fetch is the maximum duration of the fetch of a resource type, for a provider
+	Fetch time.Duration `yaml:"fetch"`
+	// Types overrides the fetch timeout of some resource types, the type can be a shell pattern, ex: {"s3.*": 10m}
+	Types map[string]time.Duration `yaml:"types"`
+}
+
+// Concurrency represents the limits on the fetches running at the same time, a limit of 0 means no limit
+type Concurrency struct {
+	// Workers is the maximum number of fetches for all the providers
+	Workers int `yaml:"workers"`
+	// PerProvider is the maximum number of fetches for a provider, for AWS it is an account in a region
+	PerProvider int `yaml:"perProvider"`
+	// PerRegion is the maximum number of fetches for a region, across all the accounts
+	PerRegion int `yaml:"perRegion"`
+	// PerService is the maximum number of fetches for a service in an account and a region, ex: {ec2: 2}
+	// The key "*" sets the limit of the services not listed
+	PerService map[string]int `yaml:"perService"`
 }
 
 // Web represents the specs cloudgrep uses for creating the webapp server
@@ -108,29 +364,40 @@ func (c *Config) Load() error {
 	return err
 }
 
-//loadRegions will replace the regions with the --region option if set
+//loadRegions will replace the regions of the AWS providers with the --region option if set
+//the regions of the other clouds are locations with other names, they are kept
 func (c *Config) loadRegions() error {
 	if len(c.Regions) == 0 {
 		return nil
 	}
 	providers := make([]Provider, 0)
 	for _, provider := range c.Providers {
-		provider.Regions = c.Regions
+		if provider.Cloud == "aws" {
+			provider.Regions = c.Regions
+		}
 		providers = append(providers, provider)
 	}
 	c.Providers = providers
 	return nil
 }
 
-//loadProfiles will load a provider for each profile if set
+//loadProfiles will load an AWS provider for each profile if set, the providers of the other clouds are kept once
 func (c *Config) loadProfiles() error {
 	if len(c.Profiles) == 0 {
 		return nil
 	}
 	//create the provider for each profile
 	providers := make([]Provider, 0)
+	for _, provider := range c.Providers {
+		if provider.Cloud != "aws" {
+			providers = append(providers, provider)
+		}
+	}
 	for _, profile := range c.Profiles {
 		for _, provider := range c.Providers {
+			if provider.Cloud != "aws" {
+				continue
+			}
 			if provider.Profile != "" {
 				return fmt.Errorf("the config file already defines a profile, using the option `--profiles` is not supported")
 			}
@@ -62,6 +62,22 @@ func TestProfiles(t *testing.T) {
 
 }
 
+func TestPolicies(t *testing.T) {
+	config, err := ReadFile("test/policies-config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, []Policy{
+		{
+			Name:        "team-tag",
+			Description: "the EC2 resources must have an owner",
+			Scope:       PolicyScope{Types: []string{"ec2.*"}, Regions: []string{"us-east-1"}},
+			Tags: []TagRule{
+				{Key: "team", Required: true, Values: []string{"infra", "dev"}},
+				{Key: "env", Pattern: "^(dev|prod)$", Case: "lower"},
+			},
+		},
+	}, config.Policies)
+}
+
 func TestRegions(t *testing.T) {
 	// Manual regions trumps any written region.
 	config, err := ReadFile("test/multi-region-config.yaml")
@@ -74,3 +90,67 @@ func TestRegions(t *testing.T) {
 	require.Equal(t, optionRegions, config.Providers[0].Regions)
 
 }
+
+func TestRegionsOtherClouds(t *testing.T) {
+	//the regions and the profiles options only apply to the AWS providers
+	config, err := ReadFile("test/providers-config.yaml")
+	require.NoError(t, err)
+	config.Regions = []string{"all"}
+	config.Profiles = []string{"dev", "prod"}
+	require.NoError(t, config.Load())
+	require.Equal(t, 6, len(config.Providers))
+	gcp := config.Providers[0]
+	require.Equal(t, "gcp", gcp.Cloud)
+	require.Equal(t, []string{"us-central1"}, gcp.Regions)
+	require.Equal(t, "", gcp.Profile)
+	var profiles []string
+	for _, provider := range config.Providers {
+		if provider.Cloud == "aws" {
+			require.Equal(t, []string{"all"}, provider.Regions)
+			profiles = append(profiles, provider.Profile)
+		}
+	}
+	require.Equal(t, []string{"dev", "prod"}, profiles)
+	azure := config.Providers[1]
+	require.Equal(t, "azure", azure.Cloud)
+	require.Equal(t, []string{"westeurope"}, azure.Regions)
+	require.Equal(t, "", azure.Profile)
+
+	//"all" keeps all the locations
+	require.Equal(t, []string{"us-central1"}, gcp.Locations())
+	gcp.Regions = []string{"all"}
+	require.Empty(t, gcp.Locations())
+	azure.Regions = []string{"westeurope", "all"}
+	require.Empty(t, azure.Locations())
+}
+
+func TestProviderValidate(t *testing.T) {
+	config, err := ReadFile("test/providers-config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, 5, len(config.Providers))
+	gcp := config.Providers[1]
+	require.Equal(t, []string{"my-project"}, gcp.GCP.Projects)
+	require.Equal(t, "http://localhost:8081", gcp.GCP.Endpoint)
+	require.Equal(t, []string{"00000000-0000-0000-0000-000000000000"}, config.Providers[2].Azure.Subscriptions)
+	require.Equal(t, []string{"prod"}, config.Providers[3].Kubernetes.Contexts)
+	require.Equal(t, []string{"terraform.tfstate"}, config.Providers[4].Terraform.States)
+	for _, provider := range config.Providers {
+		require.NoError(t, provider.Validate())
+	}
+
+	//the options of another cloud can't be set
+	aws := config.Providers[0]
+	aws.GCP = gcp.GCP
+	require.EqualError(t, aws.Validate(), "gcp can only be set for the gcp cloud, not aws")
+	gcp.AssumeRole.Accounts = []string{"123456789012"}
+	require.EqualError(t, gcp.Validate(), "assumeRole can only be set for the aws cloud, not gcp")
+	azure := config.Providers[2]
+	azure.Endpoints.URL = "http://localhost:4566"
+	require.EqualError(t, azure.Validate(), "endpoints can only be set for the aws cloud, not azure")
+	azure.Endpoints.URL = ""
+	azure.Kubernetes = config.Providers[3].Kubernetes
+	require.EqualError(t, azure.Validate(), "kubernetes can only be set for the kubernetes cloud, not azure")
+	terraform := config.Providers[4]
+	terraform.Azure = config.Providers[2].Azure
+	require.EqualError(t, terraform.Validate(), "azure can only be set for the azure cloud, not terraform")
+}
@@ -0,0 +1,56 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+//complianceReport stores the latest compliance report, the report is only read as a whole
+type complianceReport struct {
+	Id        int64 `gorm:"primaryKey;autoIncrement"`
+	RunId     string
+	CreatedAt time.Time
+	Report    datatypes.JSON
+}
+
+func (s *sqlStore) WriteComplianceReport(ctx context.Context, report model.ComplianceReport) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("can't write the compliance report: %w", err)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		//only keep the latest report
+		if err := tx.Where("1 = 1").Delete(&complianceReport{}).Error; err != nil {
+			return fmt.Errorf("can't delete the compliance reports: %w", err)
+		}
+		if err := tx.Create(&complianceReport{RunId: report.RunId, CreatedAt: report.CreatedAt, Report: data}).Error; err != nil {
+			return fmt.Errorf("can't write the compliance report: %w", err)
+		}
+		return nil
+	})
+}
+
+//GetComplianceReport returns the latest compliance report, nil if there is none
+func (s *sqlStore) GetComplianceReport(ctx context.Context) (*model.ComplianceReport, error) {
+	var reports []complianceReport
+	if err := s.db.Order("id desc").Limit(1).Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("can't get the compliance report: %w", err)
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	var report model.ComplianceReport
+	if err := json.Unmarshal(reports[0].Report, &report); err != nil {
+		return nil, fmt.Errorf("can't read the compliance report: %w", err)
+	}
+	return &report, nil
+}
@@ -13,10 +13,15 @@
 type Datastore interface {
 	GetResource(context.Context, string) (*model.Resource, error)
 	GetResources(context.Context, []byte) (model.ResourcesResponse, error)
+	GetAllResources(context.Context, []byte) (model.Resources, error)
+	GetManagedSummary(context.Context, []byte) (model.ManagedSummary, error)
 	WriteResources(context.Context, model.Resources) error
 	Stats(context.Context) (model.Stats, error)
 	WriteEvent(context.Context, model.Event) error
 	EngineStatus(ctx context.Context) (model.Event, error)
+	GetRuns(ctx context.Context) (model.Events, error)
+	WriteComplianceReport(context.Context, model.ComplianceReport) error
+	GetComplianceReport(context.Context) (*model.ComplianceReport, error)
 	Ping() error
 }
 
@@ -25,6 +30,8 @@ func NewDatastore(ctx context.Context, cfg config.Config, logger *zap.Logger) (D
 	switch cfg.Datastore.Type {
 	case "sqlite":
 		return NewSQLiteStore(ctx, cfg, logger)
+	case "postgres":
+		return NewPostgresStore(ctx, cfg, logger)
 	}
 	return nil, fmt.Errorf("unknown datastore type '%v'", cfg.Datastore.Type)
 }
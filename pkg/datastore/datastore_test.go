@@ -7,6 +7,8 @@
 	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -19,12 +21,18 @@
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 //this is the max size allowed for an AWS tag
 const tagMaxKey = "service.k8s.aws/stack-XVlBzgbaiCMRAjWwhTHctcuAxhxKQFDaFpLSjFbcXoEFfRsWxPLDnJObCsNVlgTeMaPEZQleQYhYzRyWJjPjzpfRFEgmotaFetHsbZRjxAwnwekrBEmfdzdcEkXBAkjQZLCtTMtTCoaNatyyiNKAReKJyiXJrscctNswYNsGRussVmaozFZBsbOJiFQGZsnwTKSmVoiGLOpbUOpEdKupdOMeRVjaRzL-----END"
 const tagMaxValue = "ingress-nginx/ingress-nginx-controllerLDnJObCsNVlgTeMaPEZQleQYhYzRyWJjPjzpfRFEgmotaFetHsbZRjxAwnwekrBEEdKupdOMeRVjaRzL-----END"
 
+//the environment variable with the DSN of the Postgres database used for testing
+const postgresTestDSN = "CLOUDGREP_TEST_POSTGRES_DSN"
+
 //only one resource has this tag
 const tagUniqueResourceId = "i-123"
 const tagUniqueKey = "unique-tag"
@@ -39,8 +47,18 @@ func newDatastores(t *testing.T, ctx context.Context) ([]Datastore, []config.Con
 		{
 			Type:           "sqlite",
 			DataSourceName: dbFilePath,
+			History:        config.History{Retention: time.Hour},
 		},
 	}
+	//the postgres tests are only run if a database is provided, ex: "host=localhost user=postgres dbname=cloudgrep_test"
+	if dsn := os.Getenv(postgresTestDSN); dsn != "" {
+		resetPostgres(t, dsn)
+		datastoreConfigs = append(datastoreConfigs, config.Datastore{
+			Type:           "postgres",
+			DataSourceName: dsn,
+			History:        config.History{Retention: time.Hour},
+		})
+	}
 	var datastores []Datastore
 	var configs []config.Config
 	for _, datastoreConfig := range datastoreConfigs {
@@ -55,6 +73,17 @@ func newDatastores(t *testing.T, ctx context.Context) ([]Datastore, []config.Con
 	return datastores, configs
 }
 
+//resetPostgres drops the tables to start with an empty database
+func resetPostgres(t *testing.T, dsn string) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Migrator().DropTable(&model.Resource{}, &model.Tag{}, &model.Property{}, &model.Event{},
+		&resourceVersion{}, &tagVersion{}, &propertyVersion{}, &complianceReport{}))
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+}
+
 func TestReadWrite(t *testing.T) {
 	ctx := context.Background()
 	datastores, _ := newDatastores(t, ctx)
@@ -342,6 +371,69 @@ func TestSearchByQuery(t *testing.T) {
 		})
 	}
 }
+func TestSearchByProperties(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, datastore := range datastores {
+		name := fmt.Sprintf("%T", datastore)
+		t.Run(name, func(t *testing.T) {
+
+			all_resources := testdata.GetResources(t)
+			resourceInst1 := all_resources[0]  //i-123 InstanceType:t3.small, Tags[*].key release
+			resourceInst2 := all_resources[1]  //i-124 InstanceType:t3.small
+			resourceBucket := all_resources[2] //s3 bucket, no InstanceType
+
+			require.NoError(t, datastore.WriteResources(ctx, all_resources))
+
+			//filter on a property
+			testQuery(t, ctx, datastore, "properties.InstanceType", "t3.small", resourceInst1, resourceInst2)
+			testQuery(t, ctx, datastore, "properties.InstanceId", "i-124", resourceInst2)
+			testQueryNoResult(t, ctx, datastore, "properties.InstanceType", "t3.large")
+			//numbers are stored as text
+			testQuery(t, ctx, datastore, "properties.AmiLaunchIndex", "0", resourceInst1, resourceInst2)
+			//filter on a value in an array
+			testQuery(t, ctx, datastore, "properties.Tags[*].key", "release", resourceInst1)
+			testQuery(t, ctx, datastore, "properties.Tags[*].value", "vpc-123", resourceInst1, resourceInst2)
+			//unknown property: no resource has it
+			testQueryNoResult(t, ctx, datastore, "properties.NotAProperty", "foo")
+			testQuery(t, ctx, datastore, "properties.NotAProperty", model.FieldMissing, resourceInst1, resourceInst2, resourceBucket)
+			//unknown core field
+			testQueryUnrecognizedKey(t, ctx, datastore, "core.not_a_field", "foo")
+
+			//missing and not null
+			testQuery(t, ctx, datastore, "properties.InstanceType", model.FieldMissing, resourceBucket)
+			testQuery(t, ctx, datastore, "properties.Name", model.FieldPresent, resourceBucket)
+
+			//mix core, tags and properties with some operators
+			query := `{
+			  "filter":{
+			    "core.type": "test.Instance",
+			    "$or":[
+			      { "properties.InstanceId": { "$like": "%-123" } },
+			      { "tags.team": "not-found" }
+			    ],
+			    "properties.Tags[*].value": { "$neq": "v2" }
+			  }
+			}`
+			resourcesRead, err := datastore.GetResources(ctx, []byte(query))
+			require.NoError(t, err)
+			require.Equal(t, 1, resourcesRead.Count)
+			testingutil.AssertEqualsResources(t, model.Resources{resourceInst1}, resourcesRead.Resources)
+
+			//sort on a property - the resources without the property are first
+			query = `{
+			  "sort": ["-properties.InstanceId"]
+			}`
+			resourcesRead, err = datastore.GetResources(ctx, []byte(query))
+			require.NoError(t, err)
+			require.Equal(t, 3, len(resourcesRead.Resources))
+			require.Equal(t, resourceInst2.Id, resourcesRead.Resources[0].Id)
+			require.Equal(t, resourceInst1.Id, resourcesRead.Resources[1].Id)
+			require.Equal(t, resourceBucket.Id, resourcesRead.Resources[2].Id)
+		})
+	}
+}
+
 func TestStats(t *testing.T) {
 	ctx := context.Background()
 	datastores, _ := newDatastores(t, ctx)
@@ -376,10 +468,11 @@ func TestFields(t *testing.T) {
 			fields := resourceResp.FieldGroups
 			assert.NoError(t, err)
 			//check number of groups
-			assert.Equal(t, 2, len(fields))
+			assert.Equal(t, 3, len(fields))
 			//check fields by group
 			assert.Equal(t, 3, len(fields.FindGroup("core").Fields))
 			assert.Equal(t, 10, len(fields.FindGroup("tags").Fields))
+			assert.Equal(t, 11, len(fields.FindGroup("properties").Fields))
 
 			//test a few fields
 			testingutil.AssertEqualsField(t, model.Field{
@@ -440,6 +533,21 @@ func TestFields(t *testing.T) {
 					&model.FieldValue{Value: "(missing)", Count: "2"},
 				}}, *fields.FindField("tags", "region"))
 
+			//test a property field
+			testingutil.AssertEqualsField(t, model.Field{
+				Name:  "InstanceType",
+				Count: 2,
+				Values: model.FieldValues{
+					&model.FieldValue{Value: "t3.small", Count: "2"},
+					&model.FieldValue{Value: "(missing)", Count: "1"},
+				}}, *fields.FindField("properties", "InstanceType"))
+
+			//test a property field in an array: the count is the number of resources
+			tagKeyField := *fields.FindField("properties", "Tags[*].key")
+			assert.Equal(t, 2, tagKeyField.Count)
+			assert.Equal(t, "2", tagKeyField.Values.Find("team").Count)
+			assert.Equal(t, "1", tagKeyField.Values.Find("release").Count)
+
 			//test that the fields count are updated when sending a filter
 			//only one resource has enabled=false
 			query := `{
@@ -453,9 +561,10 @@ func TestFields(t *testing.T) {
 			fields = resourceResp.FieldGroups
 
 			//check all groups and tags are returned
-			assert.Equal(t, 2, len(fields))
+			assert.Equal(t, 3, len(fields))
 			assert.Equal(t, 3, len(fields.FindGroup("core").Fields))
 			assert.Equal(t, 10, len(fields.FindGroup("tags").Fields))
+			assert.Equal(t, 11, len(fields.FindGroup("properties").Fields))
 
 			//check the values were updated
 			testingutil.AssertEqualsField(t, model.Field{
@@ -481,6 +590,15 @@ func TestFields(t *testing.T) {
 					&model.FieldValue{Value: "false", Count: "1"},
 				}}, *fields.FindField("tags", "enabled"))
 
+			//check the property count was updated
+			testingutil.AssertEqualsField(t, model.Field{
+				Name:  "InstanceId",
+				Count: 1,
+				Values: model.FieldValues{
+					&model.FieldValue{Value: "i-123", Count: "-"},
+					&model.FieldValue{Value: "i-124", Count: "1"},
+				}}, *fields.FindField("properties", "InstanceId"))
+
 			//check a tag that is not relevant is still showing with a 0 count, and a (missing) value
 			testingutil.AssertEqualsField(t, model.Field{
 				Name:  "unique-tag",
@@ -494,6 +612,280 @@ func TestFields(t *testing.T) {
 	}
 }
 
+//test that the name and the organizational unit of the account are core fields when they are set
+func TestOrganizationFields(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			r1, r2 := resources[0], resources[1]
+			r1.AccountName, r1.OrganizationalUnit = "prod-web", "Root/Production/Web"
+			r2.AccountName, r2.OrganizationalUnit = "management", "Root"
+			run := model.NewEngineEventStart()
+			require.NoError(t, ds.WriteEvent(ctx, run))
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			r, err := ds.GetResource(ctx, r1.Id)
+			require.NoError(t, err)
+			require.Equal(t, "prod-web", r.AccountName)
+			require.Equal(t, "Root/Production/Web", r.OrganizationalUnit)
+
+			response, err := ds.GetResources(ctx, []byte(`{"filter":{"core.organizational_unit":"Root/Production/Web"}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+
+			response, err = ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			require.Equal(t, 5, len(response.FieldGroups.FindGroup("core").Fields))
+			testingutil.AssertEqualsField(t, model.Field{
+				Name:  "account_name",
+				Count: 3,
+				Values: model.FieldValues{
+					&model.FieldValue{Value: "prod-web", Count: "1"},
+					&model.FieldValue{Value: "management", Count: "1"},
+					&model.FieldValue{Value: "", Count: "1"},
+				}}, *response.FieldGroups.FindField("core", "account_name"))
+
+			//the fields are kept in the history
+			response, err = ds.GetResources(ctx, []byte(fmt.Sprintf(`{"asOf":"%v","filter":{"core.account_name":"management"}}`, run.RunId)))
+			require.NoError(t, err)
+			require.Equal(t, 1, len(response.Resources))
+			require.Equal(t, "Root", response.Resources[0].OrganizationalUnit)
+		})
+	}
+}
+
+//test that the namespace is a core field when it is set
+func TestNamespaceField(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			r1 := resources[0]
+			r1.Namespace = "default"
+			run := model.NewEngineEventStart()
+			require.NoError(t, ds.WriteEvent(ctx, run))
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			response, err := ds.GetResources(ctx, []byte(`{"filter":{"core.namespace":"default"}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+			require.Equal(t, 4, len(response.FieldGroups.FindGroup("core").Fields))
+
+			//the namespace is kept in the history
+			response, err = ds.GetResources(ctx, []byte(fmt.Sprintf(`{"asOf":"%v","filter":{"core.namespace":"default"}}`, run.RunId)))
+			require.NoError(t, err)
+			require.Equal(t, 1, len(response.Resources))
+			require.Equal(t, "default", response.Resources[0].Namespace)
+		})
+	}
+}
+
+//test that the Terraform module is a core field when it is set
+func TestModuleField(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			r1, r2 := resources[0], resources[1]
+			r1.Module, r2.Module = "module.vpc", "module.vpc"
+			require.NoError(t, ds.WriteResources(ctx, resources))
+
+			response, err := ds.GetResources(ctx, []byte(`{"filter":{"core.module":"module.vpc"}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1, r2}, response.Resources)
+			response, err = ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			field := response.FieldGroups.FindField("core", "module")
+			require.NotNil(t, field)
+			require.Equal(t, "module.vpc", field.Values[0].Value)
+			require.Equal(t, "2", field.Values[0].Count)
+		})
+	}
+}
+
+//test that the tool managing the resources is a core field when it is set
+func TestManagedByField(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			for _, r := range resources {
+				r.ManagedBy = model.ManagedByNone
+			}
+			r1 := resources[0]
+			r1.ManagedBy = model.ManagedByTerraform
+			require.NoError(t, ds.WriteResources(ctx, resources))
+
+			response, err := ds.GetResources(ctx, []byte(`{"filter":{"core.managed_by":"terraform"}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+			response, err = ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			field := response.FieldGroups.FindField("core", "managed_by")
+			require.NotNil(t, field)
+			require.Equal(t, len(resources), field.Count)
+		})
+	}
+}
+
+//test that the tools managing the resources are counted by type and by account
+func TestGetManagedSummary(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			for _, r := range resources {
+				r.ManagedBy = model.ManagedByNone
+			}
+			r1, r2 := resources[0], resources[1]
+			r1.ManagedBy = model.ManagedByTerraform
+			//written before the managed_by field was added
+			r2.ManagedBy = ""
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			//the declaration of r1 in a Terraform state is not counted
+			declared := *r1
+			declared.Id = model.TerraformStateIdPrefix + "/live/terraform.tfstate/aws_instance.web"
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{&declared}))
+
+			summary, err := ds.GetManagedSummary(ctx, nil)
+			require.NoError(t, err)
+			assert.Equal(t, len(resources), summary.Total.ResourcesCount)
+			assert.Equal(t, 1, summary.Total.TerraformCount)
+			assert.Equal(t, len(resources)-1, summary.Total.UnmanagedCount)
+			var typesCount int
+			for _, c := range summary.Types {
+				typesCount += c.ResourcesCount
+			}
+			assert.Equal(t, len(resources), typesCount)
+
+			query := fmt.Sprintf(`{"filter":{"core.type":"%v"}}`, r1.Type)
+			summary, err = ds.GetManagedSummary(ctx, []byte(query))
+			require.NoError(t, err)
+			require.Len(t, summary.Types, 1)
+			assert.Equal(t, r1.Type, summary.Types[0].Name)
+			assert.Equal(t, 1, summary.Types[0].TerraformCount)
+			require.Len(t, summary.Accounts, 1)
+			assert.Equal(t, r1.AccountId, summary.Accounts[0].Name)
+
+			_, err = ds.GetManagedSummary(ctx, []byte(`{"filter":{"core.unknown":"x"}}`))
+			assert.Error(t, err)
+		})
+	}
+}
+
+//test that the property values are compared as numbers when the operand is a number
+func TestPropertyNumericComparison(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)[:3]
+			r1, r2, r3 := resources[0], resources[1], resources[2]
+			r1.RawData = []byte(`{"Size": 9}`)
+			r2.RawData = []byte(`{"Size": 10}`)
+			r3.RawData = []byte(`{"Size": "large"}`)
+			//the values made of number characters which are not numbers
+			for i, size := range []string{".", "e", "+-", "1e", "1.2.3", "-", "1e+", "e5", "1-2", ""} {
+				r := *r3
+				r.Id = fmt.Sprintf("%v-%v", r3.Id, i)
+				r.RawData = []byte(fmt.Sprintf(`{"Size": %q}`, size))
+				resources = append(resources, &r)
+			}
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			query := func(filter string, expected ...*model.Resource) {
+				response, err := ds.GetResources(ctx, []byte(fmt.Sprintf(`{"filter":{"properties.Size":%v}}`, filter)))
+				require.NoError(t, err)
+				testingutil.AssertEqualsResources(t, model.Resources(expected), response.Resources)
+			}
+			//"10" is lower than "9" as text, the values which are not numbers are skipped
+			query(`{"$gt":"9"}`, r2)
+			query(`{"$lte":"10"}`, r1, r2)
+			query(`{"$lt":"9.5", "$gte":"-1e3"}`, r1)
+			//SQLite converts the values made of number characters to 0, they are skipped too
+			query(`{"$gte":"-1", "$lte":"1"}`)
+			//the operand is not a number, the values are compared as text
+			query(`{"$gt":"f"}`, r3)
+			query(`"10"`, r2)
+		})
+	}
+}
+
+//test that a resource with a long property value is written, the value is truncated in the properties but kept in the raw data
+//the size of an index entry is limited on Postgres, set CLOUDGREP_TEST_POSTGRES_DSN to run this test on it
+func TestPropertyLongValue(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			r1 := testdata.GetResources(t)[0]
+			userData := strings.Repeat(`#!/bin/bash\n`, 1000)
+			r1.RawData = []byte(fmt.Sprintf(`{"UserData": "%v", "Certificates": ["%v", "%v"]}`, userData, userData+"a", userData+"b"))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1}))
+			var maxLength int
+			require.NoError(t, storeDB(ds).Table(propertiesTable).Select("max(length(value))").Scan(&maxLength).Error)
+			assert.Equal(t, 1024, maxLength)
+
+			response, err := ds.GetResources(ctx, []byte(`{"filter":{"properties.UserData":{"$like":"#!/bin/bash%"}}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+			response, err = ds.GetResources(ctx, []byte(`{"filter":{"properties.Certificates[*]":{"$like":"#!/bin/bash%"}}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+		})
+	}
+}
+
+//test that the property fields are computed once per run, the properties written during a run are fields once it ends
+func TestPropertyFieldsCache(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)[:2]
+			r1, r2 := resources[0], resources[1]
+			r1.RawData = []byte(`{"Size": "small"}`)
+			r2.RawData = []byte(`{"Color": "red"}`)
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1}))
+			response, err := ds.GetResources(ctx, []byte(`{}`))
+			require.NoError(t, err)
+			assert.NotNil(t, response.FieldGroups.FindField("properties", "Size"))
+
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r2}))
+			response, err = ds.GetResources(ctx, []byte(`{}`))
+			require.NoError(t, err)
+			assert.Nil(t, response.FieldGroups.FindField("properties", "Color"))
+			//the property can be queried before it is a field
+			response, err = ds.GetResources(ctx, []byte(`{"filter":{"properties.Color":"red"}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r2}, response.Resources)
+
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+			response, err = ds.GetResources(ctx, []byte(`{}`))
+			require.NoError(t, err)
+			assert.Equal(t, 2, response.Count)
+			assert.NotNil(t, response.FieldGroups.FindField("properties", "Size"))
+			assert.NotNil(t, response.FieldGroups.FindField("properties", "Color"))
+		})
+	}
+}
+
 //test that the resources can be updated: update their properties, tags
 func TestUpdateResources(t *testing.T) {
 	ctx := context.Background()
@@ -523,6 +915,11 @@ func TestUpdateResources(t *testing.T) {
 			require.Equal(t, r1.Region, "us-west-2")
 			require.Greater(t, r1.UpdatedAt, lastUpdatedAt)
 
+			//the last updated resource is first
+			resourcesRead, err := ds.GetResources(ctx, []byte(`{"sort":["-core.updated_at"]}`))
+			require.NoError(t, err)
+			require.Equal(t, r1.Id, resourcesRead.Resources[0].Id)
+
 			//test updating some tags
 			r1UniqueTag := model.Tag{Key: tagUniqueKey, Value: tagUniqueValue}
 			deletedTag := r1UniqueTag
@@ -541,20 +938,384 @@ func TestUpdateResources(t *testing.T) {
 			testQuery(t, ctx, ds, newTag.Key, newTag.Value, r1)
 			//test old tag is deleted
 			testingutil.AssertEqualsTag(t, nil, r1.Tags.Find(deletedTag.Key))
-			//searching on deleting tag returns nothing
 			testQueryNoResult(t, ctx, ds, deletedTag.Key, deletedTag.Value)
 
 			//send 2 resources with same id
 			r1DuplicateId, err := ds.GetResource(ctx, resources[1].Id)
 			require.NoError(t, err)
 			r1DuplicateId.Id = r1.Id
-			//only 1 resource would be written without throwing an error
+			//only 1 resource would be written without throwing an error, the first one is kept
 			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1, r1DuplicateId}))
+			written, err := ds.GetResource(ctx, r1.Id)
+			require.NoError(t, err)
+			testingutil.AssertEqualsResource(t, *r1, *written)
 
 		})
 	}
 }
 
+//test that there is no limit on the number of tag keys
+func TestManyTagKeys(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			r1 := resources[0]
+			//more keys than the maximum number of columns for SQLite
+			for i := 0; i < 2500; i++ {
+				r1.Tags = append(r1.Tags, model.Tag{Key: fmt.Sprintf("key-%d", i), Value: fmt.Sprintf("value-%d", i)})
+			}
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			r1, err := ds.GetResource(ctx, r1.Id)
+			require.NoError(t, err)
+			testQuery(t, ctx, ds, "tags.key-2499", "value-2499", r1)
+			testQueryNoResult(t, ctx, ds, "tags.key-2499", "value-0")
+		})
+	}
+}
+
+//test that the resources of the previous runs can be queried
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			beforeRuns := time.Now()
+
+			//1st run: write 3 resources
+			resources := testdata.GetResources(t)[:3]
+			run1 := model.NewEngineEventStart()
+			require.NoError(t, ds.WriteEvent(ctx, run1))
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+			r1, err := ds.GetResource(ctx, resources[0].Id)
+			require.NoError(t, err)
+			r2, err := ds.GetResource(ctx, resources[1].Id)
+			require.NoError(t, err)
+			r3, err := ds.GetResource(ctx, resources[2].Id)
+			require.NoError(t, err)
+			afterRun1 := time.Now()
+
+			//2nd run: r1 has a new tag value, r2 is unchanged, r3 is deleted
+			r1Updated := *r1
+			r1Updated.Tags = r1.Tags.Delete(tagUniqueKey)
+			r1Updated.Tags = append(r1Updated.Tags, model.Tag{Key: tagUniqueKey, Value: "updated"})
+			run2 := model.NewEngineEventStart()
+			require.NoError(t, ds.WriteEvent(ctx, run2))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{&r1Updated, r2}.Clean()))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			//the current resources
+			testQuery(t, ctx, ds, tagUniqueKey, "updated", &r1Updated)
+			testQueryNoResult(t, ctx, ds, tagUniqueKey, tagUniqueValue)
+
+			asOf := func(asOf string, filter string) model.ResourcesResponse {
+				response, err := ds.GetResources(ctx, []byte(fmt.Sprintf(`{"asOf":"%v","filter":{%v}}`, asOf, filter)))
+				require.NoError(t, err)
+				return response
+			}
+			//the resources at the end of the 1st run
+			response := asOf(run1.RunId, "")
+			require.Equal(t, 3, response.Count)
+			testingutil.AssertEqualsResources(t, model.Resources{r1, r2, r3}, response.Resources)
+			response = asOf(run1.RunId, fmt.Sprintf(`"tags.%v":"%v"`, tagUniqueKey, tagUniqueValue))
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+			//the fields are for the 1st run
+			field := response.FieldGroups.FindField("tags", tagUniqueKey)
+			require.NotNil(t, field)
+			require.Equal(t, 1, len(field.Values))
+			require.Equal(t, tagUniqueValue, field.Values[0].Value)
+			//the same result using a timestamp
+			response = asOf(afterRun1.UTC().Format(time.RFC3339Nano), fmt.Sprintf(`"tags.%v":"%v"`, tagUniqueKey, tagUniqueValue))
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+			//the resources are filtered and sorted by their id, not by the id of their version
+			response = asOf(run1.RunId, fmt.Sprintf(`"core.id":"%v"`, r3.Id))
+			testingutil.AssertEqualsResources(t, model.Resources{r3}, response.Resources)
+			response, err = ds.GetResources(ctx, []byte(fmt.Sprintf(`{"asOf":"%v","sort":["-core.id"]}`, run1.RunId)))
+			require.NoError(t, err)
+			ids := []string{r1.Id, r2.Id, r3.Id}
+			sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+			var sortedIds []string
+			for _, r := range response.Resources {
+				sortedIds = append(sortedIds, r.Id)
+			}
+			require.Equal(t, ids, sortedIds)
+			response, err = ds.GetResources(ctx, []byte(fmt.Sprintf(`{"asOf":"%v","filter":{"tags.%v":"%v"},"sort":["tags.%v"]}`, run1.RunId, tagUniqueKey, tagUniqueValue, tagUniqueKey)))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1}, response.Resources)
+
+			//the resources at the end of the 2nd run
+			response = asOf(run2.RunId, "")
+			require.Equal(t, 2, response.Count)
+			testingutil.AssertEqualsResources(t, model.Resources{&r1Updated, r2}, response.Resources)
+			response = asOf(run2.RunId, fmt.Sprintf(`"tags.%v":"%v"`, tagUniqueKey, tagUniqueValue))
+			require.Equal(t, 0, response.Count)
+			//r1 is the last updated resource
+			response, err = ds.GetResources(ctx, []byte(fmt.Sprintf(`{"asOf":"%v","sort":["-core.updated_at"]}`, run2.RunId)))
+			require.NoError(t, err)
+			require.Equal(t, r1.Id, response.Resources[0].Id)
+
+			//no resource before the 1st run
+			response = asOf(beforeRuns.UTC().Format(time.RFC3339Nano), "")
+			require.Equal(t, 0, response.Count)
+
+			//unknown run
+			_, err = ds.GetResources(ctx, []byte(`{"asOf":"not-a-run"}`))
+			require.ErrorContains(t, err, "not a known run id")
+
+			//the runs are listed, most recent first
+			runs, err := ds.GetRuns(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 2, len(runs))
+			require.Equal(t, run2.RunId, runs[0].RunId)
+			require.Equal(t, run1.RunId, runs[1].RunId)
+
+			//purge the history as if the retention has expired: the 1st versions of r1 and r3 are deleted
+			purger := ds.(interface{ purgeHistory(time.Time) error })
+			require.NoError(t, purger.purgeHistory(time.Now().Add(2*time.Hour)))
+			runs, err = ds.GetRuns(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 0, len(runs))
+			response = asOf(afterRun1.UTC().Format(time.RFC3339Nano), "")
+			testingutil.AssertEqualsResources(t, model.Resources{r2}, response.Resources)
+		})
+	}
+}
+
+//test that the core fields are stored in the resources and in their versions
+func TestCoreFields(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(path.Join(t.TempDir(), "fields.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(tables...))
+	for _, f := range model.CoreFields {
+		assert.True(t, db.Migrator().HasColumn(&model.Resource{}, f.Name), f.Name)
+		//the update time of a version is its valid_from column
+		if f.Name != "updated_at" {
+			assert.True(t, db.Migrator().HasColumn(&resourceVersion{}, f.Name), f.Name)
+		}
+	}
+
+	//the hash of a resource doesn't change when an optional field is added
+	r := testdata.GetResources(t)[0]
+	hash, err := resourceHash(r)
+	require.NoError(t, err)
+	assert.Equal(t, "5c3253e8ed604e1e123b75fc2021b258f5d96eb60909319dcb0d6a25ac16a100", hash)
+	r.AccountName = "management"
+	r.Module = "module.vpc"
+	r.ManagedBy = model.ManagedByTerraform
+	hash, err = resourceHash(r)
+	require.NoError(t, err)
+	assert.Equal(t, "fd81084339ed314a08d0b6ba79e8ab63e04aa0a9a7405a9290b8453a38ee2aa7", hash)
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)
+			r1, r2, r3 := resources[0], resources[1], resources[2]
+			run1 := model.NewEngineEventStart()
+			require.NoError(t, ds.WriteEvent(ctx, run1))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1, r2}))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			//2nd run: r1 is modified, r2 is deleted and r3 is added
+			r1Updated := *r1
+			r1Updated.Tags = r1.Tags.Delete("team").Add("team", "new-team")
+			r1Updated.RawData = []byte(`{"InstanceType":"t3.medium"}`)
+			run2 := model.NewEngineEventStart()
+			require.NoError(t, ds.WriteEvent(ctx, run2))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{&r1Updated, r3}))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			//all the resources are returned, the limit is ignored
+			all, err := ds.GetAllResources(ctx, []byte(`{"limit":1}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{&r1Updated, r3}, all)
+
+			diff, err := Diff(ctx, ds, run1.RunId, ds, run2.RunId, nil)
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r3}, diff.Added)
+			testingutil.AssertEqualsResources(t, model.Resources{r2}, diff.Removed)
+			require.Equal(t, 1, len(diff.Modified))
+			require.Equal(t, r1.Id, diff.Modified[0].Resource.Id)
+			require.Equal(t, []model.TagChange{{Change: model.ChangeModified, Key: "team", From: "infra", To: "new-team"}}, diff.Modified[0].Tags)
+			require.NotEmpty(t, diff.Modified[0].RawData)
+
+			//the current resources are used if 'to' is not set
+			diff, err = Diff(ctx, ds, run1.RunId, ds, "", []byte(`{"filter":{"core.type":"s3.Bucket"}}`))
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r3}, diff.Added)
+			require.Equal(t, 0, len(diff.Removed))
+			require.Equal(t, 0, len(diff.Modified))
+
+			//no change
+			diff, err = Diff(ctx, ds, run2.RunId, ds, "", nil)
+			require.NoError(t, err)
+			require.True(t, diff.Empty())
+
+			_, err = Diff(ctx, ds, "unknown", ds, "", nil)
+			require.ErrorContains(t, err, "not a known run id")
+		})
+	}
+}
+
+func TestComplianceReport(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			report, err := ds.GetComplianceReport(ctx)
+			require.NoError(t, err)
+			require.Nil(t, report)
+
+			//only the latest report is kept
+			for _, runId := range []string{"run-1", "run-2"} {
+				require.NoError(t, ds.WriteComplianceReport(ctx, model.ComplianceReport{
+					RunId:    runId,
+					Policies: []model.PolicyCompliance{{Name: "team", ResourcesCount: 1, Coverage: 100}},
+				}))
+			}
+			report, err = ds.GetComplianceReport(ctx)
+			require.NoError(t, err)
+			require.NotNil(t, report)
+			require.Equal(t, "run-2", report.RunId)
+			require.Equal(t, []model.PolicyCompliance{{Name: "team", ResourcesCount: 1, Coverage: 100}}, report.Policies)
+		})
+	}
+}
+
+//test that all the resources can be read, past the maximum limit of a query
+func TestPageResources(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			count := LimitMaxValue + 10
+			var resources model.Resources
+			for i := 0; i < count; i++ {
+				resources = append(resources, &model.Resource{
+					Id: fmt.Sprintf("i-%04d", i), Region: "us-east-1", Type: "test.Instance",
+					Tags: model.Tags{{Key: "even", Value: fmt.Sprint(i%2 == 0)}},
+				})
+			}
+			require.NoError(t, ds.WriteResources(ctx, resources))
+
+			//the fields are not computed when disabled
+			response, err := ds.GetResources(ctx, []byte(`{"fields":false,"limit":1}`))
+			require.NoError(t, err)
+			assert.Equal(t, count, response.Count)
+			assert.Nil(t, response.FieldGroups)
+
+			page := func(query string, max int) ([]string, int) {
+				var ids []string
+				pages := 0
+				require.NoError(t, PageResources(ctx, ds, []byte(query), max, func(resources model.Resources) error {
+					pages++
+					for _, r := range resources {
+						ids = append(ids, r.Id)
+					}
+					return nil
+				}))
+				return ids, pages
+			}
+			//all the resources, sorted by id
+			ids, pages := page("", 0)
+			require.Equal(t, count, len(ids))
+			assert.Equal(t, 2, pages)
+			assert.Equal(t, "i-0000", ids[0])
+			assert.Equal(t, fmt.Sprintf("i-%04d", count-1), ids[count-1])
+			//the sort of the query is kept
+			ids, _ = page(`{"sort":["-core.id"]}`, 0)
+			assert.Equal(t, fmt.Sprintf("i-%04d", count-1), ids[0])
+			//with a filter and a maximum
+			ids, pages = page(`{"filter":{"tags.even":"true"}}`, 5)
+			assert.Equal(t, []string{"i-0000", "i-0002", "i-0004", "i-0006", "i-0008"}, ids)
+			assert.Equal(t, 1, pages)
+			ids, _ = page(`{"filter":{"tags.even":"false"}}`, 0)
+			assert.Equal(t, count/2, len(ids))
+			//an error stops the paging
+			err = PageResources(ctx, ds, nil, 0, func(resources model.Resources) error {
+				return errors.New("stop")
+			})
+			assert.EqualError(t, err, "stop")
+		})
+	}
+}
+
+//test that a SQLite database created before can be opened read-only
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	dbFile := path.Join(t.TempDir(), "inventory.db")
+	cfg := config.Config{Datastore: config.Datastore{Type: "sqlite", DataSourceName: dbFile}}
+
+	//the database must exist
+	readOnlyCfg := cfg
+	readOnlyCfg.Datastore.ReadOnly = true
+	_, err := NewDatastore(ctx, readOnlyCfg, logger)
+	require.Error(t, err)
+
+	//write a run
+	ds, err := NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+	run := model.NewEngineEventStart()
+	require.NoError(t, ds.WriteEvent(ctx, run))
+	require.NoError(t, ds.WriteResources(ctx, testdata.GetResources(t)))
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+	info, err := os.Stat(dbFile)
+	require.NoError(t, err)
+
+	ds, err = NewDatastore(ctx, readOnlyCfg, logger)
+	require.NoError(t, err)
+	stats, err := ds.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.ResourcesCount)
+	response, err := ds.GetResources(ctx, []byte(`{"filter":{"tags.team":"infra"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, response.Count)
+	//the status of the last run is returned
+	status, err := ds.EngineStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, run.RunId, status.RunId)
+	assert.Equal(t, model.EventStatusSuccess, status.Status)
+
+	//the writes are rejected
+	assert.ErrorIs(t, ds.WriteResources(ctx, testdata.GetResources(t)), errReadOnly)
+	assert.ErrorIs(t, ds.WriteEvent(ctx, model.NewEngineEventStart()), errReadOnly)
+	assert.ErrorIs(t, ds.WriteComplianceReport(ctx, model.ComplianceReport{}), errReadOnly)
+	newInfo, err := os.Stat(dbFile)
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime(), newInfo.ModTime())
+
+	//the schema is not migrated: the legacy tables are kept
+	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("CREATE TABLE field_columns (column_name text, field_name text)").Error)
+	_, err = NewDatastore(ctx, readOnlyCfg, logger)
+	require.NoError(t, err)
+	assert.True(t, db.Migrator().HasTable("field_columns"))
+
+	//a database created by an older version can't be opened
+	require.NoError(t, db.Migrator().DropColumn(&model.Resource{}, "managed_by"))
+	_, err = NewDatastore(ctx, readOnlyCfg, logger)
+	assert.ErrorContains(t, err, "missing column managed_by in table resources, the database was created by an older version of cloudgrep")
+
+	//a database without the cloudgrep tables can't be opened
+	emptyFile := path.Join(t.TempDir(), "empty.db")
+	require.NoError(t, os.WriteFile(emptyFile, nil, 0600))
+	readOnlyCfg.Datastore.DataSourceName = emptyFile
+	_, err = NewDatastore(ctx, readOnlyCfg, logger)
+	assert.ErrorContains(t, err, "the database must be created by cloudgrep first")
+}
+
 //test that the DB can be reloaded at startup
 func TestReloadDB(t *testing.T) {
 	ctx := context.Background()
@@ -622,7 +1383,7 @@ func TestPurgeResources(t *testing.T) {
 			testingutil.AssertEqualsResources(t, model.Resources{r2, r3}, resourcesRead.Resources)
 			//the query doesn't return the deleted resource
 			testQueryNoResult(t, ctx, ds, "id", r1.Id)
-			testQueryUnrecognizedKey(t, ctx, ds, tagUniqueKey, tagUniqueValue)
+			testQueryNoResult(t, ctx, ds, tagUniqueKey, tagUniqueValue)
 
 			//3rd run: an error happened - there is a built-in protection to not delete all resources
 			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
@@ -643,6 +1404,110 @@ func TestPurgeResources(t *testing.T) {
 			testingutil.AssertEqualsResources(t, model.Resources{r1, r2, r3}, resourcesRead.Resources)
 			testQuery(t, ctx, ds, tagUniqueKey, tagUniqueValue, r1)
 
+			//5th run: the fetch of the instances failed after writing one of them, the other one is kept
+			//the bucket was fetched successfully, it doesn't exist anymore
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1}.Clean()))
+			instancesEvent := model.NewResourceEventEnd("fake", r2.Type, errors.New("throttled"))
+			instancesEvent.AccountId, instancesEvent.Region = r2.AccountId, r2.Region
+			require.NoError(t, ds.WriteEvent(ctx, instancesEvent))
+			bucketsEvent := model.NewResourceEventEnd("fake", r3.Type, nil)
+			bucketsEvent.AccountId, bucketsEvent.Region = r3.AccountId, r3.Region
+			require.NoError(t, ds.WriteEvent(ctx, bucketsEvent))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+			resourcesRead, err = ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			require.Equal(t, 2, resourcesRead.Count)
+			testingutil.AssertEqualsResources(t, model.Resources{r1, r2}, resourcesRead.Resources)
+
+			//6th run: the fetch failed for a provider without region, the instances of all the regions are kept
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1}.Clean()))
+			instancesEvent = model.NewResourceEventEnd("fake", r2.Type, errors.New("throttled"))
+			instancesEvent.AccountId = r2.AccountId
+			require.NoError(t, ds.WriteEvent(ctx, instancesEvent))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+			resourcesRead, err = ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1, r2}, resourcesRead.Resources)
+
+		})
+	}
+}
+
+//test that the resources which couldn't be written are not deleted at the end of the run
+func TestPurgeResourcesWriteError(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)[:3]
+			r1, r2, r3 := resources[0], resources[1], resources[2]
+
+			//1st run: write 3 resources
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			//2nd run: the first batch is written, the second one fails
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1}.Clean()))
+			writeErr := errors.New("connection reset")
+			db := storeDB(ds)
+			require.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:fail", func(tx *gorm.DB) {
+				if tx.Statement.Table == "resources" {
+					tx.AddError(writeErr)
+				}
+			}))
+			err := ds.WriteResources(ctx, model.Resources{r2, r3}.Clean())
+			require.NoError(t, db.Callback().Create().Remove("test:fail"))
+			require.ErrorIs(t, err, writeErr)
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(err)))
+			resourcesRead, err := ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1, r2, r3}, resourcesRead.Resources)
+		})
+	}
+}
+
+//storeDB returns the database of a SQL datastore
+func storeDB(ds Datastore) *gorm.DB {
+	switch s := ds.(type) {
+	case *SQLiteStore:
+		return s.db
+	case *PostgresStore:
+		return s.db
+	}
+	return nil
+}
+
+//test that the resources of an account scanned without any resource are deleted, the accounts not scanned are kept
+func TestPurgeEmptyAccount(t *testing.T) {
+	ctx := context.Background()
+	datastores, _ := newDatastores(t, ctx)
+	for _, ds := range datastores {
+		name := fmt.Sprintf("%T", ds)
+		t.Run(name, func(t *testing.T) {
+			resources := testdata.GetResources(t)[:3]
+			r1, r2, r3 := resources[0], resources[1], resources[2]
+			r1.AccountId, r2.AccountId, r3.AccountId = "111", "222", "333"
+
+			//1st run: one resource in each account
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, resources))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+
+			//2nd run: the account 222 has no resource anymore, the account 333 is scanned by another instance
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+			require.NoError(t, ds.WriteResources(ctx, model.Resources{r1}))
+			emptyEvent := model.NewResourceEventEnd("fake-222", r2.Type, nil)
+			emptyEvent.AccountId, emptyEvent.Region = r2.AccountId, r2.Region
+			require.NoError(t, ds.WriteEvent(ctx, emptyEvent))
+			require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+			resourcesRead, err := ds.GetResources(ctx, nil)
+			require.NoError(t, err)
+			testingutil.AssertEqualsResources(t, model.Resources{r1, r3}, resourcesRead.Resources)
 		})
 	}
 }
@@ -0,0 +1,40 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+)
+
+//Diff compares the resources matching a query in two datastores, they can be the same datastore
+//fromRun and toRun select the resources as of a run (or a timestamp), the current resources are used if empty
+func Diff(ctx context.Context, from Datastore, fromRun string, to Datastore, toRun string, jsonQuery []byte) (model.ResourcesDiff, error) {
+	fromResources, err := getResourcesAsOf(ctx, from, fromRun, jsonQuery)
+	if err != nil {
+		return model.ResourcesDiff{}, err
+	}
+	toResources, err := getResourcesAsOf(ctx, to, toRun, jsonQuery)
+	if err != nil {
+		return model.ResourcesDiff{}, err
+	}
+	return model.NewResourcesDiff(fromResources, toResources)
+}
+
+func getResourcesAsOf(ctx context.Context, ds Datastore, asOf string, jsonQuery []byte) (model.Resources, error) {
+	if asOf != "" {
+		query := make(map[string]interface{})
+		if len(jsonQuery) > 0 {
+			if err := json.Unmarshal(jsonQuery, &query); err != nil {
+				return nil, fmt.Errorf("can't read the query: %w", err)
+			}
+		}
+		query["asOf"] = asOf
+		var err error
+		if jsonQuery, err = json.Marshal(query); err != nil {
+			return nil, err
+		}
+	}
+	return ds.GetAllResources(ctx, jsonQuery)
+}
@@ -0,0 +1,398 @@
+package datastore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+//The history keeps a version of a resource each time it changes.
+//A version is valid from the time it was written until the resource is updated or deleted.
+//The resources at a point in time are the versions valid at that time, they are queried like the current resources.
+
+const (
+	resourceVersionsTable = "resource_versions"
+	tagVersionsTable      = "tag_versions"
+	propertyVersionsTable = "property_versions"
+	//validAtCondition selects the versions valid at a time
+	validAtCondition = "valid_from <= ? AND (valid_to IS NULL OR valid_to > ?)"
+)
+
+//versionTables maps a table storing the current resources to the table storing their versions
+var versionTables = map[string]string{
+	resourcesTable:  resourceVersionsTable,
+	tagsTable:       tagVersionsTable,
+	propertiesTable: propertyVersionsTable,
+}
+
+//resourceVersion is the state of a resource during a period of time
+type resourceVersion struct {
+	//Id identifies the version, the tags and the properties of the version use it as resource id
+	Id                 string `gorm:"primaryKey"`
+	ResourceId         string `gorm:"index"`
+	DisplayId          string
+	AccountId          string
+	AccountName        string `gorm:"default:''"`
+	OrganizationalUnit string `gorm:"default:''"`
+	Namespace          string `gorm:"default:''"`
+	Module             string `gorm:"default:''"`
+	ManagedBy          string `gorm:"default:''"`
+	Region             string
+	Type               string
+	RawData            datatypes.JSON
+	//Hash of the resource content, a new version is only created if the hash is different
+	Hash      string
+	ValidFrom time.Time `gorm:"index"`
+	//ValidTo is not set for the current version
+	ValidTo *time.Time `gorm:"index"`
+}
+
+func (resourceVersion) TableName() string {
+	return resourceVersionsTable
+}
+
+//tagVersion is a tag of a resource version, ResourceId is the version id
+type tagVersion struct {
+	ResourceId string `gorm:"primaryKey"`
+	Key        string `gorm:"primaryKey;index:idx_tag_versions_key_value,priority:1"`
+	Value      string `gorm:"index:idx_tag_versions_key_value,priority:2"`
+}
+
+func (tagVersion) TableName() string {
+	return tagVersionsTable
+}
+
+//propertyVersion is a property of a resource version, ResourceId is the version id
+type propertyVersion struct {
+	ResourceId string `gorm:"primaryKey"`
+	Path       string `gorm:"primaryKey;index:idx_property_versions_path_value,priority:1"`
+	Value      string `gorm:"primaryKey;index:idx_property_versions_path_value,priority:2"`
+}
+
+func (propertyVersion) TableName() string {
+	return propertyVersionsTable
+}
+
+//snapshot selects the resources at a point in time, the zero value selects the current resources
+type snapshot struct {
+	asOf time.Time
+}
+
+func (s snapshot) isCurrent() bool {
+	return s.asOf.IsZero()
+}
+
+//table returns the table to query, ex: "tags" or "tag_versions"
+func (s snapshot) table(name string) string {
+	if s.isCurrent() {
+		return name
+	}
+	return versionTables[name]
+}
+
+//rowId returns the column of the resources referenced by the key/value tables: the resource id, or the version id in the history
+func (s snapshot) rowId() string {
+	if s.isCurrent() {
+		return "id"
+	}
+	return "version_id"
+}
+
+//resources returns the resources to query, the result is always named after the resources table
+//the id of a version is its resource id like the current resources, the id of the version itself is version_id
+func (s snapshot) resources(db *gorm.DB) *gorm.DB {
+	if s.isCurrent() {
+		return db.Table(resourcesTable)
+	}
+	columns := make([]string, 0, len(model.CoreFields)+1)
+	for _, f := range model.CoreFields {
+		switch f.Name {
+		case "id":
+			columns = append(columns, "resource_id AS id", "id AS version_id")
+		case "updated_at":
+			columns = append(columns, "valid_from AS updated_at")
+		default:
+			columns = append(columns, f.Name)
+		}
+	}
+	versions := db.Session(&gorm.Session{NewDB: true}).
+		Table(resourceVersionsTable).
+		Select(columns).
+		Where(validAtCondition, s.asOf, s.asOf)
+	return db.Table(fmt.Sprintf("(?) AS %v", resourcesTable), versions)
+}
+
+//keyValueTable returns a key/value table to query, only the rows of the snapshot are selected
+func (s snapshot) keyValueTable(table keyValueTable) keyValueTable {
+	if s.isCurrent() {
+		return table
+	}
+	table.name = s.table(table.name)
+	table.filters = append([]string{
+		fmt.Sprintf("resource_id IN (SELECT id FROM %v WHERE %v)", resourceVersionsTable, validAtCondition),
+	}, table.filters...)
+	table.filterArgs = append([]interface{}{s.asOf, s.asOf}, table.filterArgs...)
+	return table
+}
+
+func (s *sqlStore) historyEnabled() bool {
+	return s.historyRetention > 0
+}
+
+//newSnapshot returns the snapshot to query, asOf can be a run id or a timestamp (RFC 3339)
+func (s *sqlStore) newSnapshot(asOf string) (snapshot, error) {
+	if asOf == "" {
+		return snapshot{}, nil
+	}
+	if !s.historyEnabled() {
+		return snapshot{}, fmt.Errorf("can't query the resources as of '%v': the history is disabled", asOf)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, asOf); err == nil {
+		return snapshot{asOf: t.UTC()}, nil
+	}
+	//the resources at the end of a run
+	var events model.Events
+	if err := s.db.Find(&events, model.Event{RunId: asOf, Type: model.EventTypeEngine}).Error; err != nil {
+		return snapshot{}, fmt.Errorf("can't get the run '%v': %w", asOf, err)
+	}
+	if len(events) == 0 {
+		return snapshot{}, fmt.Errorf("can't query the resources as of '%v': not a known run id or a RFC 3339 timestamp", asOf)
+	}
+	if events[0].Status == model.EventStatusFetching {
+		//the run is not done: use the current time
+		return snapshot{asOf: time.Now().UTC()}, nil
+	}
+	return snapshot{asOf: events[0].UpdatedAt.UTC()}, nil
+}
+
+//resourceHash returns a hash of the content of a resource
+func resourceHash(r *model.Resource) (string, error) {
+	tags := make(model.Tags, len(r.Tags))
+	copy(tags, r.Tags)
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	//the optional fields are omitted if not set, the hash of the other resources doesn't change
+	//the content is a JSON object, its keys are in the order of the struct hashed before the optional fields were added
+	entries := []hashEntry{{"DisplayId", r.DisplayId}, {"AccountId", r.AccountId}}
+	for _, f := range model.CoreFields {
+		if value := f.Value(r); f.Optional && value != "" {
+			entries = append(entries, hashEntry{hashKey(f.Name), value})
+		}
+	}
+	entries = append(entries, hashEntry{"Region", r.Region}, hashEntry{"Type", r.Type}, hashEntry{"Tags", tags}, hashEntry{"RawData", r.RawData})
+	content := []byte("{")
+	for i, entry := range entries {
+		if i > 0 {
+			content = append(content, ',')
+		}
+		key, err := json.Marshal(entry.key)
+		if err != nil {
+			return "", fmt.Errorf("can't hash the resource '%v': %w", r.Id, err)
+		}
+		value, err := json.Marshal(entry.value)
+		if err != nil {
+			return "", fmt.Errorf("can't hash the resource '%v': %w", r.Id, err)
+		}
+		content = append(append(append(content, key...), ':'), value...)
+	}
+	content = append(content, '}')
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type hashEntry struct {
+	key   string
+	value interface{}
+}
+
+//hashKey returns the key of a core field in the hashed content, ex: "account_name" -> "AccountName"
+func hashKey(name string) string {
+	words := strings.Split(name, "_")
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, "")
+}
+
+//writeVersions creates a new version for the resources that are new or have changed
+func (s *sqlStore) writeVersions(tx *gorm.DB, resources []*model.Resource, now time.Time) error {
+	//if a resource is present more than once, keep the first one like the tags
+	hashes := make(map[string]string)
+	var ids []string
+	var unique []*model.Resource
+	for _, r := range resources {
+		if _, found := hashes[r.Id]; found {
+			continue
+		}
+		hash, err := resourceHash(r)
+		if err != nil {
+			return err
+		}
+		hashes[r.Id] = hash
+		ids = append(ids, r.Id)
+		unique = append(unique, r)
+	}
+
+	//get the current versions
+	var current []resourceVersion
+	if err := tx.Select("resource_id", "hash").Where("valid_to IS NULL AND resource_id in ?", ids).Find(&current).Error; err != nil {
+		return fmt.Errorf("can't get the resource versions: %w", err)
+	}
+	unchanged := make(map[string]bool, len(current))
+	for _, v := range current {
+		unchanged[v.ResourceId] = v.Hash == hashes[v.ResourceId]
+	}
+
+	var changedIds []string
+	var versions []resourceVersion
+	var tags []tagVersion
+	var properties []propertyVersion
+	for _, r := range unique {
+		if unchanged[r.Id] {
+			continue
+		}
+		changedIds = append(changedIds, r.Id)
+		version := resourceVersion{
+			Id:                 uuid.New().String(),
+			ResourceId:         r.Id,
+			DisplayId:          r.DisplayId,
+			AccountId:          r.AccountId,
+			AccountName:        r.AccountName,
+			OrganizationalUnit: r.OrganizationalUnit,
+			Namespace:          r.Namespace,
+			Module:             r.Module,
+			ManagedBy:          r.ManagedBy,
+			Region:             r.Region,
+			Type:               r.Type,
+			RawData:            r.RawData,
+			Hash:               hashes[r.Id],
+			ValidFrom:          now,
+		}
+		versions = append(versions, version)
+		for _, tag := range r.Tags {
+			tags = append(tags, tagVersion{ResourceId: version.Id, Key: tag.Key, Value: tag.Value})
+		}
+		resourceProperties, err := r.Properties()
+		if err != nil {
+			return err
+		}
+		for _, p := range resourceProperties {
+			properties = append(properties, propertyVersion{ResourceId: version.Id, Path: p.Path, Value: p.Value})
+		}
+	}
+	if len(versions) == 0 {
+		//nothing changed
+		return nil
+	}
+
+	//the previous versions are no longer valid
+	if err := closeVersions(tx, changedIds, now); err != nil {
+		return err
+	}
+	if err := tx.Create(versions).Error; err != nil {
+		return fmt.Errorf("can't create the resource versions: %w", err)
+	}
+	if len(tags) > 0 {
+		if err := tx.CreateInBatches(tags, tagsBatchSize).Error; err != nil {
+			return fmt.Errorf("can't create the tag versions: %w", err)
+		}
+	}
+	if len(properties) > 0 {
+		if err := tx.CreateInBatches(properties, propertiesBatchSize).Error; err != nil {
+			return fmt.Errorf("can't create the property versions: %w", err)
+		}
+	}
+	return nil
+}
+
+//closeVersions ends the current versions of the resources
+func closeVersions(tx *gorm.DB, ids []string, at time.Time) error {
+	err := tx.Model(&resourceVersion{}).Where("valid_to IS NULL AND resource_id in ?", ids).Update("valid_to", at).Error
+	if err != nil {
+		return fmt.Errorf("can't update the resource versions: %w", err)
+	}
+	return nil
+}
+
+//purgeHistory deletes the versions and the runs older than the retention
+func (s *sqlStore) purgeHistory(now time.Time) error {
+	before := now.Add(-s.historyRetention)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		expiredVersions := fmt.Sprintf("resource_id IN (SELECT id FROM %v WHERE valid_to < ?)", resourceVersionsTable)
+		if err := tx.Where(expiredVersions, before).Delete(&tagVersion{}).Error; err != nil {
+			return fmt.Errorf("can't delete the tag versions: %w", err)
+		}
+		if err := tx.Where(expiredVersions, before).Delete(&propertyVersion{}).Error; err != nil {
+			return fmt.Errorf("can't delete the property versions: %w", err)
+		}
+		result := tx.Where("valid_to < ?", before).Delete(&resourceVersion{})
+		if result.Error != nil {
+			return fmt.Errorf("can't delete the resource versions: %w", result.Error)
+		}
+		s.logger.Sugar().Infow("Purging history: ", "versions", result.RowsAffected)
+		if err := tx.Where("created_at < ?", before).Delete(&model.Event{}).Error; err != nil {
+			return fmt.Errorf("can't delete the events: %w", err)
+		}
+		return nil
+	})
+}
+
+//getVersionsById returns the resources for some version ids, in the same order
+func (s *sqlStore) getVersionsById(ids []model.ResourceId) ([]*model.Resource, error) {
+	resources := make([]*model.Resource, 0, len(ids))
+	if len(ids) == 0 {
+		return resources, nil
+	}
+	var versions []resourceVersion
+	if err := s.db.Where("id in ?", ids).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	var tags []tagVersion
+	if err := s.db.Where("resource_id in ?", ids).Order("key").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	tagsByVersion := make(map[string]model.Tags)
+	for _, tag := range tags {
+		tagsByVersion[tag.ResourceId] = append(tagsByVersion[tag.ResourceId], model.Tag{ResourceId: tag.ResourceId, Key: tag.Key, Value: tag.Value})
+	}
+	versionsById := make(map[model.ResourceId]resourceVersion, len(versions))
+	for _, v := range versions {
+		versionsById[model.ResourceId(v.Id)] = v
+	}
+	for _, id := range ids {
+		v, found := versionsById[id]
+		if !found {
+			continue
+		}
+		tags := tagsByVersion[v.Id]
+		for i := range tags {
+			tags[i].ResourceId = v.ResourceId
+		}
+		resources = append(resources, &model.Resource{
+			Id:                 v.ResourceId,
+			DisplayId:          v.DisplayId,
+			AccountId:          v.AccountId,
+			AccountName:        v.AccountName,
+			OrganizationalUnit: v.OrganizationalUnit,
+			Namespace:          v.Namespace,
+			Module:             v.Module,
+			ManagedBy:          v.ManagedBy,
+			Region:             v.Region,
+			Type:               v.Type,
+			Tags:               tags,
+			RawData:            v.RawData,
+			UpdatedAt:          v.ValidFrom,
+		})
+	}
+	return resources, nil
+}
@@ -0,0 +1,65 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+)
+
+//PageResources reads the resources matching a query one page at a time, it is not limited by LimitMaxValue
+//f is called for each page, max is the maximum number of resources to read (0 for all)
+//the limit and the offset of the query are ignored
+func PageResources(ctx context.Context, ds Datastore, jsonQuery []byte, max int, f func(model.Resources) error) error {
+	query := make(map[string]interface{})
+	if len(jsonQuery) > 0 {
+		if err := json.Unmarshal(jsonQuery, &query); err != nil {
+			return fmt.Errorf("can't read the query: %w", err)
+		}
+	}
+	//sort by id last to have a stable order between pages
+	sort, _ := query["sort"].([]interface{})
+	sortedById := false
+	for _, field := range sort {
+		name := strings.TrimLeft(fmt.Sprint(field), "+-")
+		if name == "core.id" || name == "id" {
+			sortedById = true
+		}
+	}
+	if !sortedById {
+		query["sort"] = append(sort, "core.id")
+	}
+	//the fields are not needed
+	query["fields"] = false
+
+	offset := 0
+	for max <= 0 || offset < max {
+		limit := LimitMaxValue
+		if max > 0 && max-offset < limit {
+			limit = max - offset
+		}
+		query["limit"] = limit
+		query["offset"] = offset
+		pageQuery, err := json.Marshal(query)
+		if err != nil {
+			return err
+		}
+		response, err := ds.GetResources(ctx, pageQuery)
+		if err != nil {
+			return err
+		}
+		if len(response.Resources) == 0 {
+			return nil
+		}
+		if err := f(response.Resources); err != nil {
+			return err
+		}
+		offset += len(response.Resources)
+		if offset >= response.Count {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+//PostgresStore stores the resources in a Postgres database, the same database can be shared by many cloudgrep instances
+type PostgresStore struct {
+	sqlStore
+}
+
+func NewPostgresStore(ctx context.Context, cfg config.Config, zapLogger *zap.Logger) (*PostgresStore, error) {
+	s := PostgresStore{}
+	//create the DB client
+	db, err := gorm.Open(postgres.Open(os.ExpandEnv(cfg.Datastore.DataSourceName)),
+		&gorm.Config{Logger: newGormLogger(zapLogger)})
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to the Postgres database: %w", err)
+	}
+	if err = s.init(ctx, db, cfg.Datastore, zapLogger); err != nil {
+		return nil, fmt.Errorf("can't create the Postgres datastore: %w", err)
+	}
+	if !cfg.Datastore.ReadOnly {
+		if err = s.createIndexes(); err != nil {
+			return nil, fmt.Errorf("can't create the Postgres indexes: %w", err)
+		}
+	}
+	return &s, nil
+}
+
+//createIndexes creates the indexes used to query the tags and the raw data
+func (s *PostgresStore) createIndexes() error {
+	//a GIN index on (key, value) requires the btree_gin extension, the btree index idx_tags_key_value is used otherwise
+	if err := s.db.Exec("CREATE EXTENSION IF NOT EXISTS btree_gin").Error; err != nil {
+		s.logger.Sugar().Warnf("can't create the btree_gin extension, the tags will use a btree index: %v", err)
+	} else {
+		if err := s.db.Exec("CREATE INDEX IF NOT EXISTS idx_tags_key_value_gin ON tags USING GIN (key, value)").Error; err != nil {
+			return err
+		}
+	}
+	//the raw data is stored as JSONB, allow to search it with the containment operator @>
+	return s.db.Exec("CREATE INDEX IF NOT EXISTS idx_resources_raw_data ON resources USING GIN (raw_data jsonb_path_ops)").Error
+}
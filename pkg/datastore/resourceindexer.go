@@ -4,52 +4,81 @@
 	"context"
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/a8m/rql"
 	"github.com/juandiegopalomino/cloudgrep/pkg/model"
 	dynamicstruct "github.com/ompluscator/dynamic-struct"
 	"go.uber.org/zap"
-	"golang.org/x/exp/slices"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	"gorm.io/gorm"
 )
 
 const (
-	resourceIndexTable = "resource_index"
-	//the name the dynamic columns
-	columnDynamicName = "col_%v"
-	//the maximum number of columns allowed
-	//this is a limitation for sqlite https://www.sqlite.org/limits.html
-	maxColumns = 2000
+	resourcesTable  = "resources"
+	tagsTable       = "tags"
+	propertiesTable = "properties"
 )
 
+//legacyTables are the tables used when the tags were stored as columns, they are dropped at startup
+var legacyTables = []string{"resource_index", "field_columns"}
+
+//virtualFilterRegexp matches a filter expression on a column, ex: "prop_12 = "
+var virtualFilterRegexp = regexp.MustCompile(`\b(\w+) (=|<>|<|<=|>|>=|LIKE) $`)
+
+//nullComparisonReplacer updates the null comparisons for the databases which don't support "is ?", ex: Postgres
+var nullComparisonReplacer = strings.NewReplacer(" is not ?", " IS DISTINCT FROM ?", " is ?", " IS NOT DISTINCT FROM ?")
+
 //resourceIndexer is responsible to index the resources in the DB and provides dynamic querying capabilities
+//the tags and the properties are stored in key/value tables, there is no schema change when a new key is found
 type resourceIndexer struct {
 	logger *zap.Logger
-	parser *rql.Parser
-	//fields that are indexed by FieldName
+	//dialect is the name of the database, ex: sqlite, postgres
+	dialect string
+	//fields that are stored as columns in the resources table, indexed by FieldName
 	fieldColumns fieldColumns
 }
 
-//fieldColumn maps a field name to a column name in the query table
+//fieldColumn maps a field name to a column name in the resources table
 type fieldColumn struct {
-	//Generated column name, ex: tag1, tag2 ...
-	ColumnName string `gorm:"primaryKey"`
-	//The actual field name: ex: tags.aws:ec2:fleet-id
+	//Column name, ex: account_id
+	ColumnName string
+	//The actual field name: ex: core.account_id
 	FieldName string
 }
 
 //key is field name
 type fieldColumns map[string]fieldColumn
 
+//virtualGroup is a field group which is not stored as columns but in a key/value table
+type virtualGroup struct {
+	table keyValueTable
+	//the name of the columns used in a query
+	columnFormat string
+}
+
 func newResourceIndexer(ctx context.Context, logger *zap.Logger, db *gorm.DB) (resourceIndexer, error) {
 	qb := resourceIndexer{}
 	qb.logger = logger
 	qb.fieldColumns = make(fieldColumns)
-	err := qb.rebuildDataModel(db)
-	return qb, err
+	for _, f := range model.CoreFields {
+		qb.fieldColumns.addExplicitFields(model.FieldGroupCore, f.Name)
+	}
+	if db == nil {
+		return qb, fmt.Errorf("no DB provided")
+	}
+	qb.dialect = db.Dialector.Name()
+	return qb, nil
+}
+
+//virtualGroups are the field groups which are queried from a key/value table
+var virtualGroups = map[string]virtualGroup{
+	model.FieldGroupTags:       {table: keyValueTable{name: tagsTable, keyColumn: "key"}, columnFormat: "tag_%v"},
+	model.FieldGroupProperties: {table: keyValueTable{name: propertiesTable, keyColumn: "path"}, columnFormat: "prop_%v"},
 }
 
 //an explicit field means that the column will be named after the field
@@ -59,19 +88,6 @@ func (f fieldColumns) addExplicitFields(group string, names ...string) {
 	}
 }
 
-//a dynamic field would have a generated column name
-func (f fieldColumns) addDynamicFields(group string, names ...string) bool {
-	newField := false
-	for _, name := range names {
-		fieldName := fieldName(group, name)
-		if _, found := f[fieldName]; !found {
-			f.addFieldColumn(fieldName, f.newColumnName())
-			newField = true
-		}
-	}
-	return newField
-}
-
 func (f fieldColumns) addFieldColumn(fieldName, columnName string) {
 	f[fieldName] = fieldColumn{
 		ColumnName: columnName,
@@ -83,137 +99,154 @@ func fieldName(group string, name string) string {
 	return fmt.Sprintf("%v.%v", group, name)
 }
 
-func (f fieldColumns) columnName(group string, name string) string {
-	if v, ok := f[fieldName(group, name)]; ok {
-		return v.ColumnName
+//lookup returns the column stored in the resources table for a field name
+func (f fieldColumns) lookup(name string) (fieldColumn, bool) {
+	if fieldCol, ok := f[name]; ok {
+		return fieldCol, true
 	}
-	return ""
+	//TODO remove this extra try once FE uses new field name convention
+	//this allows the query "type=ec2.Instance" to be equivalent to "core.type=ec2.Instance"
+	fieldCol, ok := f[fieldName(model.FieldGroupCore, name)]
+	return fieldCol, ok
 }
 
-//note: this code is inneficient but only done when a new tag key is found (unfrequent)
-func (f fieldColumns) containsColumnName(name string) bool {
-	for _, v := range f {
-		if v.ColumnName == name {
-			return true
+//dropLegacyTables drops the tables used when the tags were stored as columns
+//the tags are still stored in the tags table, no data is lost, the tables are kept if the datastore is read-only
+func (ri *resourceIndexer) dropLegacyTables(db *gorm.DB) error {
+	for _, table := range legacyTables {
+		if db.Migrator().HasTable(table) {
+			ri.logger.Sugar().Infof("dropping the table %v, it's no longer used", table)
+			if err := db.Migrator().DropTable(table); err != nil {
+				return fmt.Errorf("can't drop the table %v: %w", table, err)
+			}
 		}
 	}
-	return false
+	return nil
 }
 
-func (f fieldColumns) newColumnName() string {
-	//note: this code is inneficient but only done when a new tag key is found (unfrequent)
-	//return a column name that is not used
-	var name string
-	for i := 1; i == 1 || f.containsColumnName(name); i, name = i+1, fmt.Sprintf(columnDynamicName, i) {
-	}
-	return name
+//queryColumns holds the columns used by a query
+//the virtual columns are only known for the duration of the query: they are resolved against the key/value tables,
+//this way many instances sharing the same database always see the same fields
+type queryColumns struct {
+	ri *resourceIndexer
+	db *gorm.DB
+	//snap selects the tables to query: the current resources or a snapshot in the history
+	snap snapshot
+	//key is the column name
+	columns map[string]queryColumn
+	//virtual column name by field name
+	virtualFields map[string]string
+	//err is set if a column couldn't be resolved
+	err error
+}
+
+//queryColumn is a column used in a query
+type queryColumn struct {
+	name string
+	//virtual is set if the column is queried from a key/value table
+	virtual *virtualGroup
+	//key is the key to query in the key/value table, ex: the tag key or the property path
+	key string
 }
 
-func (f fieldColumns) asSlice() []fieldColumn {
-	result := make([]fieldColumn, 0, len(f))
-	for _, v := range f {
-		result = append(result, v)
+func (ri *resourceIndexer) newQueryColumns(db *gorm.DB, snap snapshot) *queryColumns {
+	return &queryColumns{
+		ri:            ri,
+		db:            db,
+		snap:          snap,
+		columns:       make(map[string]queryColumn),
+		virtualFields: make(map[string]string),
 	}
-	return result
 }
 
-//update a query field to map the datamodel
-//ex: "tags.aws:ec2:fleet-id" -> "col_3"
-func (ri *resourceIndexer) toColumnName(fieldName string) string {
-	if fieldCol, ok := ri.fieldColumns[fieldName]; ok {
+//toColumnName returns the column to use for a field name in the query
+//ex: "core.type" -> "type", "tags.aws:ec2:fleet-id" -> "tag_1", "properties.InstanceType" -> "prop_2"
+func (q *queryColumns) toColumnName(name string) string {
+	if fieldCol, ok := q.ri.fieldColumns.lookup(name); ok {
+		q.columns[fieldCol.ColumnName] = queryColumn{name: fieldCol.ColumnName}
 		return fieldCol.ColumnName
 	}
+	if columnName, found := q.virtualColumnName(name); found {
+		return columnName
+	}
 	//TODO remove this extra try once FE uses new field name convention
 	//this allows the query "team=consumer" to be equivalent to "tags.team=consumer"
-	for _, group := range []string{model.FieldGroupCore, model.FieldGroupTags} {
-		if fieldCol, ok := ri.fieldColumns[fmt.Sprintf("%v.%v", group, fieldName)]; ok {
-			return fieldCol.ColumnName
+	//a name starting with a field group is not a tag, ex: an unknown core field is reported as unknown
+	if group, _, _ := strings.Cut(name, "."); !isFieldGroup(group) {
+		if columnName, found := q.virtualColumnName(fieldName(model.FieldGroupTags, name)); found {
+			return columnName
 		}
 	}
-
 	//do not do any change - rql would throw an unknown field error
-	return fieldName
+	return name
 }
 
-//rebuildDataModel updates the query parser and the table schema to includes all the field names
-func (ri *resourceIndexer) rebuildDataModel(db *gorm.DB) error {
-	if db == nil {
-		return fmt.Errorf("no DB provided")
-	}
-	if len(ri.fieldColumns) >= maxColumns {
-		return fmt.Errorf("maximum number of colums reached: %d", maxColumns)
-	}
+//isFieldGroup returns true if the name is a field group: core, tags or properties
+func isFieldGroup(name string) bool {
+	return name == model.FieldGroupCore || name == model.FieldGroupTags || name == model.FieldGroupProperties
+}
 
-	if len(ri.fieldColumns) == 0 {
-		//first call
-		var fieldColumns []fieldColumn
-		tables, _ := db.Migrator().GetTables()
-		if slices.Contains(tables, "field_columns") {
-			//load existing fields from the DB
-			db.Find(&fieldColumns)
-		}
-		ri.fieldColumns = make(map[string]fieldColumn)
-		for _, field := range fieldColumns {
-			ri.fieldColumns[field.FieldName] = field
-		}
-		//always include these
-		ri.fieldColumns.addExplicitFields(model.FieldGroupCore, "id", "type", "region", "account_id")
-	}
+//virtualColumnName returns a column name for a field stored in a key/value table
+//any key is accepted: the sub-query of a key that no resource has returns no resource
+func (q *queryColumns) virtualColumnName(fieldName string) (string, bool) {
+	if columnName, found := q.virtualFields[fieldName]; found {
+		return columnName, true
+	}
+	groupName, key, found := strings.Cut(fieldName, ".")
+	group, virtual := virtualGroups[groupName]
+	if !found || !virtual || key == "" || q.err != nil {
+		return "", false
+	}
+	columnName := fmt.Sprintf(group.columnFormat, len(q.virtualFields)+1)
+	q.virtualFields[fieldName] = columnName
+	q.columns[columnName] = queryColumn{name: columnName, virtual: &group, key: key}
+	return columnName, true
+}
 
+//parser returns a parser that knows the columns used in the query
+func (q *queryColumns) parser() *rql.Parser {
+	names := make([]string, 0, len(q.columns))
+	for name := range q.columns {
+		names = append(names, name)
+	}
+	//id is always known, this allows to parse a query without any field
+	if _, found := q.columns["id"]; !found {
+		names = append(names, "id")
+	}
+	sort.Strings(names)
 	builder := dynamicstruct.NewStruct()
-	for _, fieldCol := range ri.fieldColumns {
-		structFieldName := cases.Title(language.AmericanEnglish).String(fieldCol.ColumnName)
-		if structFieldName == "Id" {
-			builder = builder.AddField(structFieldName, "", `gorm:"primaryKey" rql:"filter,sort"`)
-		} else {
-			builder = builder.AddField(structFieldName, "", `rql:"filter,sort"`)
-		}
+	for _, name := range names {
+		builder = builder.AddField(cases.Title(language.AmericanEnglish).String(name), "", `rql:"filter,sort"`)
 	}
-	//update builder and parse
-	resourceIndex := builder.Build().New()
-	ri.parser = rql.MustNewParser(rql.Config{
-		Model:         resourceIndex,
+	return rql.MustNewParser(rql.Config{
+		Model:         builder.Build().New(),
 		FieldSep:      ".",
 		DefaultLimit:  DefaultLimit,
 		LimitMaxValue: LimitMaxValue,
 	})
-
-	//migrate the data to have new columns
-	if err := db.Table(resourceIndexTable).AutoMigrate(resourceIndex); err != nil {
-		return err
-	}
-
-	//save the field column names
-	if err := db.AutoMigrate(&fieldColumn{}); err != nil {
-		return err
-	}
-	//update all fields columns
-	if err := db.Exec("DELETE FROM field_columns").Error; err != nil {
-		return err
-	}
-	if db := db.Create(ri.fieldColumns.asSlice()); db.Error != nil {
-		return db.Error
-	}
-	return nil
 }
 
 //updateQueryFields update the field name to use SQL column names
-func (ri *resourceIndexer) updateQueryFields(jsonQuery []byte) ([]byte, error) {
+func updateQueryFields(jsonQuery []byte, toColumnName func(string) string) ([]byte, error) {
 	var query map[string]interface{}
 	err := json.Unmarshal(jsonQuery, &query)
 	if err != nil {
 		return nil, err
 	}
+	//the query options are not rql fields
+	for _, key := range queryOptionKeys {
+		delete(query, key)
+	}
 	//update filter
 	if obj, ok := query["filter"]; ok {
 		if filter, ok := obj.(map[string]interface{}); ok {
-			query["filter"] = updateQueryFilter(filter, ri.toColumnName)
+			query["filter"] = updateQueryFilter(filter, toColumnName)
 		}
 	}
 	//update sort
 	if obj, ok := query["sort"]; ok {
 		if sort, ok := obj.([]interface{}); ok {
-			query["sort"] = updateQuerySort(sort, ri.toColumnName)
+			query["sort"] = updateQuerySort(sort, toColumnName)
 		}
 	}
 	jsonQuery, err = json.Marshal(query)
@@ -277,121 +310,174 @@ func updateQuerySort(sort []interface{}, f func(string) string) []interface{} {
 }
 
 func (ri *resourceIndexer) deleteResourceIndexes(db *gorm.DB, ids []model.ResourceId) error {
-	err := db.Table(resourceIndexTable).Where("id in ?", ids).Delete(ids).Error
+	err := db.Table(propertiesTable).Where("resource_id in ?", ids).Delete(ids).Error
 	if err != nil {
-		return fmt.Errorf("could not delete the resources indexes: %w", err)
-	}
-	return nil
-}
-
-//purgeUnusedColumns delete the unused columns so they can be used for other tag keys
-func (ri *resourceIndexer) purgeUnusedColumns(db *gorm.DB) error {
-	purged := false
-	for key, col := range ri.fieldColumns {
-		if !strings.HasPrefix(col.ColumnName, fmt.Sprintf(columnDynamicName, "")) {
-			//only remove dynamic columns
-			continue
-		}
-		//count the values defined for the current column
-		var count int64
-		err := db.Table(resourceIndexTable).Where(fmt.Sprintf("%v is not null", col.ColumnName)).Count(&count).Error
-		if err != nil {
-			return err
-		}
-		if count == 0 {
-			//this column has no value set, it can be removed
-			ri.logger.Sugar().Debugf("the column %v (tag: %v) will be removed from %v", col.ColumnName, col.FieldName, resourceIndexTable)
-			delete(ri.fieldColumns, key)
-			purged = true
-		}
-	}
-
-	if purged {
-		//only if something was changed we would rebuild the model
-		return ri.rebuildDataModel(db)
+		return fmt.Errorf("could not delete the resources properties: %w", err)
 	}
 	return nil
 }
 
-//writeResourceIndexes will insert a new resource_index row for each Resource
+//writeResourceIndexes will insert the properties of each Resource, the tags are written with the resources
 func (ri *resourceIndexer) writeResourceIndexes(ctx context.Context, db *gorm.DB, resources []*model.Resource) error {
-
-	//get all the possible tag keys - once per batch
-	rebuildModel := false
-	for _, r := range resources {
-		for _, tag := range r.Tags {
-			if ri.fieldColumns.addDynamicFields(model.FieldGroupTags, tag.Key) {
-				//new tag key found - need to rebuild the model
-				rebuildModel = true
-			}
-		}
-	}
-	if rebuildModel {
-		if err := ri.rebuildDataModel(db); err != nil {
-			return err
-		}
-	}
-
-	//create the rows to insert in memory
-	var rows []map[string]interface{}
-	uniqueIds := make(map[model.ResourceId]*model.Resource)
+	var properties model.Properties
 	var ids []model.ResourceId
 	for _, r := range resources {
-		row := make(map[string]interface{})
-		id := model.ResourceId(r.Id)
-		if rExist, found := uniqueIds[id]; found {
-			ri.logger.Warn("Found duplicate key",
-				zap.String("key", string(id)),
-				zap.Object("resource 1", rExist),
-				zap.Object("resource 2", r),
-			)
-			continue
+		resourceProperties, err := r.Properties()
+		if err != nil {
+			return err
 		}
-		uniqueIds[id] = r
+		properties = append(properties, resourceProperties...)
 		ids = append(ids, model.ResourceId(r.Id))
-		row["id"] = r.Id
-		row["type"] = r.Type
-		row["region"] = r.Region
-		row["account_id"] = r.AccountId
-		//add the tags
-		for _, tag := range r.Tags {
-			//ex: row["Col23"]="Team"
-			row[ri.fieldColumns.columnName(model.FieldGroupTags, tag.Key)] = tag.Value
-		}
-		rows = append(rows, row)
 	}
 
-	//delete all previous resource_index if they exist
+	//delete all previous properties if they exist
 	if err := ri.deleteResourceIndexes(db, ids); err != nil {
 		return err
 	}
 
-	//create the resource_index
-	if err := db.Table(resourceIndexTable).Create(rows).Error; err != nil {
-		return fmt.Errorf("could not create the resource indexes: %w", err)
+	//create the properties - a resource can have many, use smaller batches
+	if len(properties) > 0 {
+		if err := db.Table(propertiesTable).CreateInBatches(uniqueProperties(properties), propertiesBatchSize).Error; err != nil {
+			return fmt.Errorf("could not create the resource properties: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func (ri *resourceIndexer) parse(jsonQuery []byte) (*rql.Params, error) {
+//uniqueProperties removes the properties with the same key, this happens when a batch contains duplicate resources
+func uniqueProperties(properties model.Properties) model.Properties {
+	unique := make(map[model.Property]struct{}, len(properties))
+	result := make(model.Properties, 0, len(properties))
+	for _, p := range properties {
+		if _, found := unique[p]; !found {
+			unique[p] = struct{}{}
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (ri *resourceIndexer) parse(db *gorm.DB, jsonQuery []byte, snap snapshot) (*rql.Params, error) {
 	ri.logger.Sugar().Debugw("received",
 		zap.String("query", string(jsonQuery)),
 	)
 	// update query field names to map to the data model
-	jsonQuery, err := ri.updateQueryFields(jsonQuery)
+	columns := ri.newQueryColumns(db, snap)
+	jsonQuery, err := updateQueryFields(jsonQuery, columns.toColumnName)
 	if err != nil {
 		return nil, err
 	}
+	if columns.err != nil {
+		return nil, columns.err
+	}
 	ri.logger.Sugar().Debugw("updated",
 		zap.String("query", string(jsonQuery)),
 	)
-	params, err := ri.parser.Parse(jsonQuery)
+	params, err := columns.parser().Parse(jsonQuery)
 	if err != nil {
 		return nil, err
 	}
+	//the virtual columns are not stored in the table, query them with a sub-query
+	params = ri.replaceVirtualFields(params, columns)
 	//hand null values
-	return replaceNullValues(params), nil
+	params = replaceNullValues(params)
+	if ri.dialect == "postgres" {
+		params.FilterExp = nullComparisonReplacer.Replace(params.FilterExp)
+	}
+	return params, nil
+}
+
+//replaceVirtualFields updates the filter and the sort to query the virtual columns in their key/value table
+//ex: "prop_3 = ?" -> "id IN (SELECT resource_id FROM properties WHERE path = ? AND value = ?)"
+func (ri *resourceIndexer) replaceVirtualFields(p *rql.Params, columns *queryColumns) *rql.Params {
+	parts := strings.Split(p.FilterExp, "?")
+	var filterExp strings.Builder
+	var filterArgs []interface{}
+	for i, arg := range p.FilterArgs {
+		part := parts[i]
+		match := virtualFilterRegexp.FindStringSubmatchIndex(part)
+		var col queryColumn
+		if match != nil {
+			col = columns.columns[part[match[2]:match[3]]]
+		}
+		if col.virtual == nil {
+			filterExp.WriteString(part + "?")
+			filterArgs = append(filterArgs, arg)
+			continue
+		}
+		table := col.virtual.table
+		table.name = columns.snap.table(table.name)
+		rowId := columns.snap.rowId()
+		op := part[match[4]:match[5]]
+		filterExp.WriteString(part[:match[0]])
+		switch arg {
+		case model.FieldMissing:
+			filterExp.WriteString(fmt.Sprintf("%v NOT IN (SELECT resource_id FROM %v WHERE %v = ?)", rowId, table.name, table.keyColumn))
+			filterArgs = append(filterArgs, col.key)
+		case model.FieldPresent:
+			filterExp.WriteString(fmt.Sprintf("%v IN (SELECT resource_id FROM %v WHERE %v = ?)", rowId, table.name, table.keyColumn))
+			filterArgs = append(filterArgs, col.key)
+		default:
+			//the values are stored as text, they are compared as numbers if the operand is a number, ex: "10" > "9"
+			if number, ok := numericOperand(op, arg); ok {
+				filterExp.WriteString(fmt.Sprintf("%v IN (SELECT resource_id FROM %v WHERE %v = ? AND %v %v ?)", rowId, table.name, table.keyColumn, ri.numericValue(), op))
+				filterArgs = append(filterArgs, col.key, ri.numericPattern(), number)
+				continue
+			}
+			filterExp.WriteString(fmt.Sprintf("%v IN (SELECT resource_id FROM %v WHERE %v = ? AND value %v ?)", rowId, table.name, table.keyColumn, op))
+			filterArgs = append(filterArgs, col.key, arg)
+		}
+	}
+	filterExp.WriteString(parts[len(p.FilterArgs)])
+	p.FilterExp = filterExp.String()
+	p.FilterArgs = filterArgs
+
+	//sort: use the smallest value for the key
+	if p.Sort != "" {
+		sorts := strings.Split(p.Sort, ", ")
+		for i, sort := range sorts {
+			columnName, direction, _ := strings.Cut(sort, " ")
+			if col := columns.columns[columnName]; col.virtual != nil {
+				table := col.virtual.table
+				table.name = columns.snap.table(table.name)
+				sorts[i] = strings.TrimSpace(fmt.Sprintf("(SELECT MIN(value) FROM %v WHERE %v.resource_id = %v.%v AND %v.%v = %v) %v",
+					table.name, table.name, resourcesTable, columns.snap.rowId(), table.name, table.keyColumn, quoteString(col.key), direction))
+			}
+		}
+		p.Sort = strings.Join(sorts, ", ")
+	}
+	return p
+}
+
+//numericOperand returns the number of an ordering comparison, ex: {"$gt": "9"}
+func numericOperand(op string, arg interface{}) (float64, bool) {
+	s, ok := arg.(string)
+	if !ok || (op != "<" && op != "<=" && op != ">" && op != ">=") {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return number, err == nil
+}
+
+//numericValue returns the expression converting a value to a number, the values which are not numbers are null
+//the first argument of the expression is the pattern of the numbers returned by numericPattern
+func (ri *resourceIndexer) numericValue() string {
+	if ri.dialect == "postgres" {
+		return "(CASE WHEN value ~ ? THEN CAST(value AS DOUBLE PRECISION) END)"
+	}
+	//SQLite converts any text to a number, ex: "abc" is 0, the REGEXP function is registered with the driver
+	return "(CASE WHEN value REGEXP ? THEN CAST(value AS REAL) END)"
+}
+
+//numericPattern returns the pattern of the numbers used by numericValue, it is the same on all the dialects
+func (ri *resourceIndexer) numericPattern() string {
+	return `^[-+]?([0-9]+[.]?[0-9]*|[.][0-9]+)([eE][-+]?[0-9]+)?$`
+}
+
+//quoteString returns a SQL string literal, it should only be used when a query argument can't be used
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
 
 func replaceNullValues(p *rql.Params) *rql.Params {
@@ -418,12 +504,13 @@ func replaceWith(s string, old string, new string, sep string, i int) string {
 
 //findResourceIds finds resources using a RQL query
 //see https://github.com/a8m/rql#getting-started for the syntax
-func (ri *resourceIndexer) findResourceIds(db gorm.DB, logger *zap.Logger, jsonQuery []byte, paginated bool) ([]model.ResourceId, int, error) {
+//snap selects the current resources or the resources at a point in time, the ids of the resources at a point in time are their version ids
+func (ri *resourceIndexer) findResourceIds(db gorm.DB, logger *zap.Logger, jsonQuery []byte, paginated bool, snap snapshot) ([]model.ResourceId, int, error) {
 	if len(jsonQuery) == 0 {
 		//use en empty json if nothing is set - this will use the default limit
 		jsonQuery = []byte(`{}`)
 	}
-	p, err := ri.parse(jsonQuery)
+	p, err := ri.parse(&db, jsonQuery, snap)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -431,16 +518,16 @@ func (ri *resourceIndexer) findResourceIds(db gorm.DB, logger *zap.Logger, jsonQ
 	var resourceIds []model.ResourceId
 	var result *gorm.DB
 	if paginated {
-		result = db.Table(resourceIndexTable).
-			Select("id").
+		result = snap.resources(&db).
+			Select(snap.rowId()).
 			Where(p.FilterExp, p.FilterArgs...).
 			Offset(p.Offset).
 			Limit(p.Limit).
 			Order(p.Sort).
 			Find(&resourceIds)
 	} else {
-		result = db.Table(resourceIndexTable).
-			Select("id").
+		result = snap.resources(&db).
+			Select(snap.rowId()).
 			Where(p.FilterExp, p.FilterArgs...).
 			Order(p.Sort).
 			Find(&resourceIds)
@@ -451,7 +538,7 @@ func (ri *resourceIndexer) findResourceIds(db gorm.DB, logger *zap.Logger, jsonQ
 	}
 
 	var count int64
-	resultCount := db.Table(resourceIndexTable).
+	resultCount := snap.resources(&db).
 		Select("id").
 		Where(p.FilterExp, p.FilterArgs...).
 		Count(&count)
@@ -461,3 +548,32 @@ func (ri *resourceIndexer) findResourceIds(db gorm.DB, logger *zap.Logger, jsonQ
 	}
 	return resourceIds, int(count), nil
 }
+
+//countManagedResources counts the resources matching a RQL query by type, account and managing tool
+//the limit and the offset are ignored, the resources of the Terraform states identified by their state are declarations of scanned resources, they are skipped
+func (ri *resourceIndexer) countManagedResources(db gorm.DB, jsonQuery []byte, snap snapshot) ([]model.ManagedResources, error) {
+	if len(jsonQuery) == 0 {
+		jsonQuery = []byte(`{}`)
+	}
+	p, err := ri.parse(&db, jsonQuery, snap)
+	if err != nil {
+		return nil, err
+	}
+	/*
+		SELECT type, account_id, managed_by, count(*) AS count
+		FROM resources
+		WHERE id NOT LIKE 'terraform:%' AND ...
+		GROUP BY type, account_id, managed_by
+	*/
+	var counts []model.ManagedResources
+	err = snap.resources(&db).
+		Select("type, account_id, managed_by, count(*) AS count").
+		Where("id NOT LIKE ?", model.TerraformStateIdPrefix+"%").
+		Where(p.FilterExp, p.FilterArgs...).
+		Group("type, account_id, managed_by").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -2,12 +2,16 @@
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/a8m/rql"
 	"github.com/juandiegopalomino/cloudgrep/pkg/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 type testCase struct {
@@ -16,13 +20,16 @@ type testCase struct {
 
 func TestUpdateQueryFields(t *testing.T) {
 
-	ri, err := newResourceIndexer(context.Background(), zaptest.NewLogger(t), nil)
-	//for this test we don't have a DB, we can still proceed
-	assert.Error(t, err, "no DB provided")
-	ri.fieldColumns.addExplicitFields("core", "type", "region", "id")
-	assert.True(t,
-		ri.fieldColumns.addDynamicFields("tags", "aws:ec2:fleet-id", "team-name", "cluster", "env"),
-	)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	ri, err := newResourceIndexer(context.Background(), zaptest.NewLogger(t), db)
+	require.NoError(t, err)
+	//the tag keys are found in the tags table
+	tagKeys := []string{"aws:ec2:fleet-id", "team-name", "cluster", "env"}
+	require.NoError(t, db.AutoMigrate(&model.Tag{}))
+	for _, key := range tagKeys {
+		require.NoError(t, db.Create(&model.Tag{ResourceId: "i-123", Key: key, Value: "value"}).Error)
+	}
 
 	testCases := []testCase{
 		{`{
@@ -38,10 +45,10 @@ func TestUpdateQueryFields(t *testing.T) {
   "limit":5,
   "filter":{
     "type":"ec2.Instance",
-    "col_1":"fleet-bafee5d7-215d-addb-2632-290ab09da4e7"
+    "tag_1":"fleet-bafee5d7-215d-addb-2632-290ab09da4e7"
   },
   "sort":[
-    "-col_1"
+    "-tag_1"
   ]
 }`},
 		{`{
@@ -69,22 +76,36 @@ func TestUpdateQueryFields(t *testing.T) {
   "filter":{
     "type":"ec2.Volume",
     "$or": [
-      { "col_2": "marketplace" },
-      { "col_2": "shipping" }
+      { "tag_2": "marketplace" },
+      { "tag_2": "shipping" }
     ]
   }
 }`,
 		},
+		//an unknown core field is not updated, rql rejects it
 		{
 			`{
   "filter":{
-    "tags.unknown-field":"ec2.Volume"
+    "core.unknown-field":"ec2.Volume"
   }
 }`,
 			`{
+  "filter":{
+    "core.unknown-field":"ec2.Volume"
+  }
+}`,
+		},
+		//a tag key that no resource has is queried like the other tags
+		{
+			`{
   "filter":{
     "tags.unknown-field":"ec2.Volume"
   }
+}`,
+			`{
+  "filter":{
+    "tag_5":"ec2.Volume"
+  }
 }`,
 		},
 		//only filter and sort are updated
@@ -125,17 +146,17 @@ func TestUpdateQueryFields(t *testing.T) {
   "filter":{
     "type":"ec2.Volume",
     "$or": [
-      { "col_2": "marketplace" },
-      { "col_2": "shipping" }
+      { "tag_2": "marketplace" },
+      { "tag_2": "shipping" }
     ],
 	"$and": [
 		{ "$or": [
-			{ "col_3": "dev" },
-			{ "col_3": "prod" }
+			{ "tag_3": "dev" },
+			{ "tag_3": "prod" }
 		] },
 		{ "$or": [
-			{ "col_4": "staging" },
-			{ "col_4": "prod" }
+			{ "tag_4": "staging" },
+			{ "tag_4": "prod" }
 		] }
 	]
   }
@@ -144,7 +165,12 @@ func TestUpdateQueryFields(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		actual, err := ri.updateQueryFields([]byte(tc.input.(string)))
+		//resolve the tags in order to have predictable column names: tag_1, tag_2...
+		columns := ri.newQueryColumns(db, snapshot{})
+		for i, key := range tagKeys {
+			require.Equal(t, fmt.Sprintf("tag_%d", i+1), columns.toColumnName("tags."+key))
+		}
+		actual, err := updateQueryFields([]byte(tc.input.(string)), columns.toColumnName)
 		assert.NoError(t, err)
 		assert.JSONEq(t, tc.output.(string), string(actual))
 	}
@@ -191,3 +217,88 @@ func TestReplaceNullValues(t *testing.T) {
 		assert.EqualValues(t, tc.OutFilterArgs, outParams.FilterArgs)
 	}
 }
+
+func TestReplaceVirtualFields(t *testing.T) {
+	ri, err := newResourceIndexer(context.Background(), zaptest.NewLogger(t), nil)
+	//for this test we don't have a DB, we can still proceed
+	assert.Error(t, err, "no DB provided")
+
+	//simulate the columns resolved for a query
+	columns := ri.newQueryColumns(nil, snapshot{})
+	properties, tags := virtualGroups[model.FieldGroupProperties], virtualGroups[model.FieldGroupTags]
+	columns.columns["type"] = queryColumn{name: "type"}
+	columns.columns["prop_1"] = queryColumn{name: "prop_1", virtual: &properties, key: "InstanceType"}
+	columns.columns["prop_2"] = queryColumn{name: "prop_2", virtual: &properties, key: "SecurityGroups[*].GroupId"}
+	columns.columns["tag_3"] = queryColumn{name: "tag_3", virtual: &tags, key: "team"}
+
+	testCases := []struct {
+		InFilterExp   string
+		InFilterArgs  []interface{}
+		InSort        string
+		OutFilterExp  string
+		OutFilterArgs []interface{}
+		OutSort       string
+	}{
+		{
+			InFilterExp:   "region = ? AND type = ?",
+			InFilterArgs:  []interface{}{"us-east-1", "ec2.Instance"},
+			InSort:        "region desc",
+			OutFilterExp:  "region = ? AND type = ?",
+			OutFilterArgs: []interface{}{"us-east-1", "ec2.Instance"},
+			OutSort:       "region desc",
+		},
+		{
+			InFilterExp:   "tag_3 = ? AND type = ?",
+			InFilterArgs:  []interface{}{"infra", "ec2.Instance"},
+			InSort:        "tag_3",
+			OutFilterExp:  "id IN (SELECT resource_id FROM tags WHERE key = ? AND value = ?) AND type = ?",
+			OutFilterArgs: []interface{}{"team", "infra", "ec2.Instance"},
+			OutSort:       "(SELECT MIN(value) FROM tags WHERE tags.resource_id = resources.id AND tags.key = 'team')",
+		},
+		{
+			InFilterExp:   "prop_1 = ? AND type = ?",
+			InFilterArgs:  []interface{}{"t3.small", "ec2.Instance"},
+			OutFilterExp:  "id IN (SELECT resource_id FROM properties WHERE path = ? AND value = ?) AND type = ?",
+			OutFilterArgs: []interface{}{"InstanceType", "t3.small", "ec2.Instance"},
+		},
+		{
+			InFilterExp:   "(prop_2 = ? OR prop_2 LIKE ?) AND prop_1 = ?",
+			InFilterArgs:  []interface{}{"sg-1", "sg-2%", model.FieldMissing},
+			OutFilterExp:  "(id IN (SELECT resource_id FROM properties WHERE path = ? AND value = ?) OR id IN (SELECT resource_id FROM properties WHERE path = ? AND value LIKE ?)) AND id NOT IN (SELECT resource_id FROM properties WHERE path = ?)",
+			OutFilterArgs: []interface{}{"SecurityGroups[*].GroupId", "sg-1", "SecurityGroups[*].GroupId", "sg-2%", "InstanceType"},
+		},
+		{
+			InFilterExp:   "prop_1 = ?",
+			InFilterArgs:  []interface{}{model.FieldPresent},
+			InSort:        "prop_1 desc, type",
+			OutFilterExp:  "id IN (SELECT resource_id FROM properties WHERE path = ?)",
+			OutFilterArgs: []interface{}{"InstanceType"},
+			OutSort:       "(SELECT MIN(value) FROM properties WHERE properties.resource_id = resources.id AND properties.path = 'InstanceType') desc, type",
+		},
+	}
+	for _, tc := range testCases {
+		inParams := rql.Params{
+			FilterExp:  tc.InFilterExp,
+			FilterArgs: tc.InFilterArgs,
+			Sort:       tc.InSort,
+		}
+		outParams := ri.replaceVirtualFields(&inParams, columns)
+		assert.Equal(t, tc.OutFilterExp, outParams.FilterExp)
+		assert.EqualValues(t, tc.OutFilterArgs, outParams.FilterArgs)
+		assert.Equal(t, tc.OutSort, outParams.Sort)
+	}
+}
+
+func TestDropLegacyTables(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	//simulate a DB created when the tags were stored as columns
+	require.NoError(t, db.Exec("CREATE TABLE resource_index (id text, col_1 text)").Error)
+	require.NoError(t, db.Exec("CREATE TABLE field_columns (column_name text, field_name text)").Error)
+
+	ri, err := newResourceIndexer(context.Background(), zaptest.NewLogger(t), db)
+	require.NoError(t, err)
+	require.NoError(t, ri.dropLegacyTables(db))
+	assert.False(t, db.Migrator().HasTable("resource_index"))
+	assert.False(t, db.Migrator().HasTable("field_columns"))
+}
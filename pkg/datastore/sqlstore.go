@@ -0,0 +1,937 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/util"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	//how many resources can be inserted at one time
+	//the main concern is Maximum Length Of An SQL Statement (1,000,000 bytes)
+	//https://www.sqlite.org/limits.html
+	//this is also well below the maximum number of parameters for Postgres (65535)
+	batchSize = 100
+	//how many resource tags or properties can be inserted at one time
+	//a resource can have many, the main concern is the maximum number of variables in a statement (32766 for SQLite)
+	tagsBatchSize       = 1000
+	propertiesBatchSize = 1000
+	//how many resources can be read at one time, the ids are sent as variables
+	readBatchSize = 1000
+	//a property is only returned as a field if it has less distinct values than this limit
+	//the properties with many values (ids, ip addresses, dates...) can still be queried
+	maxPropertyFieldValues = 100
+)
+
+//sqlStore implements the Datastore interface for a SQL database using gorm
+//the database specific logic is done by the store embedding it: SQLiteStore or PostgresStore
+type sqlStore struct {
+	logger  *zap.Logger
+	db      *gorm.DB
+	indexer resourceIndexer
+	//fetchedAt is the last time the resources were fetched
+	fetchedAt time.Time
+	//fetchedAccounts are the accounts scanned since fetchedAt, from the resource events and the resources written
+	//an account whose fetches succeeded without any resource is scanned too, its previous resources are deleted
+	fetchedAccounts map[string]struct{}
+	//failedFetches are the resource types which failed, timed out or couldn't be written since fetchedAt, by account and region
+	//their resources may have been partially written so the stale ones are kept
+	failedFetches map[fetchKey]struct{}
+	lock          sync.Mutex
+	runId         string
+	//historyRetention is how long the previous versions of the resources are kept, 0 if the history is disabled
+	historyRetention time.Duration
+	//readOnly rejects the writes, the schema is not migrated
+	readOnly bool
+	//propertyPaths are the paths of the properties returned as fields by table, they are computed once per run
+	propertyPaths     map[string][]string
+	propertyPathsLock sync.Mutex
+}
+
+//errReadOnly is returned when writing to a read-only datastore
+var errReadOnly = errors.New("the datastore is read-only")
+
+//tables are the models stored in the database
+var tables = []interface{}{&model.Resource{}, &model.Tag{}, &model.Property{}, &model.Event{},
+	&resourceVersion{}, &tagVersion{}, &propertyVersion{}, &complianceReport{}}
+
+//newGormLogger returns the logger used for the SQL queries
+func newGormLogger(zapLogger *zap.Logger) logger.Interface {
+	logLevel := logger.Error
+	if zapLogger.Core().Enabled(zap.DebugLevel) {
+		//log all SQL queries
+		logLevel = logger.Info
+	}
+	//gormLogger has it's own logger for SQL queries - better than zaplog for that purpose
+	return logger.New(
+		log.New(os.Stdout, "\r\n", log.LstdFlags),
+		logger.Config{
+			SlowThreshold:             time.Second,
+			LogLevel:                  logLevel,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  true,
+		},
+	)
+}
+
+//init migrates the schema and creates the indexer
+func (s *sqlStore) init(ctx context.Context, db *gorm.DB, cfg config.Datastore, zapLogger *zap.Logger) error {
+	s.logger = zapLogger
+	s.db = db
+	s.historyRetention = cfg.History.Retention
+	s.readOnly = cfg.ReadOnly
+
+	//create the indexer
+	var err error
+	s.indexer, err = newResourceIndexer(ctx, s.logger, s.db)
+	if err != nil {
+		return fmt.Errorf("can't create the query builder: %w", err)
+	}
+
+	if s.readOnly {
+		//the schema must have been created by a previous run, it is not migrated
+		return s.checkSchema()
+	}
+	// Migrate the schema
+	if err := s.db.AutoMigrate(tables...); err != nil {
+		return fmt.Errorf("can't create the data model: %w", err)
+	}
+	return s.indexer.dropLegacyTables(s.db)
+}
+
+//checkSchema checks that the tables and their columns exist, ex: the database was created by an older version of cloudgrep
+func (s *sqlStore) checkSchema() error {
+	migrator := s.db.Migrator()
+	for _, table := range tables {
+		if !migrator.HasTable(table) {
+			return fmt.Errorf("can't open the database read-only: missing table for %T, the database must be created by cloudgrep first", table)
+		}
+		stmt := &gorm.Statement{DB: s.db}
+		if err := stmt.Parse(table); err != nil {
+			return fmt.Errorf("can't read the data model: %w", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(table, field.DBName) {
+				return fmt.Errorf("can't open the database read-only: missing column %v in table %v, the database was created by an older version of cloudgrep, open it once without read-only to upgrade it",
+					field.DBName, stmt.Schema.Table)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) Ping() error {
+	db, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return db.Ping()
+}
+
+func (s *sqlStore) getResourcesById(ctx context.Context, ids []model.ResourceId) ([]*model.Resource, error) {
+	resources := make([]*model.Resource, 0)
+	if len(ids) == 0 {
+		return resources, nil
+	}
+	db := s.db.Preload("Tags").Find(&resources, ids)
+
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	return sortResources(resources, ids), nil
+
+}
+
+//sortResources returns the resources in the same order as the ids (the order of a query)
+func sortResources(resources []*model.Resource, ids []model.ResourceId) []*model.Resource {
+	resourcesById := make(map[model.ResourceId]*model.Resource, len(resources))
+	for _, r := range resources {
+		resourcesById[model.ResourceId(r.Id)] = r
+	}
+	result := make([]*model.Resource, 0, len(resources))
+	for _, id := range ids {
+		if r, found := resourcesById[id]; found {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+func (s *sqlStore) GetResource(ctx context.Context, id string) (*model.Resource, error) {
+	resources, err := s.getResourcesById(ctx, []model.ResourceId{model.ResourceId(id)})
+	if err != nil {
+		return nil, fmt.Errorf("can't get resource from database: %w", err)
+	}
+	if len(resources) == 0 {
+		//not found
+		return nil, nil
+	}
+	s.logger.Sugar().Infow("Getting resource: ",
+		zap.String("id", id),
+	)
+	return resources[0], nil
+}
+
+func (s *sqlStore) WriteResources(ctx context.Context, resources model.Resources) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	if len(resources) == 0 {
+		//nothing to write
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.fetchedAccounts == nil {
+		s.fetchedAccounts = make(map[string]struct{})
+	}
+	for _, r := range resources {
+		s.fetchedAccounts[r.AccountId] = struct{}{}
+	}
+
+	now := time.Now().UTC()
+	//Postgres rejects an upsert updating the same row twice, so a resource present more than once is only written once
+	batches := util.Chunks(uniqueResources(resources), batchSize)
+	for i, batch := range batches {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			//delete all the previously stored tags if any
+			ids := model.ResourceIds(batch)
+			if err := deleteTags(tx, ids); err != nil {
+				return err
+			}
+
+			// Create or Update the resource rows
+			result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{UpdateAll: true}).Create(batch)
+			s.logger.Sugar().Infow("Writting resources: ", zap.Int64("count", result.RowsAffected))
+			if result.Error != nil {
+				return result.Error
+			}
+
+			// Create the tags
+			if err := writeTags(tx, batch); err != nil {
+				return err
+			}
+
+			// Keep the new versions of the resources
+			if s.historyEnabled() {
+				if err := s.writeVersions(tx, batch, now); err != nil {
+					return err
+				}
+			}
+
+			// Create or Update the resource indexes
+			if err := s.indexer.writeResourceIndexes(ctx, tx, batch); err != nil {
+				return err
+			}
+			return result.Error
+		})
+		if err != nil {
+			//the resources not written keep their previous update time, they must not be deleted at the end of the run
+			s.writeFailed(batches[i:])
+			return fmt.Errorf("can't write resources to database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+//writeFailed records the resource types of the resources which couldn't be written as failed fetches
+func (s *sqlStore) writeFailed(batches [][]*model.Resource) {
+	if s.failedFetches == nil {
+		s.failedFetches = make(map[fetchKey]struct{})
+	}
+	for _, batch := range batches {
+		for _, r := range batch {
+			s.failedFetches[fetchKey{accountId: r.AccountId, region: r.Region, resourceType: r.Type}] = struct{}{}
+		}
+	}
+}
+
+//uniqueResources removes the resources with the same id, the first one is kept like the tags and the versions
+func uniqueResources(resources model.Resources) model.Resources {
+	unique := make(map[string]struct{}, len(resources))
+	result := make(model.Resources, 0, len(resources))
+	for _, r := range resources {
+		if _, found := unique[r.Id]; !found {
+			unique[r.Id] = struct{}{}
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+func resourceCount(db *gorm.DB) (int, error) {
+	var count int64
+	return int(count), db.Table("resources").Count(&count).Error
+}
+
+func (s *sqlStore) Stats(context.Context) (model.Stats, error) {
+	count, err := resourceCount(s.db)
+	if err != nil {
+		return model.Stats{}, fmt.Errorf("can't read stats: %w", err)
+	}
+	return model.Stats{ResourcesCount: count}, nil
+}
+
+func (s *sqlStore) getResourceField(columnName string, ids []model.ResourceId, snap snapshot) (model.Field, error) {
+	/*
+		SELECT DISTINCT `type` , count(*) as count
+		FROM `resources`
+		group by  `type`
+		order by `type`
+		sort by `count` desc
+	*/
+	query := snap.resources(s.db).
+		Select(columnName, "count(*) as count").
+		Distinct().
+		Group(columnName).
+		Order("count desc")
+	rows, err := query.Rows()
+	if err != nil {
+		return model.Field{}, fmt.Errorf("can't get resource field '%v' from database: %w", columnName, err)
+	}
+	defer rows.Close()
+	field := model.Field{
+		Name: columnName,
+	}
+
+	//used to update count later
+	var totalCount int
+	//use a map for efficient access the field value by their value
+	fieldValuesMap := make(map[string]*model.FieldValue)
+
+	for rows.Next() {
+		var value string
+		var count int
+		err = rows.Scan(&value, &count)
+		if err != nil {
+			return model.Field{}, fmt.Errorf("can't get resource field '%v' from database: %w", columnName, err)
+		}
+		fieldValue := model.FieldValue{
+			Value: value,
+		}
+		if len(ids) == 0 {
+			//we don't need to go back to the DB
+			fieldValue.Count = fmt.Sprint(count)
+			totalCount = totalCount + count
+		} else {
+			//the count would be updated based on filter
+			fieldValue.Count = model.CountValueIgnored
+		}
+		field.Values = append(field.Values, &fieldValue)
+		fieldValuesMap[value] = &fieldValue
+	}
+
+	//if there is some ids - do another call to update the counts for the current query
+	if len(ids) > 0 {
+		rows, err = query.Where(snap.rowId()+" in ?", ids).Rows()
+		if err != nil {
+			return model.Field{}, fmt.Errorf("can't get resource field '%v' from database: %w", columnName, err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var value string
+			var count int
+			err = rows.Scan(&value, &count)
+			if err != nil {
+				return model.Field{}, fmt.Errorf("can't get resource field '%v' from database: %w", columnName, err)
+			}
+			fieldValuesMap[value].Count = fmt.Sprint(count)
+			totalCount = totalCount + count
+		}
+	}
+
+	field.Count = totalCount
+	return field, nil
+}
+
+//return the list of tag fields sorted by most popular
+func (s *sqlStore) getTagFields(ids []model.ResourceId, snap snapshot) (model.Fields, error) {
+	return s.getKeyValueFields(snap.keyValueTable(keyValueTable{name: "tags", keyColumn: "key"}), ids)
+}
+
+//return the list of property fields sorted by most popular
+//the properties with too many distinct values are not returned
+func (s *sqlStore) getPropertyFields(ids []model.ResourceId, snap snapshot) (model.Fields, error) {
+	table := snap.keyValueTable(keyValueTable{name: propertiesTable, keyColumn: "path"})
+	paths, err := s.getPropertyPaths(table.name)
+	if err != nil {
+		return nil, err
+	}
+	table.filters = append(table.filters, "path in ?")
+	table.filterArgs = append(table.filterArgs, paths)
+	return s.getKeyValueFields(table, ids)
+}
+
+//getPropertyPaths returns the paths of the properties with less distinct values than maxPropertyFieldValues
+//the whole table is scanned so the paths are cached until the end of the run
+func (s *sqlStore) getPropertyPaths(table string) ([]string, error) {
+	s.propertyPathsLock.Lock()
+	defer s.propertyPathsLock.Unlock()
+	if paths, ok := s.propertyPaths[table]; ok {
+		return paths, nil
+	}
+	paths := make([]string, 0)
+	err := s.db.Table(table).
+		Group("path").
+		Having("count(distinct value) <= ?", maxPropertyFieldValues).
+		Pluck("path", &paths).Error
+	if err != nil {
+		return nil, fmt.Errorf("can't get the %v paths from database: %w", table, err)
+	}
+	if s.propertyPaths == nil {
+		s.propertyPaths = make(map[string][]string)
+	}
+	s.propertyPaths[table] = paths
+	return paths, nil
+}
+
+//clearPropertyPaths removes the cached paths of the properties, they are computed again on the next read
+func (s *sqlStore) clearPropertyPaths() {
+	s.propertyPathsLock.Lock()
+	defer s.propertyPathsLock.Unlock()
+	s.propertyPaths = nil
+}
+
+//keyValueTable is a table storing key/value pairs for the resources, ex: tags
+type keyValueTable struct {
+	name      string
+	keyColumn string
+	//optional conditions to select the rows, ex: the keys to return
+	filters    []string
+	filterArgs []interface{}
+}
+
+func (t keyValueTable) where(conditions ...string) string {
+	conditions = append(conditions, t.filters...)
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ") + " "
+}
+
+//return the list of fields for a key/value table sorted by most popular
+func (s *sqlStore) getKeyValueFields(table keyValueTable, ids []model.ResourceId) (model.Fields, error) {
+
+	// the tricky part of this function is to always return the same fields containing all the values but with different count
+	// the fields are always visible to the user and are ordered by popularity - if we would change this list for every call the UI would look shaky
+
+	// so this is implemented in 3 steps:
+	//1. get all keys sorted by most frequent first
+	//2. get all values sorted by most frequent first
+	//3. update count to reflect the current query, which relates to subset of resources (ids params)
+
+	// Steps 1 & 2 ensure that all fields and values are returned in the same order
+	// Step 3 updates the count values
+
+	//1.  get all keys sorted by most frequent first
+	rows, err := s.db.Raw(fmt.Sprintf("SELECT %v, count(distinct resource_id) as count FROM %v %vgroup by %v order by count desc",
+		table.keyColumn, table.name, table.where(), table.keyColumn), table.filterArgs...).Rows()
+	if err != nil {
+		return model.Fields{}, fmt.Errorf("can't get %v keys from database: %w", table.name, err)
+	}
+	var fields model.Fields
+	//use a map for efficient access (the model is a slice)
+	mapFields := make(map[string]*model.Field)
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var count int
+		err = rows.Scan(&key, &count)
+		if err != nil {
+			return nil, err
+		}
+		field := model.Field{
+			Name: key,
+		}
+		if len(ids) == 0 {
+			//the current count is for all resources, use it
+			field.Count = count
+		}
+		mapFields[key] = &field
+		fields = append(fields, &field)
+	}
+
+	//2.  get all values sorted by most frequent first
+	rows, err = s.db.Raw(fmt.Sprintf("SELECT %v, value, count(distinct resource_id) as count FROM %v %vgroup by %v, value",
+		table.keyColumn, table.name, table.where(), table.keyColumn), table.filterArgs...).Rows()
+	if err != nil {
+		return model.Fields{}, fmt.Errorf("can't get %v values from database: %w", table.name, err)
+	}
+	keyFunc := func(key, val string) string {
+		return fmt.Sprintf("%v:%v", key, val)
+	}
+	//use a map for efficient access
+	mapFieldsValue := make(map[string]*model.FieldValue)
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var value string
+		var count int
+		err = rows.Scan(&key, &value, &count)
+		if err != nil {
+			return nil, err
+		}
+		//add the value
+		fieldVal := model.FieldValue{
+			Value: value,
+		}
+		if len(ids) == 0 {
+			//the current count is for all resources, use it
+			fieldVal.Count = fmt.Sprint(count)
+		} else {
+			//this value will be set using the ids
+			fieldVal.Count = "-"
+		}
+		field := mapFields[key]
+		field.Values = append(field.Values, &fieldVal)
+
+		//update map for fast access
+		mapFieldsValue[keyFunc(key, value)] = &fieldVal
+	}
+
+	if len(ids) == 0 {
+		//we are done, no need to get specific values
+		return fields, nil
+	}
+
+	//3. update count to reflect the current query
+	rows, err = s.db.Raw(fmt.Sprintf("SELECT %v, value, count(distinct resource_id) as count FROM %v %vgroup by %v, value",
+		table.keyColumn, table.name, table.where("resource_id in ?"), table.keyColumn), append([]interface{}{ids}, table.filterArgs...)...).Rows()
+	if err != nil {
+		return model.Fields{}, fmt.Errorf("can't get %v values from database: %w", table.name, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var value string
+		var count int
+		err = rows.Scan(&key, &value, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		//update the value
+		fieldValue := mapFieldsValue[keyFunc(key, value)]
+		fieldValue.Count = fmt.Sprint(count)
+	}
+
+	//update the count of the fields: a resource can have more than one value for the same key
+	rows, err = s.db.Raw(fmt.Sprintf("SELECT %v, count(distinct resource_id) as count FROM %v %vgroup by %v",
+		table.keyColumn, table.name, table.where("resource_id in ?"), table.keyColumn), append([]interface{}{ids}, table.filterArgs...)...).Rows()
+	if err != nil {
+		return model.Fields{}, fmt.Errorf("can't get %v keys from database: %w", table.name, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var count int
+		err = rows.Scan(&key, &count)
+		if err != nil {
+			return nil, err
+		}
+		mapFields[key].Count = count
+	}
+
+	return fields, nil
+}
+
+//TODO remove this api
+func (s *sqlStore) GetFields(ctx context.Context) (model.FieldGroups, error) {
+	return s.getFields(ctx, nil, snapshot{})
+}
+
+func (s *sqlStore) getFields(ctx context.Context, ids []model.ResourceId, snap snapshot) (model.FieldGroups, error) {
+	var fieldGroups model.FieldGroups
+
+	//get core fields
+	coreGroup := model.FieldGroup{
+		Name: model.FieldGroupCore,
+	}
+	for _, f := range model.CoreFields {
+		if !f.Listed {
+			continue
+		}
+		field, err := s.getResourceField(f.Name, ids, snap)
+		if err != nil {
+			return nil, err
+		}
+		//the optional fields are only returned if some resources have a value, ex: the namespace if some resources are Kubernetes objects
+		if f.Optional && (len(field.Values) == 0 || (len(field.Values) == 1 && field.Values[0].Value == "")) {
+			continue
+		}
+		coreGroup.Fields = append(coreGroup.Fields, &field)
+	}
+	fieldGroups = append(fieldGroups, coreGroup)
+
+	//get tag fields
+	tagFields, err := s.getTagFields(ids, snap)
+	if err != nil {
+		return nil, err
+	}
+	tagsGroup := model.FieldGroup{
+		Name:   model.FieldGroupTags,
+		Fields: tagFields,
+	}
+	fieldGroups = append(fieldGroups, tagsGroup)
+
+	//get property fields
+	propertyFields, err := s.getPropertyFields(ids, snap)
+	if err != nil {
+		return nil, err
+	}
+	propertiesGroup := model.FieldGroup{
+		Name:   model.FieldGroupProperties,
+		Fields: propertyFields,
+	}
+	fieldGroups = append(fieldGroups, propertiesGroup)
+
+	return fieldGroups.AddNullValues(), nil
+}
+
+//queryOptions are the options of a query which are not used to select the resources
+type queryOptions struct {
+	//AsOf selects the resources at a point in time
+	AsOf string `json:"asOf"`
+	//Fields can be set to false to not return the fields, ex: when paging through all the resources
+	Fields *bool `json:"fields"`
+}
+
+//queryOptionKeys are removed from the query before parsing it
+var queryOptionKeys = []string{"asOf", "fields"}
+
+func parseQueryOptions(jsonQuery []byte) (queryOptions, error) {
+	var options queryOptions
+	if len(jsonQuery) > 0 {
+		if err := json.Unmarshal(jsonQuery, &options); err != nil {
+			return queryOptions{}, err
+		}
+	}
+	return options, nil
+}
+
+//querySnapshot returns the snapshot selected by a query
+func (s *sqlStore) querySnapshot(jsonQuery []byte) (snapshot, error) {
+	options, err := parseQueryOptions(jsonQuery)
+	if err != nil {
+		return snapshot{}, err
+	}
+	return s.newSnapshot(options.AsOf)
+}
+
+//getSnapshotResources returns the resources of a snapshot, in the same order as the ids
+func (s *sqlStore) getSnapshotResources(ctx context.Context, ids []model.ResourceId, snap snapshot) ([]*model.Resource, error) {
+	if snap.isCurrent() {
+		return s.getResourcesById(ctx, ids)
+	}
+	return s.getVersionsById(ids)
+}
+
+func (s *sqlStore) GetResources(ctx context.Context, jsonQuery []byte) (model.ResourcesResponse, error) {
+	snap, err := s.querySnapshot(jsonQuery)
+	if err != nil {
+		return model.ResourcesResponse{}, err
+	}
+	ids, totalCount, err := s.indexer.findResourceIds(*s.db, s.logger, jsonQuery, true, snap)
+	if err != nil {
+		return model.ResourcesResponse{}, err
+	}
+	resources, err := s.getSnapshotResources(ctx, ids, snap)
+	if err != nil {
+		return model.ResourcesResponse{}, err
+	}
+	if options, _ := parseQueryOptions(jsonQuery); options.Fields != nil && !*options.Fields {
+		return model.ResourcesResponse{Count: totalCount, Resources: resources}, nil
+	}
+	//update field count to match current query
+	allIds := ids
+	if totalCount > len(ids) {
+		// the response is paginated, but we need all ids to show correct count
+		allIds, _, err = s.indexer.findResourceIds(*s.db, s.logger, jsonQuery, false, snap)
+		if err != nil {
+			return model.ResourcesResponse{}, err
+		}
+	}
+	fields, err := s.getFields(ctx, allIds, snap)
+	if err != nil {
+		return model.ResourcesResponse{}, err
+	}
+	return model.ResourcesResponse{Count: totalCount, Resources: resources, FieldGroups: fields}, nil
+}
+
+//GetAllResources returns all the resources matching a query, the limit and the offset are ignored
+func (s *sqlStore) GetAllResources(ctx context.Context, jsonQuery []byte) (model.Resources, error) {
+	snap, err := s.querySnapshot(jsonQuery)
+	if err != nil {
+		return nil, err
+	}
+	ids, _, err := s.indexer.findResourceIds(*s.db, s.logger, jsonQuery, false, snap)
+	if err != nil {
+		return nil, err
+	}
+	resources := make(model.Resources, 0, len(ids))
+	for _, batch := range util.Chunks(ids, readBatchSize) {
+		batchResources, err := s.getSnapshotResources(ctx, batch, snap)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, batchResources...)
+	}
+	return resources, nil
+}
+
+//GetManagedSummary summarizes the tools managing the resources matching a query, the resources are counted by the database
+func (s *sqlStore) GetManagedSummary(ctx context.Context, jsonQuery []byte) (model.ManagedSummary, error) {
+	snap, err := s.querySnapshot(jsonQuery)
+	if err != nil {
+		return model.ManagedSummary{}, err
+	}
+	counts, err := s.indexer.countManagedResources(*s.db, jsonQuery, snap)
+	if err != nil {
+		return model.ManagedSummary{}, err
+	}
+	return model.NewManagedSummary(counts), nil
+}
+
+//writeTags inserts the tags of the resources, if a resource is present more than once its first tags are kept
+func writeTags(db *gorm.DB, resources []*model.Resource) error {
+	var tags model.Tags
+	for _, r := range resources {
+		for _, tag := range r.Tags {
+			tag.ResourceId = r.Id
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(tags, tagsBatchSize).Error
+}
+
+func deleteTags(db *gorm.DB, ids []model.ResourceId) error {
+	return db.Table("tags").Where("resource_id in ?", ids).Delete(ids).Error
+}
+
+//fetchKey identifies the resources of a fetch function
+type fetchKey struct {
+	accountId    string
+	region       string
+	resourceType string
+}
+
+//deleteResourcesBefore deletes the resources not updated since a time
+//only the accounts fetched since then are considered: the other accounts can be fetched by another instance sharing the database
+//the resource types which failed to be fetched are kept, the resources are written while fetching so a failed fetch may have written some of them
+//if the history is enabled, the versions of the deleted resources end at deletedAt
+func (s *sqlStore) deleteResourcesBefore(before time.Time, deletedAt time.Time) (int, error) {
+
+	accounts := make([]string, 0, len(s.fetchedAccounts))
+	for account := range s.fetchedAccounts {
+		accounts = append(accounts, account)
+	}
+	var rowsAffected int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		//get the resource ids to delete
+		var stale []struct {
+			Id        model.ResourceId
+			AccountId string
+			Region    string
+			Type      string
+		}
+		if err := tx.Table("resources").Select("id, account_id, region, type").Where("updated_at < ? AND account_id in ?", before, accounts).Find(&stale).Error; err != nil {
+			return err
+		}
+		var ids []model.ResourceId
+		for _, r := range stale {
+			if _, failed := s.failedFetches[fetchKey{accountId: r.AccountId, region: r.Region, resourceType: r.Type}]; failed {
+				continue
+			}
+			//a provider without region, ex: a GCP project, fetches the resources of all the regions
+			if _, failed := s.failedFetches[fetchKey{accountId: r.AccountId, resourceType: r.Type}]; failed {
+				continue
+			}
+			ids = append(ids, r.Id)
+		}
+
+		if len(ids) == 0 {
+			//nothing to delete
+			return nil
+		}
+
+		totalCount, err := resourceCount(tx)
+		if err != nil {
+			return err
+		}
+		if totalCount == len(ids) {
+			s.logger.Sugar().Warnf("deleting resources before last run would delete all resources (count: %v), ignoring delete.", totalCount)
+			// the most common case for this would be the engine encountered a global error,
+			// and we are now writting the resource event in the DB to record the error.
+			// we woud rather keep the previous resources than deleting them all.
+			return nil
+		}
+
+		//delete all the tags
+		if err := deleteTags(tx, ids); err != nil {
+			return err
+		}
+
+		//delete the resource indexes
+		if err := s.indexer.deleteResourceIndexes(tx, ids); err != nil {
+			return err
+		}
+
+		//end the versions of the deleted resources
+		if s.historyEnabled() {
+			deletedIds := make([]string, len(ids))
+			for i, id := range ids {
+				deletedIds[i] = string(id)
+			}
+			if err := closeVersions(tx, deletedIds, deletedAt); err != nil {
+				return err
+			}
+		}
+
+		//delete the resources
+		result := tx.Table("resources").Where("id in ?", ids).Delete(ids)
+		if result.Error != nil {
+			return result.Error
+		}
+		rowsAffected = result.RowsAffected
+		return nil
+	})
+
+	s.logger.Sugar().Infow("Deleting resources: ", zap.Int64("rowsAffected", rowsAffected))
+
+	if err != nil {
+		return 0, fmt.Errorf("can't delete resources from database: %w", err)
+	}
+	return int(rowsAffected), nil
+}
+
+func (s *sqlStore) EngineStatus(ctx context.Context) (model.Event, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	runId := s.runId
+	if runId == "" {
+		//no run since the datastore was opened, return the last recorded run
+		var lastRun model.Event
+		result := s.db.Model(&model.Event{}).
+			Order("created_at desc").
+			Limit(1).
+			Find(&lastRun, model.Event{Type: model.EventTypeEngine})
+		if result.Error != nil {
+			return model.Event{}, fmt.Errorf("error while reading event from database %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			//no event found
+			return model.Event{}, nil
+		}
+		runId = lastRun.RunId
+	}
+	var resourceEvents model.Events
+	result := s.db.
+		Model(&model.Event{}).
+		Order("created_at").
+		Find(&resourceEvents, model.Event{RunId: runId})
+	if result.Error != nil {
+		return model.Event{}, fmt.Errorf("error while reading event from database %w", result.Error)
+	}
+	if len(resourceEvents) == 0 {
+		//no event found
+		return model.Event{}, nil
+	}
+	engineEvent := resourceEvents[0]
+	engineEvent.ChildEvents = resourceEvents[1:]
+	return engineEvent, nil
+}
+
+func (s *sqlStore) WriteEvent(ctx context.Context, event model.Event) error {
+	if s.readOnly {
+		return errReadOnly
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if event.Type == model.EventTypeEngine && event.Status == model.EventStatusFetching {
+		s.runId = event.RunId
+		s.fetchedAt = time.Now()
+		s.fetchedAccounts = make(map[string]struct{})
+		s.failedFetches = make(map[fetchKey]struct{})
+	}
+	if event.Type == model.EventTypeResource && event.AccountId != "" {
+		if s.fetchedAccounts == nil {
+			s.fetchedAccounts = make(map[string]struct{})
+		}
+		s.fetchedAccounts[event.AccountId] = struct{}{}
+	}
+	if event.Type == model.EventTypeResource && (event.Status == model.EventStatusFailed || event.Status == model.EventStatusTimeout) {
+		if s.failedFetches == nil {
+			s.failedFetches = make(map[fetchKey]struct{})
+		}
+		s.failedFetches[fetchKey{accountId: event.AccountId, region: event.Region, resourceType: event.ResourceType}] = struct{}{}
+	}
+	event.RunId = s.runId
+
+	var existingEvent model.Event
+	result := s.db.Model(&model.Event{}).
+		Find(&existingEvent, model.Event{
+			RunId:        s.runId,
+			Type:         event.Type,
+			ProviderName: event.ProviderName,
+			ResourceType: event.ResourceType,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error occured while fetching event from database %w", result.Error)
+	}
+	event.Id = existingEvent.Id
+	engineDone := event.Type == model.EventTypeEngine && (event.Status == model.EventStatusFailed || event.Status == model.EventStatusSuccess)
+	if engineDone {
+		//the end of the run is the time of its snapshot in the history
+		event.UpdatedAt = time.Now().UTC()
+	}
+	result = s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&event)
+	if result.Error != nil {
+		return fmt.Errorf("error occured while upserting events into database %w", result.Error)
+	}
+	//once engine is complete, we delete all the resources that no longer exist
+	if engineDone {
+		_, err := s.deleteResourcesBefore(s.fetchedAt, event.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if s.historyEnabled() {
+			if err := s.purgeHistory(event.UpdatedAt); err != nil {
+				return err
+			}
+		}
+		//the properties of the run are all written
+		s.clearPropertyPaths()
+	}
+	return nil
+}
+
+//GetRuns returns the engine runs, most recent first
+func (s *sqlStore) GetRuns(ctx context.Context) (model.Events, error) {
+	var runs model.Events
+	result := s.db.
+		Model(&model.Event{}).
+		Order("created_at desc").
+		Find(&runs, model.Event{Type: model.EventTypeEngine})
+	if result.Error != nil {
+		return nil, fmt.Errorf("error while reading runs from database %w", result.Error)
+	}
+	return runs, nil
+}
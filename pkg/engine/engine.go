@@ -19,6 +19,7 @@ type Engine struct {
 	datastore.Datastore
 	Logger    *zap.Logger
 	Sequencer sequencer.Sequencer
+	managedBy managedBy
 }
 
 //NewEngine Set up the providers, make sure configuration is valid
@@ -26,8 +27,26 @@ func NewEngine(ctx context.Context, cfg config.Config, logger *zap.Logger, datas
 	e := Engine{}
 	e.Datastore = datastore
 	e.Logger = logger
-	e.Sequencer = sequencer.AsyncSequencer{Logger: e.Logger}
+	if err := sequencer.ValidateTimeouts(cfg.Engine.Timeouts); err != nil {
+		return e, err
+	}
+	flush := sequencer.Flush{BatchSize: cfg.Engine.BatchSize, Interval: cfg.Engine.FlushInterval}
+	e.Sequencer = sequencer.AsyncSequencer{Logger: e.Logger, Flush: flush, Timeouts: cfg.Engine.Timeouts}
 	var errors error
+	managedBy, err := newManagedBy(cfg.IaC)
+	if err != nil {
+		return e, err
+	}
+	e.managedBy = managedBy
+	if concurrency := cfg.Engine.Concurrency; concurrency.Workers != 0 || concurrency.PerProvider != 0 || concurrency.PerRegion != 0 || len(concurrency.PerService) > 0 {
+		pool, err := sequencer.NewPoolSequencer(logger, concurrency)
+		if err != nil {
+			return e, err
+		}
+		pool.Flush, pool.Timeouts = flush, cfg.Engine.Timeouts
+		e.Sequencer = pool
+	}
+	scanned := scansCloud(cfg.Providers)
 	for _, c := range cfg.Providers {
 		if err := datastore.WriteEvent(ctx, model.NewProviderEventStart(c.String())); err != nil {
 			errors = multierror.Append(errors, err)
@@ -35,6 +54,9 @@ func NewEngine(ctx context.Context, cfg config.Config, logger *zap.Logger, datas
 		// create the providers - one per region
 		providers, err := provider.NewProviders(ctx, c, logger)
 		if err == nil {
+			if scanned {
+				providers = withStateIds(providers)
+			}
 			e.Providers = append(e.Providers, providers...)
 			//send one amplitude event per AWS account
 			for _, p := range providers {
@@ -50,6 +72,12 @@ func NewEngine(ctx context.Context, cfg config.Config, logger *zap.Logger, datas
 	return e, errors
 }
 
+//WriteResources sets the tool managing the resources before writing them, the sequencer writes the fetched resources to the engine
+func (e *Engine) WriteResources(ctx context.Context, resources model.Resources) error {
+	e.managedBy.set(resources)
+	return e.Datastore.WriteResources(ctx, resources)
+}
+
 //Run the providers: fetches data about cloud resources and save them to store
 func (e *Engine) Run(ctx context.Context) error {
 	var errors error
@@ -2,12 +2,16 @@
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/juandiegopalomino/cloudgrep/pkg/config"
 	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
 	"github.com/juandiegopalomino/cloudgrep/pkg/model"
 	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	"github.com/juandiegopalomino/cloudgrep/pkg/sequencer"
 	providerutil "github.com/juandiegopalomino/cloudgrep/pkg/testingutil/provider"
 	"github.com/juandiegopalomino/cloudgrep/pkg/util/amplitude"
 	"github.com/stretchr/testify/assert"
@@ -37,6 +41,32 @@ func TestNewEngine(t *testing.T) {
 		require.Equal(t, logger, e.Logger)
 	})
 
+	t.Run("Concurrency", func(t *testing.T) {
+		cfg, err := config.GetDefault()
+		require.NoError(t, err)
+		cfg.Datastore = config.Datastore{
+			Type:           "sqlite",
+			DataSourceName: "file::memory:",
+		}
+		ds, err := datastore.NewDatastore(ctx, cfg, zaptest.NewLogger(t))
+		require.NoError(t, err)
+		cfg.Providers = []config.Provider{}
+
+		//the default config limits the workers
+		e, err := NewEngine(ctx, cfg, logger, ds)
+		require.NoError(t, err)
+		assert.IsType(t, sequencer.PoolSequencer{}, e.Sequencer)
+
+		cfg.Engine.Concurrency = config.Concurrency{}
+		e, err = NewEngine(ctx, cfg, logger, ds)
+		require.NoError(t, err)
+		assert.IsType(t, sequencer.AsyncSequencer{}, e.Sequencer)
+
+		cfg.Engine.Concurrency = config.Concurrency{PerRegion: -1}
+		_, err = NewEngine(ctx, cfg, logger, ds)
+		require.ErrorContains(t, err, "can't be negative")
+	})
+
 	t.Run("BadProvider", func(t *testing.T) {
 		cfg, err := config.GetDefault()
 		require.NoError(t, err)
@@ -122,6 +152,58 @@ func TestEngineRun(t *testing.T) {
 	})
 }
 
+func TestEngineTimeouts(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore = config.Datastore{
+		Type:           "sqlite",
+		DataSourceName: "file::memory:",
+	}
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+
+	//the slow provider sends a resource every 200ms
+	slow := &providerutil.FakeProvider{
+		ID:  "slow",
+		Foo: providerutil.FakeProviderResourceConfig{Count: 3, DelayBefore: 200 * time.Millisecond},
+		Bar: providerutil.FakeProviderResourceConfig{Count: 1},
+	}
+	provider.RegisterExtraProviders("fake-timeout", []provider.Provider{slow})
+	cfg.Providers = []config.Provider{{Cloud: "fake-timeout"}}
+	cfg.Engine.Timeouts = config.Timeouts{Types: map[string]time.Duration{"slow.F*": 500 * time.Millisecond}}
+
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	e, err := NewEngine(ctx, cfg, logger, ds)
+	require.NoError(t, err)
+	err = e.Run(ctx)
+	require.ErrorContains(t, err, "timeout, the fetch was stopped after 2 resource(s)")
+
+	//the resources fetched before the timeout are kept
+	resp, err := ds.GetResources(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Count)
+
+	//the engine status shows the fetch cut short
+	engineStatus, err := ds.EngineStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, model.EventStatusFailed, engineStatus.Status)
+	statuses := make(map[string]model.Event)
+	for _, event := range engineStatus.ChildEvents {
+		if event.Type == model.EventTypeResource {
+			statuses[event.ResourceType] = event
+		}
+	}
+	assert.Equal(t, model.EventStatusTimeout, statuses["slow.Foo"].Status)
+	assert.Equal(t, 2, statuses["slow.Foo"].ResourcesCount)
+	assert.Equal(t, model.EventStatusSuccess, statuses["slow.Bar"].Status)
+
+	cfg.Engine.Timeouts = config.Timeouts{Run: -time.Second}
+	_, err = NewEngine(ctx, cfg, logger, ds)
+	require.ErrorContains(t, err, "can't be negative")
+}
+
 func fakeProviders() []provider.Provider {
 	fakeProviders := []*providerutil.FakeProvider{
 		{
@@ -145,3 +227,156 @@ func fakeProviders() []provider.Provider {
 
 	return providers
 }
+
+func TestEngineManagedBy(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore = config.Datastore{
+		Type:           "sqlite",
+		DataSourceName: "file::memory:",
+	}
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+
+	//the first instance is declared in a Terraform state
+	state := `{"version":4,"resources":[{"mode":"managed","type":"aws_instance","name":"web","instances":[{"attributes":{"id":"managed-managed.Foo-0"}}]}]}`
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, os.WriteFile(path, []byte(state), 0600))
+	provider.RegisterExtraProviders("fake-managed", []provider.Provider{&providerutil.FakeProvider{
+		ID:  "managed",
+		Foo: providerutil.FakeProviderResourceConfig{Count: 2},
+	}})
+	cfg.Providers = []config.Provider{{Cloud: "fake-managed"}}
+	cfg.IaC = config.IaC{TerraformStates: []string{path}}
+
+	e, err := NewEngine(ctx, cfg, logger, ds)
+	require.NoError(t, err)
+	require.NoError(t, e.Run(ctx))
+	resource, err := ds.GetResource(ctx, "managed-managed.Foo-0")
+	require.NoError(t, err)
+	assert.Equal(t, model.ManagedByTerraform, resource.ManagedBy)
+	resource, err = ds.GetResource(ctx, "managed-managed.Foo-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.ManagedByNone, resource.ManagedBy)
+
+	//an invalid state is reported before the run
+	cfg.IaC = config.IaC{TerraformStates: []string{filepath.Join(t.TempDir(), "missing.tfstate")}}
+	_, err = NewEngine(ctx, cfg, logger, ds)
+	require.ErrorContains(t, err, "can't find the resources managed by Terraform")
+}
+
+func TestEngineTerraformState(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore = config.Datastore{
+		Type:           "sqlite",
+		DataSourceName: "file::memory:",
+	}
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+
+	//the instance of the state is also scanned by a provider, the resources don't collide
+	state := `{"version":4,"resources":[{"mode":"managed","type":"aws_instance","name":"web","instances":[{"attributes":{"id":"scanned-scanned.Foo-0","arn":"arn:aws:ec2:us-east-1:123456789012:instance/scanned-scanned.Foo-0"}}]}]}`
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, os.WriteFile(path, []byte(state), 0600))
+	provider.RegisterExtraProviders("fake-scanned", []provider.Provider{&providerutil.FakeProvider{
+		ID:  "scanned",
+		Foo: providerutil.FakeProviderResourceConfig{Count: 1},
+	}})
+	cfg.Providers = []config.Provider{{Cloud: "fake-scanned"}, {Cloud: "terraform", Terraform: config.Terraform{States: []string{path}}}}
+	e, err := NewEngine(ctx, cfg, logger, ds)
+	require.NoError(t, err)
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	require.NoError(t, e.Run(ctx))
+	resp, err := ds.GetResources(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.Count)
+	declared, err := ds.GetResource(ctx, "terraform:"+path+"/aws_instance.web")
+	require.NoError(t, err)
+	assert.Equal(t, path, declared.AccountId)
+
+	//the resources of a state which can't be read are kept
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	require.ErrorContains(t, e.Run(ctx), "invalid Terraform state")
+	resp, err = ds.GetResources(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, resp.Count)
+
+	//without scanned cloud, the id of a declared resource is its id in the cloud
+	require.NoError(t, os.WriteFile(path, []byte(state), 0600))
+	ds, err = datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+	cfg.Providers = cfg.Providers[1:]
+	e, err = NewEngine(ctx, cfg, logger, ds)
+	require.NoError(t, err)
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	require.NoError(t, e.Run(ctx))
+	declared, err = ds.GetResource(ctx, "scanned-scanned.Foo-0")
+	require.NoError(t, err)
+	assert.Equal(t, "aws_instance.web", declared.DisplayId)
+}
+
+func TestEngineManagedSummary(t *testing.T) {
+	ctx := context.Background()
+	logger := zaptest.NewLogger(t)
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore = config.Datastore{
+		Type:           "sqlite",
+		DataSourceName: "file::memory:",
+	}
+	ds, err := datastore.NewDatastore(ctx, cfg, logger)
+	require.NoError(t, err)
+
+	//the state is read by a terraform provider and used to find the managed resources
+	state := `{"version":4,"resources":[{"mode":"managed","type":"aws_instance","name":"web","instances":[{"attributes":{"id":"summary-summary.Foo-0"}}]}]}`
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, os.WriteFile(path, []byte(state), 0600))
+	provider.RegisterExtraProviders("fake-summary", []provider.Provider{&providerutil.FakeProvider{
+		ID:  "summary",
+		Foo: providerutil.FakeProviderResourceConfig{Count: 2},
+	}})
+	cfg.Providers = []config.Provider{{Cloud: "fake-summary"}, {Cloud: "terraform", Terraform: config.Terraform{States: []string{path}}}}
+	cfg.IaC = config.IaC{TerraformStates: []string{path}}
+	e, err := NewEngine(ctx, cfg, logger, ds)
+	require.NoError(t, err)
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	require.NoError(t, e.Run(ctx))
+	resp, err := ds.GetResources(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Count)
+
+	//the declared resource is not counted, only the scanned resources are
+	summary, err := ds.GetManagedSummary(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, model.ManagedCount{ResourcesCount: 2, TerraformCount: 1, UnmanagedCount: 1, Coverage: 50}, summary.Total)
+}
+
+func TestManagedBy(t *testing.T) {
+	m := managedBy{terraformIds: map[string]bool{"i-1": true, "arn:aws:iam::123456789012:role/api": true}}
+	resources := model.Resources{
+		{Id: "i-1"},
+		{Id: "arn:aws:iam::123456789012:role/api"},
+		{Id: "i-2", Tags: model.Tags{{Key: "aws:cloudformation:stack-name", Value: "web"}}},
+		{Id: "i-3", Tags: model.Tags{{Key: "team", Value: "web"}}},
+		//the tool set by the provider is kept
+		{Id: "i-4", ManagedBy: model.ManagedByTerraform},
+	}
+	m.set(resources)
+	var managedBy []string
+	for _, r := range resources {
+		managedBy = append(managedBy, r.ManagedBy)
+	}
+	assert.Equal(t, []string{
+		model.ManagedByTerraform,
+		model.ManagedByTerraform,
+		model.ManagedByCloudFormation,
+		model.ManagedByNone,
+		model.ManagedByTerraform,
+	}, managedBy)
+}
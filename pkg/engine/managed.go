@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/terraform"
+)
+
+//cloudFormationStackTag is the tag set by CloudFormation on the resources of a stack
+const cloudFormationStackTag = "aws:cloudformation:stack-name"
+
+//managedBy finds the IaC tool managing the resources
+type managedBy struct {
+	//terraformIds are the ids and the ARNs of the resources of the Terraform states
+	terraformIds map[string]bool
+}
+
+//newManagedBy reads the Terraform states, they are read at each run to follow the changes of the states
+func newManagedBy(cfg config.IaC) (managedBy, error) {
+	if len(cfg.TerraformStates) == 0 {
+		return managedBy{}, nil
+	}
+	ids, err := terraform.ManagedIds(cfg.TerraformStates)
+	if err != nil {
+		return managedBy{}, fmt.Errorf("can't find the resources managed by Terraform: %w", err)
+	}
+	return managedBy{terraformIds: ids}, nil
+}
+
+//set sets the tool managing the resources: terraform, cloudformation or none
+//the tool set by the provider is kept, ex: the resources read from a Terraform state
+//the resources of a Terraform state identified by their state are declarations, they are never matched with the states
+func (m managedBy) set(resources model.Resources) {
+	for _, r := range resources {
+		if r.ManagedBy != "" || strings.HasPrefix(r.Id, model.TerraformStateIdPrefix) {
+			continue
+		}
+		switch {
+		case m.terraformIds[r.Id]:
+			r.ManagedBy = model.ManagedByTerraform
+		case r.Tags.Find(cloudFormationStackTag) != nil:
+			r.ManagedBy = model.ManagedByCloudFormation
+		default:
+			r.ManagedBy = model.ManagedByNone
+		}
+	}
+}
+
+//scansCloud returns true if a provider scans a cloud, the terraform providers read the resources declared in states
+func scansCloud(providers []config.Provider) bool {
+	for _, p := range providers {
+		if p.Cloud != "terraform" {
+			return true
+		}
+	}
+	return false
+}
+
+//withStateIds identifies the resources of the Terraform states by their state and their address, it is used when a cloud is scanned too:
+//the cloud ids are the ids of the scanned resources, a declared resource doesn't replace the scanned one
+func withStateIds(providers []provider.Provider) []provider.Provider {
+	for i, p := range providers {
+		if stateProvider, ok := p.(terraform.Provider); ok {
+			providers[i] = stateProvider.WithStateIds()
+		}
+	}
+	return providers
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	//the rules a tag can violate
+	RuleRequired = "required"
+	RuleValues   = "values"
+	RulePattern  = "pattern"
+	RuleCase     = "case"
+)
+
+//ComplianceReport is the result of the tag policies evaluation
+type ComplianceReport struct {
+	//RunId is the engine run evaluated
+	RunId     string    `json:"runId"`
+	CreatedAt time.Time `json:"createdAt"`
+	//ResourcesCount is the number of resources in the scope of at least one policy
+	ResourcesCount int `json:"resourcesCount"`
+	//CompliantCount is the number of resources without violation
+	CompliantCount int `json:"compliantCount"`
+	//Coverage is the percentage of compliant resources
+	Coverage float64            `json:"coverage"`
+	Policies []PolicyCompliance `json:"policies"`
+	//Resources lists the resources with at least one violation
+	Resources []ResourceCompliance `json:"resources"`
+}
+
+//PolicyCompliance is the result of a policy evaluation
+type PolicyCompliance struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	//ResourcesCount is the number of resources in the scope of the policy
+	ResourcesCount int `json:"resourcesCount"`
+	//CompliantCount is the number of resources respecting all the rules of the policy
+	CompliantCount int             `json:"compliantCount"`
+	Coverage       float64         `json:"coverage"`
+	Tags           []TagCompliance `json:"tags"`
+	Violations     []Violation     `json:"violations"`
+}
+
+//TagCompliance is the result of a tag rule evaluation
+type TagCompliance struct {
+	Key string `json:"key"`
+	//CompliantCount is the number of resources respecting the tag rule
+	CompliantCount int     `json:"compliantCount"`
+	Coverage       float64 `json:"coverage"`
+}
+
+//ResourceCompliance lists the violations of a resource
+type ResourceCompliance struct {
+	Id         string      `json:"id"`
+	DisplayId  string      `json:"displayId"`
+	AccountId  string      `json:"accountId"`
+	Region     string      `json:"region"`
+	Type       string      `json:"type"`
+	Violations []Violation `json:"violations"`
+}
+
+//Violation is a resource tag not respecting a policy rule
+type Violation struct {
+	Policy     string `json:"policy"`
+	ResourceId string `json:"resourceId"`
+	Key        string `json:"key"`
+	Value      string `json:"value,omitempty"`
+	//Rule is the rule violated: required, values, pattern or case
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+//Violations returns all the violations of the report
+func (r ComplianceReport) Violations() []Violation {
+	var violations []Violation
+	for _, p := range r.Policies {
+		violations = append(violations, p.Violations...)
+	}
+	return violations
+}
+
+//Coverage returns the percentage of compliant resources, rounded to 2 decimals
+//if there is no resource, the coverage is 100%
+func Coverage(compliant int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(compliant)*10000/float64(total)) / 100
+}
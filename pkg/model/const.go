@@ -10,14 +10,24 @@
 	CountValueIgnored = "-"
 
 	//name of the field groups as shown in API
-	FieldGroupCore = "core"
-	FieldGroupTags = "tags"
+	FieldGroupCore       = "core"
+	FieldGroupTags       = "tags"
+	FieldGroupProperties = "properties"
 
 	//event status as shown in API
 	EventStatusFetching string = "fetching"
 	EventStatusFailed   string = "failed"
 	EventStatusSuccess  string = "success"
 	EventStatusLoaded   string = "loaded"
+	//a resource fetch stopped by a timeout, the resources fetched before are kept
+	EventStatusTimeout string = "timeout"
+
+	//the kind of error of a failed fetch as shown in API
+	ErrorKindPermission        string = "permission"
+	ErrorKindThrottling        string = "throttling"
+	ErrorKindNetwork           string = "network"
+	ErrorKindUnsupportedRegion string = "unsupported_region"
+	ErrorKindUnknown           string = "unknown"
 
 	//event type as shown in API
 	EventTypeEngine   string = "engine"
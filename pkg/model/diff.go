@@ -0,0 +1,206 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+//ResourcesDiff lists the resources added, removed and modified between two sets of resources
+type ResourcesDiff struct {
+	Added    Resources        `json:"added"`
+	Removed  Resources        `json:"removed"`
+	Modified []ResourceChange `json:"modified"`
+}
+
+//ResourceChange describes the changes of a modified resource
+type ResourceChange struct {
+	//Resource is the resource after the change
+	Resource *Resource    `json:"resource"`
+	Tags     []TagChange  `json:"tags"`
+	RawData  []DataChange `json:"rawData"`
+}
+
+//TagChange is a tag added, removed or with a new value
+type TagChange struct {
+	Change string `json:"change"`
+	Key    string `json:"key"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+//DataChange is a value added, removed or modified in the resource raw data
+type DataChange struct {
+	Change string `json:"change"`
+	//Path is the JSON path of the value, ex: SecurityGroups[0].GroupId
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+//Empty returns true if there is no change
+func (d ResourcesDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+//NewResourcesDiff compares the resources by id, from is the previous state and to the new one
+func NewResourcesDiff(from Resources, to Resources) (ResourcesDiff, error) {
+	diff := ResourcesDiff{
+		Added:    Resources{},
+		Removed:  Resources{},
+		Modified: []ResourceChange{},
+	}
+	fromById := make(map[string]*Resource, len(from))
+	for _, r := range from {
+		if _, found := fromById[r.Id]; !found {
+			fromById[r.Id] = r
+		}
+	}
+	toById := make(map[string]*Resource, len(to))
+	for _, r := range to {
+		if _, found := toById[r.Id]; found {
+			continue
+		}
+		toById[r.Id] = r
+		previous, found := fromById[r.Id]
+		if !found {
+			diff.Added = append(diff.Added, r)
+			continue
+		}
+		rawDataChanges, err := DiffRawData(previous.RawData, r.RawData)
+		if err != nil {
+			return ResourcesDiff{}, fmt.Errorf("can't compare the resource '%v': %w", r.Id, err)
+		}
+		tagChanges := DiffTags(previous.Tags, r.Tags)
+		if len(tagChanges) > 0 || len(rawDataChanges) > 0 {
+			diff.Modified = append(diff.Modified, ResourceChange{
+				Resource: r,
+				Tags:     tagChanges,
+				RawData:  rawDataChanges,
+			})
+		}
+	}
+	for _, r := range from {
+		if _, found := toById[r.Id]; !found {
+			diff.Removed = append(diff.Removed, r)
+			//only report a duplicate once
+			toById[r.Id] = r
+		}
+	}
+	return diff, nil
+}
+
+//DiffTags returns the tag changes, sorted by key
+func DiffTags(from Tags, to Tags) []TagChange {
+	changes := []TagChange{}
+	for _, tag := range to {
+		previous := from.Find(tag.Key)
+		if previous == nil {
+			changes = append(changes, TagChange{Change: ChangeAdded, Key: tag.Key, To: tag.Value})
+		} else if previous.Value != tag.Value {
+			changes = append(changes, TagChange{Change: ChangeModified, Key: tag.Key, From: previous.Value, To: tag.Value})
+		}
+	}
+	for _, tag := range from {
+		if to.Find(tag.Key) == nil {
+			changes = append(changes, TagChange{Change: ChangeRemoved, Key: tag.Key, From: tag.Value})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+//DiffRawData returns the values changed between two JSON documents, sorted by path
+func DiffRawData(from []byte, to []byte) ([]DataChange, error) {
+	fromData, err := decodeRawData(from)
+	if err != nil {
+		return nil, err
+	}
+	toData, err := decodeRawData(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := []DataChange{}
+	diffData(fromData, toData, "", func(change DataChange) {
+		changes = append(changes, change)
+	})
+	return changes, nil
+}
+
+func decodeRawData(data []byte) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	//keep the numbers as they are written in the JSON
+	decoder.UseNumber()
+	var result interface{}
+	if err := decoder.Decode(&result); err != nil {
+		return nil, fmt.Errorf("can't read the raw data: %w", err)
+	}
+	return result, nil
+}
+
+func diffData(from interface{}, to interface{}, path string, add func(DataChange)) {
+	switch fromValue := from.(type) {
+	case map[string]interface{}:
+		if toValue, ok := to.(map[string]interface{}); ok {
+			//iterate in order to always return the changes in the same order
+			keys := make([]string, 0, len(fromValue)+len(toValue))
+			for key := range fromValue {
+				keys = append(keys, key)
+			}
+			for key := range toValue {
+				if _, found := fromValue[key]; !found {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				childPath := key
+				if path != "" {
+					childPath = path + propertyPathSep + key
+				}
+				fromChild, fromFound := fromValue[key]
+				toChild, toFound := toValue[key]
+				switch {
+				case !fromFound:
+					add(DataChange{Change: ChangeAdded, Path: childPath, To: toChild})
+				case !toFound:
+					add(DataChange{Change: ChangeRemoved, Path: childPath, From: fromChild})
+				default:
+					diffData(fromChild, toChild, childPath, add)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if toValue, ok := to.([]interface{}); ok {
+			for i := 0; i < len(fromValue) || i < len(toValue); i++ {
+				childPath := fmt.Sprintf("%v[%d]", path, i)
+				switch {
+				case i >= len(fromValue):
+					add(DataChange{Change: ChangeAdded, Path: childPath, To: toValue[i]})
+				case i >= len(toValue):
+					add(DataChange{Change: ChangeRemoved, Path: childPath, From: fromValue[i]})
+				default:
+					diffData(fromValue[i], toValue[i], childPath, add)
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(from, to) {
+		add(DataChange{Change: ChangeModified, Path: path, From: from, To: to})
+	}
+}
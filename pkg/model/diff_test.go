@@ -0,0 +1,88 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResourcesDiff(t *testing.T) {
+	unchanged := Resource{Id: "i-1", Type: "test.Instance", Tags: Tags{{Key: "team", Value: "infra"}}, RawData: []byte(`{"a":1}`)}
+	removed := Resource{Id: "i-2", Type: "test.Instance"}
+	modifiedFrom := Resource{Id: "i-3", Type: "test.Instance",
+		Tags:    Tags{{Key: "team", Value: "infra"}, {Key: "env", Value: "prod"}},
+		RawData: []byte(`{"InstanceType":"t3.small"}`),
+	}
+	modifiedTo := Resource{Id: "i-3", Type: "test.Instance",
+		Tags:    Tags{{Key: "team", Value: "dev"}, {Key: "release", Value: "v1"}},
+		RawData: []byte(`{"InstanceType":"t3.medium"}`),
+	}
+	added := Resource{Id: "i-4", Type: "test.Instance"}
+	//the tag order and the JSON formatting are not changes
+	unchangedTo := unchanged
+	unchangedTo.RawData = []byte(`{ "a": 1 }`)
+
+	diff, err := NewResourcesDiff(Resources{&unchanged, &removed, &modifiedFrom}, Resources{&modifiedTo, &added, &unchangedTo})
+	require.NoError(t, err)
+	assert.Equal(t, Resources{&added}, diff.Added)
+	assert.Equal(t, Resources{&removed}, diff.Removed)
+	require.Equal(t, 1, len(diff.Modified))
+	assert.Equal(t, &modifiedTo, diff.Modified[0].Resource)
+	assert.Equal(t, []TagChange{
+		{Change: ChangeRemoved, Key: "env", From: "prod"},
+		{Change: ChangeAdded, Key: "release", To: "v1"},
+		{Change: ChangeModified, Key: "team", From: "infra", To: "dev"},
+	}, diff.Modified[0].Tags)
+	assert.Equal(t, []DataChange{
+		{Change: ChangeModified, Path: "InstanceType", From: "t3.small", To: "t3.medium"},
+	}, diff.Modified[0].RawData)
+	assert.False(t, diff.Empty())
+
+	//no change
+	diff, err = NewResourcesDiff(Resources{&unchanged}, Resources{&unchangedTo})
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+	//the lists are never null
+	data, err := json.Marshal(diff)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"added":[],"removed":[],"modified":[]}`, string(data))
+}
+
+func TestDiffRawData(t *testing.T) {
+	testCases := []struct {
+		name     string
+		from, to string
+		expected []DataChange
+	}{
+		{"Equal", `{"a":{"b":[1,2]}}`, `{"a":{"b":[1,2]}}`, []DataChange{}},
+		{"Empty", ``, ``, []DataChange{}},
+		{"Nested", `{"Placement":{"AvailabilityZone":"us-east-1a","Tenancy":"default"}}`, `{"Placement":{"AvailabilityZone":"us-east-1b","GroupName":""}}`, []DataChange{
+			{Change: ChangeModified, Path: "Placement.AvailabilityZone", From: "us-east-1a", To: "us-east-1b"},
+			{Change: ChangeAdded, Path: "Placement.GroupName", To: ""},
+			{Change: ChangeRemoved, Path: "Placement.Tenancy", From: "default"},
+		}},
+		{"Array", `{"SecurityGroups":[{"GroupId":"sg-1"},{"GroupId":"sg-2"}]}`, `{"SecurityGroups":[{"GroupId":"sg-3"}]}`, []DataChange{
+			{Change: ChangeModified, Path: "SecurityGroups[0].GroupId", From: "sg-1", To: "sg-3"},
+			{Change: ChangeRemoved, Path: "SecurityGroups[1]", From: map[string]interface{}{"GroupId": "sg-2"}},
+		}},
+		{"Types", `{"a":1,"b":true,"c":null}`, `{"a":"1","b":false,"c":{}}`, []DataChange{
+			{Change: ChangeModified, Path: "a", From: json.Number("1"), To: "1"},
+			{Change: ChangeModified, Path: "b", From: true, To: false},
+			{Change: ChangeModified, Path: "c", From: nil, To: map[string]interface{}{}},
+		}},
+		{"NoRawData", ``, `{"a":1}`, []DataChange{
+			{Change: ChangeModified, Path: "", From: nil, To: map[string]interface{}{"a": json.Number("1")}},
+		}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := DiffRawData([]byte(tc.from), []byte(tc.to))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, changes)
+		})
+	}
+	_, err := DiffRawData([]byte(`{`), []byte(`{}`))
+	assert.ErrorContains(t, err, "can't read the raw data")
+}
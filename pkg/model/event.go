@@ -7,16 +7,31 @@
 )
 
 type Event struct {
-	Id           int64     `json:"-" gorm:"primaryKey;autoIncrement"`
-	RunId        string    `json:"runId"`
-	Type         string    `json:"eventType"`
-	Status       string    `json:"status"`
-	ProviderName string    `json:"providerName,omitempty"`
-	ResourceType string    `json:"resourceType,omitempty"`
-	Error        string    `json:"error"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
-	ChildEvents  Events    `json:"childEvents" gorm:"-"`
+	Id           int64  `json:"-" gorm:"primaryKey;autoIncrement"`
+	RunId        string `json:"runId"`
+	Type         string `json:"eventType"`
+	Status       string `json:"status"`
+	ProviderName string `json:"providerName,omitempty"`
+	ResourceType string `json:"resourceType,omitempty"`
+	//the account and the region of the provider, for a resource event
+	AccountId string `json:"accountId,omitempty"`
+	Region    string `json:"region,omitempty"`
+	//the number of resources fetched, for a resource event
+	ResourcesCount int `json:"resourcesCount"`
+	//the number of API calls throttled by the cloud and of retried API calls, for a resource event
+	Throttles int    `json:"throttles,omitempty"`
+	Retries   int    `json:"retries,omitempty"`
+	Error     string `json:"error"`
+	//the classification of the error of a failed resource event: its kind, the error code of the cloud, the API operation and the permission required by the operation
+	ErrorKind      string    `json:"errorKind,omitempty"`
+	ErrorCode      string    `json:"errorCode,omitempty"`
+	ErrorOperation string    `json:"errorOperation,omitempty"`
+	ErrorAction    string    `json:"errorAction,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	ChildEvents    Events    `json:"childEvents" gorm:"-"`
+	//the time of the next scheduled run, for the engine event returned by the engine status
+	NextRunAt *time.Time `json:"nextRunAt,omitempty" gorm:"-"`
 }
 
 type Events []Event
@@ -0,0 +1,93 @@
+package model
+
+import "sort"
+
+const (
+	//the tools managing a resource as shown in the core.managed_by field
+	ManagedByTerraform      = "terraform"
+	ManagedByCloudFormation = "cloudformation"
+	ManagedByNone           = "none"
+)
+
+//TerraformStateIdPrefix is the prefix of the ids of the resources of a Terraform state identified by their state and their address
+//ex: the declared resources when a cloud is scanned too, they are not counted in the managed summary as the scanned resources are
+const TerraformStateIdPrefix = "terraform:"
+
+//ManagedSummary counts the resources managed by an IaC tool, to measure the progress of an IaC initiative
+type ManagedSummary struct {
+	Total ManagedCount `json:"total"`
+	//the counts by resource type and by account, sorted by name
+	Types    []ManagedCount `json:"types"`
+	Accounts []ManagedCount `json:"accounts"`
+}
+
+//ManagedCount counts the resources by tool for a resource type or an account
+type ManagedCount struct {
+	Name                string `json:"name"`
+	ResourcesCount      int    `json:"resourcesCount"`
+	TerraformCount      int    `json:"terraformCount"`
+	CloudFormationCount int    `json:"cloudFormationCount"`
+	UnmanagedCount      int    `json:"unmanagedCount"`
+	//Coverage is the percentage of resources managed by Terraform or CloudFormation
+	Coverage float64 `json:"coverage"`
+}
+
+//ManagedResources is the number of resources of a type in an account managed by a tool
+type ManagedResources struct {
+	Type      string
+	AccountId string
+	ManagedBy string
+	Count     int
+}
+
+//NewManagedSummary summarizes the tools managing the resources, from their counts by type, account and tool
+//the resources written before the managed_by field was added are counted as unmanaged
+func NewManagedSummary(counts []ManagedResources) ManagedSummary {
+	summary := ManagedSummary{
+		Types:    []ManagedCount{},
+		Accounts: []ManagedCount{},
+	}
+	types := make(map[string]*ManagedCount)
+	accounts := make(map[string]*ManagedCount)
+	count := func(c *ManagedCount, r ManagedResources) {
+		c.ResourcesCount += r.Count
+		switch r.ManagedBy {
+		case ManagedByTerraform:
+			c.TerraformCount += r.Count
+		case ManagedByCloudFormation:
+			c.CloudFormationCount += r.Count
+		default:
+			c.UnmanagedCount += r.Count
+		}
+	}
+	countBy := func(counts map[string]*ManagedCount, name string, r ManagedResources) {
+		c, found := counts[name]
+		if !found {
+			c = &ManagedCount{Name: name}
+			counts[name] = c
+		}
+		count(c, r)
+	}
+	for _, r := range counts {
+		count(&summary.Total, r)
+		countBy(types, r.Type, r)
+		countBy(accounts, r.AccountId, r)
+	}
+	sorted := func(counts map[string]*ManagedCount) []ManagedCount {
+		result := make([]ManagedCount, 0, len(counts))
+		for _, c := range counts {
+			c.Coverage = c.coverage()
+			result = append(result, *c)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		return result
+	}
+	summary.Total.Coverage = summary.Total.coverage()
+	summary.Types = sorted(types)
+	summary.Accounts = sorted(accounts)
+	return summary
+}
+
+func (c ManagedCount) coverage() float64 {
+	return Coverage(c.TerraformCount+c.CloudFormationCount, c.ResourcesCount)
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewManagedSummary(t *testing.T) {
+	counts := []ManagedResources{
+		{Type: "ec2.Instance", AccountId: "111", ManagedBy: ManagedByTerraform, Count: 1},
+		{Type: "ec2.Instance", AccountId: "111", ManagedBy: ManagedByCloudFormation, Count: 1},
+		{Type: "ec2.Instance", AccountId: "222", ManagedBy: ManagedByNone, Count: 1},
+		//written before the managed_by field was added
+		{Type: "s3.Bucket", AccountId: "222", Count: 2},
+	}
+	summary := NewManagedSummary(counts)
+	assert.Equal(t, ManagedCount{ResourcesCount: 5, TerraformCount: 1, CloudFormationCount: 1, UnmanagedCount: 3, Coverage: 40}, summary.Total)
+	assert.Equal(t, []ManagedCount{
+		{Name: "ec2.Instance", ResourcesCount: 3, TerraformCount: 1, CloudFormationCount: 1, UnmanagedCount: 1, Coverage: 66.67},
+		{Name: "s3.Bucket", ResourcesCount: 2, UnmanagedCount: 2, Coverage: 0},
+	}, summary.Types)
+	assert.Equal(t, []ManagedCount{
+		{Name: "111", ResourcesCount: 2, TerraformCount: 1, CloudFormationCount: 1, Coverage: 100},
+		{Name: "222", ResourcesCount: 3, UnmanagedCount: 3, Coverage: 0},
+	}, summary.Accounts)
+
+	//no resource
+	summary = NewManagedSummary(nil)
+	assert.Equal(t, ManagedCount{Coverage: 100}, summary.Total)
+	assert.Empty(t, summary.Types)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"sort"
+
+	"golang.org/x/exp/maps"
+)
+
+//PermissionReport lists the permissions missing to fetch the resources in a run of the engine
+type PermissionReport struct {
+	RunId string `json:"runId"`
+	//the missing actions, sorted by name
+	Actions []string `json:"actions"`
+	//where each missing action was required, sorted by action
+	Missing []MissingPermission `json:"missing"`
+	//Policy is an IAM policy granting the missing actions, empty if no action is missing
+	Policy PolicyDocument `json:"policy"`
+}
+
+//MissingPermission is an action which was denied, with the fetches which required it
+type MissingPermission struct {
+	Action        string   `json:"action"`
+	ResourceTypes []string `json:"resourceTypes"`
+	Accounts      []string `json:"accounts"`
+	Regions       []string `json:"regions"`
+}
+
+//PolicyDocument is an IAM policy document
+type PolicyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+//PolicyStatement is a statement of an IAM policy document
+type PolicyStatement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource string   `json:"Resource"`
+}
+
+//NewPermissionReport returns the actions denied in the resource events of an engine event
+func NewPermissionReport(engine Event) PermissionReport {
+	report := PermissionReport{
+		RunId:   engine.RunId,
+		Actions: []string{},
+		Missing: []MissingPermission{},
+	}
+	type usage struct {
+		resourceTypes, accounts, regions map[string]struct{}
+	}
+	usages := make(map[string]usage)
+	add := func(set map[string]struct{}, value string) {
+		if value != "" {
+			set[value] = struct{}{}
+		}
+	}
+	for _, event := range engine.ChildEvents {
+		if event.Type != EventTypeResource || event.ErrorKind != ErrorKindPermission || event.ErrorAction == "" {
+			continue
+		}
+		u, found := usages[event.ErrorAction]
+		if !found {
+			u = usage{make(map[string]struct{}), make(map[string]struct{}), make(map[string]struct{})}
+			usages[event.ErrorAction] = u
+		}
+		add(u.resourceTypes, event.ResourceType)
+		add(u.accounts, event.AccountId)
+		add(u.regions, event.Region)
+	}
+	sorted := func(set map[string]struct{}) []string {
+		values := maps.Keys(set)
+		sort.Strings(values)
+		return values
+	}
+	for action := range usages {
+		report.Actions = append(report.Actions, action)
+	}
+	sort.Strings(report.Actions)
+	for _, action := range report.Actions {
+		u := usages[action]
+		report.Missing = append(report.Missing, MissingPermission{
+			Action:        action,
+			ResourceTypes: sorted(u.resourceTypes),
+			Accounts:      sorted(u.accounts),
+			Regions:       sorted(u.regions),
+		})
+	}
+	report.Policy = NewPolicyDocument(report.Actions)
+	return report
+}
+
+//NewPolicyDocument returns an IAM policy document allowing the actions on all the resources, without statement if there is no action
+func NewPolicyDocument(actions []string) PolicyDocument {
+	policy := PolicyDocument{Version: "2012-10-17", Statement: []PolicyStatement{}}
+	if len(actions) > 0 {
+		policy.Statement = append(policy.Statement, PolicyStatement{Effect: "Allow", Action: actions, Resource: "*"})
+	}
+	return policy
+}
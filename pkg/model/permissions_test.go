@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPermissionReport(t *testing.T) {
+	denied := func(resourceType, account, region, action string) Event {
+		return Event{Type: EventTypeResource, Status: EventStatusFailed, ResourceType: resourceType, AccountId: account, Region: region, ErrorKind: ErrorKindPermission, ErrorAction: action}
+	}
+	engine := Event{RunId: "run-1", Type: EventTypeEngine, Status: EventStatusFailed, ChildEvents: Events{
+		denied("ec2.Instance", "a1", "us-east-1", "ec2:DescribeInstances"),
+		denied("ec2.Instance", "a2", "us-west-2", "ec2:DescribeInstances"),
+		denied("ec2.Instance", "a1", "us-west-2", "ec2:DescribeInstances"),
+		denied("s3.Bucket", "a1", "global", "s3:ListAllMyBuckets"),
+		//the other errors are ignored
+		{Type: EventTypeResource, Status: EventStatusFailed, ResourceType: "sns.Topic", ErrorKind: ErrorKindThrottling, ErrorAction: "sns:ListTopics"},
+		{Type: EventTypeResource, Status: EventStatusSuccess, ResourceType: "sqs.Queue"},
+	}}
+	report := NewPermissionReport(engine)
+	assert.Equal(t, "run-1", report.RunId)
+	assert.Equal(t, []string{"ec2:DescribeInstances", "s3:ListAllMyBuckets"}, report.Actions)
+	assert.Equal(t, []MissingPermission{
+		{Action: "ec2:DescribeInstances", ResourceTypes: []string{"ec2.Instance"}, Accounts: []string{"a1", "a2"}, Regions: []string{"us-east-1", "us-west-2"}},
+		{Action: "s3:ListAllMyBuckets", ResourceTypes: []string{"s3.Bucket"}, Accounts: []string{"a1"}, Regions: []string{"global"}},
+	}, report.Missing)
+	assert.Equal(t, PolicyDocument{Version: "2012-10-17", Statement: []PolicyStatement{
+		{Effect: "Allow", Action: []string{"ec2:DescribeInstances", "s3:ListAllMyBuckets"}, Resource: "*"},
+	}}, report.Policy)
+
+	//nothing missing
+	report = NewPermissionReport(Event{RunId: "run-2"})
+	assert.Empty(t, report.Actions)
+	assert.Empty(t, report.Policy.Statement)
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	//propertyPathSep separates the object keys in a property path, ex: Placement.AvailabilityZone
+	propertyPathSep = "."
+	//propertyPathArray is appended to a property path for each array element, ex: SecurityGroups[*].GroupId
+	propertyPathArray = "[*]"
+	//maxPropertyValueSize is the maximum size in bytes of a property value, the longer values are truncated
+	//the values are indexed and the size of an index entry is limited, ex: about 2.7 KB on Postgres
+	maxPropertyValueSize = 1024
+)
+
+//Property is a value found in the resource RawData, identified by its JSON path.
+//An array in RawData generates one property per element, all sharing the same path.
+type Property struct {
+	ResourceId string `json:"-" gorm:"primaryKey"`
+	Path       string `json:"path" gorm:"primaryKey;index:idx_properties_path_value,priority:1"`
+	Value      string `json:"value" gorm:"primaryKey;index:idx_properties_path_value,priority:2"`
+}
+
+type Properties []Property
+
+//Properties flattens the resource RawData into a list of properties.
+//Null values, empty objects and empty arrays are ignored, the long values are truncated: the RawData keeps them.
+func (r Resource) Properties() (Properties, error) {
+	if len(r.RawData) == 0 {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(r.RawData))
+	//keep the numbers as they are written in the JSON
+	decoder.UseNumber()
+	var rawData interface{}
+	if err := decoder.Decode(&rawData); err != nil {
+		return nil, fmt.Errorf("can't read the raw data of resource '%v': %w", r.Id, err)
+	}
+	//the same value can be present more than once in an array, only keep one
+	unique := make(map[Property]struct{})
+	var properties Properties
+	flattenProperties(rawData, "", func(path string, value string) {
+		p := Property{ResourceId: r.Id, Path: path, Value: truncateValue(value)}
+		if _, found := unique[p]; !found {
+			unique[p] = struct{}{}
+			properties = append(properties, p)
+		}
+	})
+	return properties, nil
+}
+
+func flattenProperties(data interface{}, path string, add func(path string, value string)) {
+	switch v := data.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		//iterate in order to always return the properties in the same order
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := key
+			if path != "" {
+				childPath = path + propertyPathSep + key
+			}
+			flattenProperties(v[key], childPath, add)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			flattenProperties(elem, path+propertyPathArray, add)
+		}
+	case string:
+		add(path, v)
+	default:
+		//json.Number or bool
+		add(path, fmt.Sprint(v))
+	}
+}
+
+//truncateValue returns the start of a value longer than maxPropertyValueSize, without splitting a character
+func truncateValue(value string) string {
+	if len(value) <= maxPropertyValueSize {
+		return value
+	}
+	end := maxPropertyValueSize
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end]
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceProperties(t *testing.T) {
+	r := Resource{
+		Id: "i-123",
+		RawData: []byte(`{
+  "InstanceType": "t3.small",
+  "AmiLaunchIndex": 0,
+  "EbsOptimized": false,
+  "KernelId": null,
+  "Placement": { "AvailabilityZone": "us-east-1a", "Tenancy": "default" },
+  "SecurityGroups": [
+    { "GroupId": "sg-1", "GroupName": "default" },
+    { "GroupId": "sg-2", "GroupName": "default" }
+  ],
+  "ProductCodes": [],
+  "Ipv6Addresses": ["::1", "::2"]
+}`),
+	}
+	properties, err := r.Properties()
+	require.NoError(t, err)
+	assert.Equal(t, Properties{
+		{ResourceId: "i-123", Path: "AmiLaunchIndex", Value: "0"},
+		{ResourceId: "i-123", Path: "EbsOptimized", Value: "false"},
+		{ResourceId: "i-123", Path: "InstanceType", Value: "t3.small"},
+		{ResourceId: "i-123", Path: "Ipv6Addresses[*]", Value: "::1"},
+		{ResourceId: "i-123", Path: "Ipv6Addresses[*]", Value: "::2"},
+		{ResourceId: "i-123", Path: "Placement.AvailabilityZone", Value: "us-east-1a"},
+		{ResourceId: "i-123", Path: "Placement.Tenancy", Value: "default"},
+		{ResourceId: "i-123", Path: "SecurityGroups[*].GroupId", Value: "sg-1"},
+		//the duplicate value is only returned once
+		{ResourceId: "i-123", Path: "SecurityGroups[*].GroupName", Value: "default"},
+		{ResourceId: "i-123", Path: "SecurityGroups[*].GroupId", Value: "sg-2"},
+	}, properties)
+
+	//no raw data
+	properties, err = Resource{Id: "i-123"}.Properties()
+	require.NoError(t, err)
+	assert.Empty(t, properties)
+
+	//invalid raw data
+	_, err = Resource{Id: "i-123", RawData: []byte(`{"foo"`)}.Properties()
+	assert.ErrorContains(t, err, "can't read the raw data of resource 'i-123'")
+}
+
+func TestResourcePropertiesLongValue(t *testing.T) {
+	//the values sharing the same start are truncated to the same value, it is only returned once
+	long := strings.Repeat("é", maxPropertyValueSize)
+	r := Resource{Id: "i-123", RawData: []byte(fmt.Sprintf(`{"UserData": ["%va", "%vb"], "Name": "web"}`, long, long))}
+	properties, err := r.Properties()
+	require.NoError(t, err)
+	require.Len(t, properties, 2)
+	assert.Equal(t, Property{ResourceId: "i-123", Path: "Name", Value: "web"}, properties[0])
+	value := properties[1].Value
+	//the characters are 2 bytes long, the value is truncated before the last one that doesn't fit
+	assert.Equal(t, maxPropertyValueSize, len(value))
+	assert.True(t, strings.HasPrefix(long, value))
+
+	assert.Equal(t, "web", truncateValue("web"))
+	assert.Equal(t, maxPropertyValueSize-1, len(truncateValue("a"+long)))
+}
@@ -9,9 +9,20 @@
 
 //TODO store provider info in resource (needed when we can have more than one provider)
 type Resource struct {
-	Id        string         `json:"id" gorm:"primaryKey"`
-	DisplayId string         `json:"displayId"`
-	AccountId string         `json:"accountId"`
+	Id        string `json:"id" gorm:"primaryKey"`
+	DisplayId string `json:"displayId"`
+	AccountId string `json:"accountId"`
+	//AccountName and OrganizationalUnit are only set when the accounts are discovered from an organization or a management group
+	//the default value is set on the resources written before the fields were added
+	AccountName string `json:"accountName,omitempty" gorm:"default:''"`
+	//OrganizationalUnit is the path of the organizational unit of the account, ex: Root/Production
+	OrganizationalUnit string `json:"organizationalUnit,omitempty" gorm:"default:''"`
+	//Namespace is the namespace of a Kubernetes object, empty for the other resources
+	Namespace string `json:"namespace,omitempty" gorm:"default:''"`
+	//Module is the address of the Terraform module declaring a resource read from a Terraform state, ex: module.vpc
+	Module string `json:"module,omitempty" gorm:"default:''"`
+	//ManagedBy is the tool managing the resource: terraform, cloudformation or none, it is set by the engine when writing the resource
+	ManagedBy string         `json:"managedBy,omitempty" gorm:"default:''"`
 	Region    string         `json:"region"`
 	Type      string         `json:"type"`
 	Tags      Tags           `json:"tags"`
@@ -19,6 +30,44 @@ type Resource struct {
 	UpdatedAt time.Time      `json:"updatedAt"`
 }
 
+//CoreField is a field stored in a column of the resources table, it is queried as core.<name>
+type CoreField struct {
+	//Name of the field and of its column, ex: account_id
+	Name string
+	//Value returns the value of the field as printed by the query command
+	Value func(r *Resource) string
+	//Listed fields have their values counted in the fields of the resources
+	Listed bool
+	//Optional fields are only listed if some resources have a value and are only hashed when set,
+	//the hash of the resources written before they were added doesn't change
+	Optional bool
+}
+
+//CoreFields are the fields of the resources table, a new field of the Resource struct is added here
+var CoreFields = []CoreField{
+	{Name: "id", Value: func(r *Resource) string { return r.Id }},
+	{Name: "display_id", Value: func(r *Resource) string { return r.EffectiveDisplayId() }},
+	{Name: "region", Value: func(r *Resource) string { return r.Region }, Listed: true},
+	{Name: "type", Value: func(r *Resource) string { return r.Type }, Listed: true},
+	{Name: "account_id", Value: func(r *Resource) string { return r.AccountId }, Listed: true},
+	{Name: "account_name", Value: func(r *Resource) string { return r.AccountName }, Listed: true, Optional: true},
+	{Name: "organizational_unit", Value: func(r *Resource) string { return r.OrganizationalUnit }, Listed: true, Optional: true},
+	{Name: "namespace", Value: func(r *Resource) string { return r.Namespace }, Listed: true, Optional: true},
+	{Name: "module", Value: func(r *Resource) string { return r.Module }, Listed: true, Optional: true},
+	{Name: "managed_by", Value: func(r *Resource) string { return r.ManagedBy }, Listed: true, Optional: true},
+	{Name: "updated_at", Value: func(r *Resource) string { return r.UpdatedAt.UTC().Format(time.RFC3339) }},
+}
+
+//FindCoreField returns the core field with a name, nil if not found
+func FindCoreField(name string) *CoreField {
+	for i := range CoreFields {
+		if CoreFields[i].Name == name {
+			return &CoreFields[i]
+		}
+	}
+	return nil
+}
+
 // EffectiveDisplayId returns the ID displayed to the user,
 // which is the DisplayId if set, else the Id
 func (r Resource) EffectiveDisplayId() string {
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+//ScanSummary summarizes a run of the engine, built from its events
+type ScanSummary struct {
+	RunId  string `json:"runId"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	//the duration of the scan, set by the caller
+	Duration       time.Duration `json:"duration"`
+	ResourcesCount int           `json:"resourcesCount"`
+	FetchCount     int           `json:"fetchCount"`
+	FailedCount    int           `json:"failedCount"`
+	//the resources fetched and the failures by resource type and by region, sorted by name
+	Types   []ScanCount `json:"types"`
+	Regions []ScanCount `json:"regions"`
+	//the events of the failed providers and resources, the resources fetched before a timeout included
+	Failures Events `json:"failures"`
+}
+
+//ScanCount counts the resources and the fetch failures for a resource type or a region
+type ScanCount struct {
+	Name           string `json:"name"`
+	ResourcesCount int    `json:"resourcesCount"`
+	FetchCount     int    `json:"fetchCount"`
+	FailedCount    int    `json:"failedCount"`
+}
+
+//NewScanSummary summarizes an engine event and its child events
+func NewScanSummary(engine Event) ScanSummary {
+	summary := ScanSummary{
+		RunId:    engine.RunId,
+		Status:   engine.Status,
+		Error:    engine.Error,
+		Types:    []ScanCount{},
+		Regions:  []ScanCount{},
+		Failures: Events{},
+	}
+	types := make(map[string]*ScanCount)
+	regions := make(map[string]*ScanCount)
+	count := func(counts map[string]*ScanCount, name string, event Event) {
+		c, found := counts[name]
+		if !found {
+			c = &ScanCount{Name: name}
+			counts[name] = c
+		}
+		c.ResourcesCount += event.ResourcesCount
+		c.FetchCount++
+		if event.failed() {
+			c.FailedCount++
+		}
+	}
+	for _, event := range engine.ChildEvents {
+		if event.failed() {
+			summary.Failures = append(summary.Failures, event)
+		}
+		if event.Type != EventTypeResource {
+			continue
+		}
+		summary.ResourcesCount += event.ResourcesCount
+		summary.FetchCount++
+		if event.failed() {
+			summary.FailedCount++
+		}
+		count(types, event.ResourceType, event)
+		count(regions, event.Region, event)
+	}
+	sorted := func(counts map[string]*ScanCount) []ScanCount {
+		result := make([]ScanCount, 0, len(counts))
+		for _, c := range counts {
+			result = append(result, *c)
+		}
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+		return result
+	}
+	summary.Types = sorted(types)
+	summary.Regions = sorted(regions)
+	return summary
+}
+
+//failed returns true if the event failed, a timeout is a failure: some resources may be missing
+func (e Event) failed() bool {
+	return e.Status == EventStatusFailed || e.Status == EventStatusTimeout
+}
+
+//Failed returns true if the engine failed or a provider or a resource could not be fetched
+func (s ScanSummary) Failed() bool {
+	return s.Status == EventStatusFailed || len(s.Failures) > 0
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewScanSummary(t *testing.T) {
+	engine := Event{
+		RunId:  "run-1",
+		Type:   EventTypeEngine,
+		Status: EventStatusSuccess,
+		ChildEvents: Events{
+			{Type: EventTypeProvider, Status: EventStatusSuccess, ProviderName: "aws"},
+			{Type: EventTypeProvider, Status: EventStatusFailed, ProviderName: "aws-prod", Error: "no credentials"},
+			{Type: EventTypeResource, Status: EventStatusSuccess, ResourceType: "ec2.Instance", Region: "us-east-1", ResourcesCount: 3},
+			{Type: EventTypeResource, Status: EventStatusFailed, ResourceType: "ec2.Instance", Region: "us-west-2", ResourcesCount: 1, Error: "throttled"},
+			{Type: EventTypeResource, Status: EventStatusSuccess, ResourceType: "s3.Bucket", Region: "global", ResourcesCount: 2},
+		},
+	}
+	summary := NewScanSummary(engine)
+	assert.Equal(t, "run-1", summary.RunId)
+	assert.Equal(t, 6, summary.ResourcesCount)
+	assert.Equal(t, 3, summary.FetchCount)
+	assert.Equal(t, 1, summary.FailedCount)
+	assert.Equal(t, []ScanCount{
+		{Name: "ec2.Instance", ResourcesCount: 4, FetchCount: 2, FailedCount: 1},
+		{Name: "s3.Bucket", ResourcesCount: 2, FetchCount: 1},
+	}, summary.Types)
+	assert.Equal(t, []ScanCount{
+		{Name: "global", ResourcesCount: 2, FetchCount: 1},
+		{Name: "us-east-1", ResourcesCount: 3, FetchCount: 1},
+		{Name: "us-west-2", ResourcesCount: 1, FetchCount: 1, FailedCount: 1},
+	}, summary.Regions)
+	//the provider failures are reported too
+	assert.Equal(t, 2, len(summary.Failures))
+	assert.Equal(t, "no credentials", summary.Failures[0].Error)
+	assert.True(t, summary.Failed())
+
+	//no failure
+	summary = NewScanSummary(Event{RunId: "run-2", Type: EventTypeEngine, Status: EventStatusSuccess})
+	assert.False(t, summary.Failed())
+	assert.Equal(t, []ScanCount{}, summary.Types)
+	assert.True(t, NewScanSummary(Event{Status: EventStatusFailed}).Failed())
+
+	//a timeout is a failure, the resources fetched before are counted
+	summary = NewScanSummary(Event{RunId: "run-3", Type: EventTypeEngine, Status: EventStatusFailed, ChildEvents: Events{
+		{Type: EventTypeResource, Status: EventStatusTimeout, ResourceType: "s3.Bucket", Region: "global", ResourcesCount: 5, Error: "timeout"},
+	}})
+	assert.Equal(t, 5, summary.ResourcesCount)
+	assert.Equal(t, 1, summary.FailedCount)
+	assert.Equal(t, 1, len(summary.Failures))
+}
@@ -6,8 +6,8 @@
 
 type Tag struct {
 	ResourceId string `json:"-" gorm:"primaryKey"`
-	Key        string `json:"key" gorm:"primaryKey"`
-	Value      string `json:"value"`
+	Key        string `json:"key" gorm:"primaryKey;index:idx_tags_key_value,priority:1"`
+	Value      string `json:"value" gorm:"index:idx_tags_key_value,priority:2"`
 }
 
 type Tags []Tag
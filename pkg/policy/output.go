@@ -0,0 +1,239 @@
+package policy
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/version"
+)
+
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+	OutputJUnit = "junit"
+	OutputSarif = "sarif"
+)
+
+var Outputs = []string{OutputTable, OutputJson, OutputJUnit, OutputSarif}
+
+//WriteReport writes the compliance report in an output format
+func WriteReport(out io.Writer, output string, report model.ComplianceReport) error {
+	switch output {
+	case OutputTable:
+		return writeTable(out, report)
+	case OutputJson:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case OutputJUnit:
+		return writeJUnit(out, report)
+	case OutputSarif:
+		return writeSarif(out, report)
+	}
+	return fmt.Errorf("unknown output '%v', must be one of %v", output, Outputs)
+}
+
+//writeTable writes the violations followed by the coverage of each policy
+func writeTable(out io.Writer, report model.ComplianceReport) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	violations := report.Violations()
+	if len(violations) > 0 {
+		fmt.Fprintln(w, "POLICY\tTYPE\tRESOURCE\tRULE\tMESSAGE")
+		resources := make(map[string]model.ResourceCompliance, len(report.Resources))
+		for _, r := range report.Resources {
+			resources[r.Id] = r
+		}
+		for _, v := range violations {
+			r := resources[v.ResourceId]
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", v.Policy, r.Type, r.DisplayId, v.Rule, v.Message)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "POLICY\tRESOURCES\tCOMPLIANT\tCOVERAGE")
+	for _, p := range report.Policies {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v%%\n", p.Name, p.ResourcesCount, p.CompliantCount, p.Coverage)
+	}
+	fmt.Fprintf(w, "TOTAL\t%v\t%v\t%v%%\n", report.ResourcesCount, report.CompliantCount, report.Coverage)
+	fmt.Fprintf(w, "\n%v violation(s)\n", len(violations))
+	return w.Flush()
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+//writeJUnit writes a test suite per policy, with a failed test case per resource in violation
+//a policy without violation has a single successful test case
+func writeJUnit(out io.Writer, report model.ComplianceReport) error {
+	suites := junitTestSuites{Name: "cloudgrep"}
+	resources := make(map[string]model.ResourceCompliance, len(report.Resources))
+	for _, r := range report.Resources {
+		resources[r.Id] = r
+	}
+	for _, p := range report.Policies {
+		suite := junitTestSuite{Name: p.Name}
+		//group the violations by resource, keeping their order
+		var resourceIds []string
+		byResource := make(map[string][]model.Violation)
+		for _, v := range p.Violations {
+			if _, found := byResource[v.ResourceId]; !found {
+				resourceIds = append(resourceIds, v.ResourceId)
+			}
+			byResource[v.ResourceId] = append(byResource[v.ResourceId], v)
+		}
+		for _, id := range resourceIds {
+			r := resources[id]
+			violations := byResource[id]
+			text := ""
+			for _, v := range violations {
+				text += v.Message + "\n"
+			}
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      fmt.Sprintf("%v %v (%v)", r.Type, r.DisplayId, r.Region),
+				ClassName: p.Name,
+				Failure:   &junitFailure{Message: violations[0].Message, Type: violations[0].Rule, Text: text},
+			})
+		}
+		suite.Failures = len(suite.TestCases)
+		if len(suite.TestCases) == 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      fmt.Sprintf("%v resource(s) compliant", p.CompliantCount),
+				ClassName: p.Name,
+			})
+		}
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationUri string      `json:"informationUri"`
+	Version        string      `json:"version"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+//writeSarif writes a rule per policy and a result per violation, the resources are logical locations
+func writeSarif(out io.Writer, report model.ComplianceReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "cloudgrep",
+			InformationUri: "https://github.com/juandiegopalomino/cloudgrep",
+			Version:        version.Get().Version,
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	resources := make(map[string]model.ResourceCompliance, len(report.Resources))
+	for _, r := range report.Resources {
+		resources[r.Id] = r
+	}
+	for _, p := range report.Policies {
+		description := p.Description
+		if description == "" {
+			description = p.Name
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{Id: p.Name, ShortDescription: sarifMessage{Text: description}})
+		for _, v := range p.Violations {
+			r := resources[v.ResourceId]
+			run.Results = append(run.Results, sarifResult{
+				RuleId:  p.Name,
+				Level:   "error",
+				Message: sarifMessage{Text: v.Message},
+				Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{
+					Name:               r.DisplayId,
+					FullyQualifiedName: fmt.Sprintf("%v/%v/%v/%v", r.AccountId, r.Region, r.Type, r.Id),
+					Kind:               "resource",
+				}}}},
+			})
+		}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
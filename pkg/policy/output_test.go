@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() model.ComplianceReport {
+	violations := []model.Violation{
+		{Policy: "team", ResourceId: "i-124", Key: "team", Value: "dev", Rule: model.RuleValues, Message: "tag 'team' has value 'dev', expected one of: infra"},
+		{Policy: "team", ResourceId: "i-124", Key: "env", Rule: model.RuleRequired, Message: "missing tag 'env'"},
+	}
+	return model.ComplianceReport{
+		ResourcesCount: 2,
+		CompliantCount: 1,
+		Coverage:       50,
+		Policies: []model.PolicyCompliance{
+			{Name: "team", Description: "resources must have an owner", ResourcesCount: 2, CompliantCount: 1, Coverage: 50, Violations: violations},
+			{Name: "release", ResourcesCount: 1, CompliantCount: 1, Coverage: 100, Violations: []model.Violation{}},
+		},
+		Resources: []model.ResourceCompliance{
+			{Id: "i-124", DisplayId: "i-124", AccountId: "123456789012", Region: "us-east-1", Type: "ec2.Instance", Violations: violations},
+		},
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteReport(buf, OutputTable, testReport()))
+	assert.Equal(t, `POLICY  TYPE          RESOURCE  RULE      MESSAGE
+team    ec2.Instance  i-124     values    tag 'team' has value 'dev', expected one of: infra
+team    ec2.Instance  i-124     required  missing tag 'env'
+
+POLICY   RESOURCES  COMPLIANT  COVERAGE
+team     2          1          50%
+release  1          1          100%
+TOTAL    2          1          50%
+
+2 violation(s)
+`, buf.String())
+}
+
+func TestWriteJson(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteReport(buf, OutputJson, testReport()))
+	var report model.ComplianceReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, testReport(), report)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteReport(buf, OutputJUnit, testReport()))
+	assert.Contains(t, buf.String(), `<?xml version="1.0" encoding="UTF-8"?>`)
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	assert.Equal(t, 2, suites.Tests)
+	assert.Equal(t, 1, suites.Failures)
+	require.Equal(t, 2, len(suites.Suites))
+	//one failed test case for the resource in violation
+	team := suites.Suites[0]
+	assert.Equal(t, "team", team.Name)
+	require.Equal(t, 1, len(team.TestCases))
+	assert.Equal(t, "ec2.Instance i-124 (us-east-1)", team.TestCases[0].Name)
+	require.NotNil(t, team.TestCases[0].Failure)
+	assert.Equal(t, "tag 'team' has value 'dev', expected one of: infra\nmissing tag 'env'\n", team.TestCases[0].Failure.Text)
+	//one successful test case for a compliant policy
+	release := suites.Suites[1]
+	assert.Equal(t, 0, release.Failures)
+	require.Equal(t, 1, len(release.TestCases))
+	assert.Nil(t, release.TestCases[0].Failure)
+}
+
+func TestWriteSarif(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, WriteReport(buf, OutputSarif, testReport()))
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Equal(t, 1, len(log.Runs))
+	run := log.Runs[0]
+	assert.Equal(t, "cloudgrep", run.Tool.Driver.Name)
+	assert.Equal(t, []sarifRule{
+		{Id: "team", ShortDescription: sarifMessage{Text: "resources must have an owner"}},
+		{Id: "release", ShortDescription: sarifMessage{Text: "release"}},
+	}, run.Tool.Driver.Rules)
+	require.Equal(t, 2, len(run.Results))
+	assert.Equal(t, "team", run.Results[0].RuleId)
+	assert.Equal(t, "missing tag 'env'", run.Results[1].Message.Text)
+	assert.Equal(t, "123456789012/us-east-1/ec2.Instance/i-124", run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName)
+}
+
+func TestWriteUnknownOutput(t *testing.T) {
+	require.ErrorContains(t, WriteReport(new(bytes.Buffer), "yaml", testReport()), "unknown output 'yaml'")
+}
@@ -0,0 +1,226 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+)
+
+const (
+	caseLower = "lower"
+	caseUpper = "upper"
+)
+
+//Evaluator checks the tags of the resources against the tag policies
+type Evaluator struct {
+	policies []policy
+}
+
+type policy struct {
+	config.Policy
+	tags []tagRule
+}
+
+type tagRule struct {
+	config.TagRule
+	values  map[string]bool
+	pattern *regexp.Regexp
+}
+
+//NewEvaluator validates the policies and returns an evaluator for them
+func NewEvaluator(policies []config.Policy) (*Evaluator, error) {
+	e := &Evaluator{}
+	names := make(map[string]bool)
+	for i, p := range policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("policy #%v: missing name", i+1)
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("policy '%v': the name is already used", p.Name)
+		}
+		names[p.Name] = true
+		if len(p.Tags) == 0 {
+			return nil, fmt.Errorf("policy '%v': no tag rule", p.Name)
+		}
+		for _, pattern := range append(append(append([]string{}, p.Scope.Types...), p.Scope.Accounts...), p.Scope.Regions...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy '%v': invalid scope pattern '%v': %w", p.Name, pattern, err)
+			}
+		}
+		compiled := policy{Policy: p}
+		for _, t := range p.Tags {
+			if t.Key == "" {
+				return nil, fmt.Errorf("policy '%v': missing tag key", p.Name)
+			}
+			if t.Case != "" && t.Case != caseLower && t.Case != caseUpper {
+				return nil, fmt.Errorf("policy '%v': invalid case '%v' for tag '%v', must be %v or %v", p.Name, t.Case, t.Key, caseLower, caseUpper)
+			}
+			rule := tagRule{TagRule: t}
+			if len(t.Values) > 0 {
+				rule.values = make(map[string]bool, len(t.Values))
+				for _, v := range t.Values {
+					rule.values[v] = true
+				}
+			}
+			if t.Pattern != "" {
+				pattern, err := regexp.Compile(t.Pattern)
+				if err != nil {
+					return nil, fmt.Errorf("policy '%v': invalid pattern for tag '%v': %w", p.Name, t.Key, err)
+				}
+				rule.pattern = pattern
+			}
+			compiled.tags = append(compiled.tags, rule)
+		}
+		e.policies = append(e.policies, compiled)
+	}
+	return e, nil
+}
+
+//Empty returns true if there is no policy to evaluate
+func (e *Evaluator) Empty() bool {
+	return len(e.policies) == 0
+}
+
+//Evaluate returns the compliance report of the resources
+func (e *Evaluator) Evaluate(resources model.Resources) model.ComplianceReport {
+	report := model.ComplianceReport{
+		CreatedAt: time.Now(),
+		Policies:  []model.PolicyCompliance{},
+		Resources: []model.ResourceCompliance{},
+	}
+	inScope := make(map[string]bool)
+	//the position of a resource in the report
+	resourceIndex := make(map[string]int)
+	for _, p := range e.policies {
+		result := model.PolicyCompliance{
+			Name:        p.Name,
+			Description: p.Description,
+			Tags:        make([]model.TagCompliance, len(p.tags)),
+			Violations:  []model.Violation{},
+		}
+		for i, rule := range p.tags {
+			result.Tags[i].Key = rule.Key
+		}
+		for _, r := range resources {
+			if !p.matches(r) {
+				continue
+			}
+			inScope[r.Id] = true
+			result.ResourcesCount++
+			var violations []model.Violation
+			for i, rule := range p.tags {
+				violation := rule.check(r)
+				if violation == nil {
+					result.Tags[i].CompliantCount++
+					continue
+				}
+				violation.Policy = p.Name
+				violations = append(violations, *violation)
+			}
+			if len(violations) == 0 {
+				result.CompliantCount++
+				continue
+			}
+			result.Violations = append(result.Violations, violations...)
+			index, found := resourceIndex[r.Id]
+			if !found {
+				index = len(report.Resources)
+				resourceIndex[r.Id] = index
+				report.Resources = append(report.Resources, model.ResourceCompliance{
+					Id:        r.Id,
+					DisplayId: r.EffectiveDisplayId(),
+					AccountId: r.AccountId,
+					Region:    r.Region,
+					Type:      r.Type,
+				})
+			}
+			report.Resources[index].Violations = append(report.Resources[index].Violations, violations...)
+		}
+		result.Coverage = model.Coverage(result.CompliantCount, result.ResourcesCount)
+		for i := range result.Tags {
+			result.Tags[i].Coverage = model.Coverage(result.Tags[i].CompliantCount, result.ResourcesCount)
+		}
+		report.Policies = append(report.Policies, result)
+	}
+	report.ResourcesCount = len(inScope)
+	report.CompliantCount = report.ResourcesCount - len(report.Resources)
+	report.Coverage = model.Coverage(report.CompliantCount, report.ResourcesCount)
+	return report
+}
+
+//matches returns true if the resource is in the scope of the policy
+func (p policy) matches(r *model.Resource) bool {
+	return matchesAny(p.Scope.Types, r.Type) && matchesAny(p.Scope.Accounts, r.AccountId) && matchesAny(p.Scope.Regions, r.Region)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		//the patterns are validated when the evaluator is created
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+//check returns the violation of the rule by the resource, nil if the resource respects the rule
+func (rule tagRule) check(r *model.Resource) *model.Violation {
+	violation := func(ruleName string, value string, format string, a ...any) *model.Violation {
+		return &model.Violation{
+			ResourceId: r.Id,
+			Key:        rule.Key,
+			Value:      value,
+			Rule:       ruleName,
+			Message:    fmt.Sprintf(format, a...),
+		}
+	}
+	tag := r.Tags.Find(rule.Key)
+	if tag == nil {
+		if rule.Required {
+			return violation(model.RuleRequired, "", "missing tag '%v'", rule.Key)
+		}
+		return nil
+	}
+	value := tag.Value
+	if rule.values != nil && !rule.values[value] {
+		return violation(model.RuleValues, value, "tag '%v' has value '%v', expected one of: %v", rule.Key, value, strings.Join(rule.Values, ", "))
+	}
+	if rule.pattern != nil && !rule.pattern.MatchString(value) {
+		return violation(model.RulePattern, value, "tag '%v' has value '%v', expected to match '%v'", rule.Key, value, rule.Pattern)
+	}
+	switch rule.Case {
+	case caseLower:
+		if strings.ToLower(value) != value {
+			return violation(model.RuleCase, value, "tag '%v' has value '%v', expected lower case", rule.Key, value)
+		}
+	case caseUpper:
+		if strings.ToUpper(value) != value {
+			return violation(model.RuleCase, value, "tag '%v' has value '%v', expected upper case", rule.Key, value)
+		}
+	}
+	return nil
+}
+
+//EvaluateDatastore evaluates the policies on the resources of a datastore and stores the report
+func (e *Evaluator) EvaluateDatastore(ctx context.Context, ds datastore.Datastore, runId string) (model.ComplianceReport, error) {
+	resources, err := ds.GetAllResources(ctx, nil)
+	if err != nil {
+		return model.ComplianceReport{}, fmt.Errorf("can't get the resources to evaluate the policies: %w", err)
+	}
+	report := e.Evaluate(resources)
+	report.RunId = runId
+	if err := ds.WriteComplianceReport(ctx, report); err != nil {
+		return model.ComplianceReport{}, err
+	}
+	return report, nil
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore/testdata"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestNewEvaluator(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policies []config.Policy
+		err      string
+	}{
+		{"NoPolicy", nil, ""},
+		{"Valid", []config.Policy{{Name: "p1", Scope: config.PolicyScope{Types: []string{"ec2.*"}}, Tags: []config.TagRule{{Key: "team", Pattern: "^[a-z]+$", Case: "lower"}}}}, ""},
+		{"MissingName", []config.Policy{{Tags: []config.TagRule{{Key: "team"}}}}, "policy #1: missing name"},
+		{"DuplicateName", []config.Policy{{Name: "p1", Tags: []config.TagRule{{Key: "team"}}}, {Name: "p1", Tags: []config.TagRule{{Key: "env"}}}}, "the name is already used"},
+		{"NoRule", []config.Policy{{Name: "p1"}}, "no tag rule"},
+		{"MissingKey", []config.Policy{{Name: "p1", Tags: []config.TagRule{{Required: true}}}}, "missing tag key"},
+		{"InvalidCase", []config.Policy{{Name: "p1", Tags: []config.TagRule{{Key: "team", Case: "camel"}}}}, "invalid case 'camel'"},
+		{"InvalidPattern", []config.Policy{{Name: "p1", Tags: []config.TagRule{{Key: "team", Pattern: "[a-z"}}}}, "invalid pattern for tag 'team'"},
+		{"InvalidScope", []config.Policy{{Name: "p1", Scope: config.PolicyScope{Regions: []string{"us-[east"}}, Tags: []config.TagRule{{Key: "team"}}}}, "invalid scope pattern"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewEvaluator(tc.policies)
+			if tc.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	//i-123: team=infra env=prod, i-124: team=dev env=dev, a-bucket-123: no tag
+	resources := testdata.GetResources(t)
+	evaluator, err := NewEvaluator([]config.Policy{
+		{
+			Name:  "team",
+			Scope: config.PolicyScope{Types: []string{"test.*"}, Regions: []string{"us-east-1"}},
+			Tags: []config.TagRule{
+				{Key: "team", Required: true, Values: []string{"infra", "marketplace"}},
+				{Key: "env", Pattern: "^(prod|staging)$"},
+			},
+		},
+		{
+			Name: "release",
+			Tags: []config.TagRule{
+				{Key: "release", Required: true, Case: "upper"},
+			},
+		},
+		{
+			Name:  "other-account",
+			Scope: config.PolicyScope{Accounts: []string{"000000000000"}},
+			Tags:  []config.TagRule{{Key: "team", Required: true}},
+		},
+	})
+	require.NoError(t, err)
+	report := evaluator.Evaluate(resources)
+
+	require.Equal(t, 3, len(report.Policies))
+	team := report.Policies[0]
+	assert.Equal(t, 2, team.ResourcesCount)
+	assert.Equal(t, 1, team.CompliantCount)
+	assert.Equal(t, 50.0, team.Coverage)
+	assert.Equal(t, []model.TagCompliance{{Key: "team", CompliantCount: 1, Coverage: 50}, {Key: "env", CompliantCount: 1, Coverage: 50}}, team.Tags)
+	assert.Equal(t, []model.Violation{
+		{Policy: "team", ResourceId: "i-124", Key: "team", Value: "dev", Rule: model.RuleValues, Message: "tag 'team' has value 'dev', expected one of: infra, marketplace"},
+		{Policy: "team", ResourceId: "i-124", Key: "env", Value: "dev", Rule: model.RulePattern, Message: "tag 'env' has value 'dev', expected to match '^(prod|staging)$'"},
+	}, team.Violations)
+
+	release := report.Policies[1]
+	assert.Equal(t, 3, release.ResourcesCount)
+	assert.Equal(t, 0, release.CompliantCount)
+	assert.Equal(t, 0.0, release.Coverage)
+	require.Equal(t, 3, len(release.Violations))
+	assert.Equal(t, model.Violation{Policy: "release", ResourceId: "i-123", Key: "release", Value: "v1", Rule: model.RuleCase, Message: "tag 'release' has value 'v1', expected upper case"}, release.Violations[0])
+	assert.Equal(t, model.RuleRequired, release.Violations[1].Rule)
+
+	//no resource in scope
+	assert.Equal(t, 0, report.Policies[2].ResourcesCount)
+	assert.Equal(t, 100.0, report.Policies[2].Coverage)
+
+	//all the resources have a violation
+	assert.Equal(t, 3, report.ResourcesCount)
+	assert.Equal(t, 0, report.CompliantCount)
+	require.Equal(t, 3, len(report.Resources))
+	//in the order of their first violation
+	assert.Equal(t, "i-124", report.Resources[0].Id)
+	assert.Equal(t, 3, len(report.Resources[0].Violations))
+	assert.Equal(t, "i-123", report.Resources[1].Id)
+	assert.Equal(t, 1, len(report.Resources[1].Violations))
+	assert.Equal(t, "a-bucket-123", report.Resources[2].Id)
+	assert.Equal(t, 5, len(report.Violations()))
+}
+
+func TestEvaluateDatastore(t *testing.T) {
+	ctx := context.Background()
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore.DataSourceName = "file::memory:"
+	ds, err := datastore.NewDatastore(ctx, cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.NoError(t, ds.WriteResources(ctx, testdata.GetResources(t)))
+
+	evaluator, err := NewEvaluator([]config.Policy{{Name: "team", Tags: []config.TagRule{{Key: "team", Required: true}}}})
+	require.NoError(t, err)
+	report, err := evaluator.EvaluateDatastore(ctx, ds, "run-1")
+	require.NoError(t, err)
+	assert.Equal(t, "run-1", report.RunId)
+	assert.Equal(t, 3, report.ResourcesCount)
+	assert.Equal(t, 2, report.CompliantCount)
+	assert.Equal(t, 66.67, report.Coverage)
+
+	//the report is stored
+	stored, err := ds.GetComplianceReport(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "run-1", stored.RunId)
+	assert.Equal(t, report.Policies, stored.Policies)
+	assert.Equal(t, report.Resources, stored.Resources)
+}
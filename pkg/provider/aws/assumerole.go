@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+)
+
+const (
+	//accountPlaceholder is replaced by the account id in the role ARN template
+	accountPlaceholder = "{account}"
+	//defaultSessionName is the name of the role sessions if not configured
+	defaultSessionName = "cloudgrep"
+)
+
+//validateAssumeRole returns an error if the assume role config is incomplete
+func validateAssumeRole(c cfg.AssumeRole) error {
+	if c.RoleArnTemplate != "" && !strings.Contains(c.RoleArnTemplate, accountPlaceholder) {
+		return fmt.Errorf("invalid roleArnTemplate %v, it must contain %v", c.RoleArnTemplate, accountPlaceholder)
+	}
+	if c.RoleArnTemplate == "" && len(c.Accounts) > 0 {
+		return fmt.Errorf("invalid assumeRole: the accounts are set without a roleArnTemplate")
+	}
+	for _, roleArn := range c.RoleArns {
+		if !strings.HasPrefix(roleArn, "arn:") {
+			return fmt.Errorf("invalid role ARN %v", roleArn)
+		}
+	}
+	return nil
+}
+
+//roleArns returns the ARNs of the roles to assume: the configured ARNs followed by the template for each account
+func roleArns(c cfg.AssumeRole) []string {
+	arns := append([]string{}, c.RoleArns...)
+	if c.RoleArnTemplate != "" {
+		for _, account := range c.Accounts {
+			arns = append(arns, strings.ReplaceAll(c.RoleArnTemplate, accountPlaceholder, account))
+		}
+	}
+	return arns
+}
+
+//sourceProfile returns the profile used to load the credentials, they assume the roles if any
+func sourceProfile(c cfg.Provider) string {
+	if c.AssumeRole.SourceProfile != "" {
+		return c.AssumeRole.SourceProfile
+	}
+	return c.Profile
+}
+
+//assumeRoleConfig returns a copy of the source config with the credentials of the assumed role
+func assumeRoleConfig(sourceConfig aws.Config, roleArn string, c cfg.AssumeRole) aws.Config {
+	stsConfig := sourceConfig.Copy()
+	if stsConfig.Region == "" {
+		stsConfig.Region = "us-east-1"
+	}
+	sessionName := c.SessionName
+	if sessionName == "" {
+		sessionName = defaultSessionName
+	}
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(stsConfig), roleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if c.ExternalId != "" {
+			o.ExternalID = aws.String(c.ExternalId)
+		}
+	})
+	accountConfig := sourceConfig.Copy()
+	//the credentials are cached and refreshed before they expire
+	accountConfig.Credentials = aws.NewCredentialsCache(provider)
+	return accountConfig
+}
@@ -0,0 +1,147 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestValidateAssumeRole(t *testing.T) {
+	assert.NoError(t, validateAssumeRole(cfg.AssumeRole{}))
+	assert.NoError(t, validateAssumeRole(cfg.AssumeRole{RoleArns: []string{"arn:aws:iam::111111111111:role/r"}, RoleArnTemplate: "arn:aws:iam::{account}:role/r", Accounts: []string{"222222222222"}}))
+	assert.ErrorContains(t, validateAssumeRole(cfg.AssumeRole{RoleArnTemplate: "arn:aws:iam::111111111111:role/r"}), "it must contain {account}")
+	assert.ErrorContains(t, validateAssumeRole(cfg.AssumeRole{Accounts: []string{"222222222222"}}), "without a roleArnTemplate")
+	assert.ErrorContains(t, validateAssumeRole(cfg.AssumeRole{RoleArns: []string{"cloudgrep-readonly"}}), "invalid role ARN")
+}
+
+func TestRoleArns(t *testing.T) {
+	arns := roleArns(cfg.AssumeRole{
+		RoleArns:        []string{"arn:aws:iam::111111111111:role/admin"},
+		RoleArnTemplate: "arn:aws:iam::{account}:role/cloudgrep-readonly",
+		Accounts:        []string{"222222222222", "333333333333"},
+	})
+	assert.Equal(t, []string{
+		"arn:aws:iam::111111111111:role/admin",
+		"arn:aws:iam::222222222222:role/cloudgrep-readonly",
+		"arn:aws:iam::333333333333:role/cloudgrep-readonly",
+	}, arns)
+}
+
+//stsServer is a stub of the STS API, the role of the account "denied" can't be assumed
+//the credentials of a role are named after its account, they return this account as the caller identity
+type stsServer struct {
+	lock     sync.Mutex
+	assumed  []string
+	external []string
+	sessions []string
+}
+
+var credentialRegexp = regexp.MustCompile(`Credential=([^/]+)/`)
+
+func (s *stsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch r.Form.Get("Action") {
+	case "AssumeRole":
+		roleArn := r.Form.Get("RoleArn")
+		s.lock.Lock()
+		s.assumed = append(s.assumed, roleArn)
+		s.external = append(s.external, r.Form.Get("ExternalId"))
+		s.sessions = append(s.sessions, r.Form.Get("RoleSessionName"))
+		s.lock.Unlock()
+		account := strings.Split(roleArn, ":")[4]
+		if account == "denied" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized to perform: sts:AssumeRole</Message></Error><RequestId>1</RequestId></ErrorResponse>`)
+			return
+		}
+		fmt.Fprintf(w, `<AssumeRoleResponse><AssumeRoleResult><Credentials><AccessKeyId>%v</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>2100-01-01T00:00:00Z</Expiration></Credentials><AssumedRoleUser><Arn>arn:aws:sts::%v:assumed-role/cloudgrep-readonly/cloudgrep</Arn><AssumedRoleId>AROA:cloudgrep</AssumedRoleId></AssumedRoleUser></AssumeRoleResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></AssumeRoleResponse>`, account, account)
+	case "GetCallerIdentity":
+		account := "source"
+		if match := credentialRegexp.FindStringSubmatch(r.Header.Get("Authorization")); match != nil {
+			account = match[1]
+		}
+		fmt.Fprintf(w, `<GetCallerIdentityResponse><GetCallerIdentityResult><Arn>arn:aws:sts::%v:assumed-role/cloudgrep-readonly/cloudgrep</Arn><UserId>AROA:cloudgrep</UserId><Account>%v</Account></GetCallerIdentityResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></GetCallerIdentityResponse>`, account, account)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newSourceConfig(url string) aws.Config {
+	return aws.Config{
+		Region: "us-east-1",
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "source", SecretAccessKey: "secret"}, nil
+		}),
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: url}, nil
+		}),
+	}
+}
+
+func TestNewProviders_assumeRole(t *testing.T) {
+	stub := &stsServer{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	providerCfg := cfg.Provider{
+		Cloud:   "aws",
+		Regions: []string{"us-east-1", "global"},
+		AssumeRole: cfg.AssumeRole{
+			RoleArnTemplate: "arn:aws:iam::{account}:role/cloudgrep-readonly",
+			Accounts:        []string{"111111111111", "denied", "222222222222"},
+			ExternalId:      "external-id",
+		},
+	}
+	providers, err := newProviders(context.Background(), providerCfg, newSourceConfig(server.URL), zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	//a provider per account and region, the account which can't be assumed is skipped
+	var names []string
+	for _, p := range providers {
+		names = append(names, p.AccountId()+"/"+p.Region())
+	}
+	assert.Equal(t, []string{"111111111111/us-east-1", "111111111111/global", "222222222222/us-east-1", "222222222222/global"}, names)
+	assert.Equal(t, []string{
+		"arn:aws:iam::111111111111:role/cloudgrep-readonly",
+		"arn:aws:iam::denied:role/cloudgrep-readonly",
+		"arn:aws:iam::222222222222:role/cloudgrep-readonly",
+	}, stub.assumed)
+	assert.Equal(t, []string{"external-id", "external-id", "external-id"}, stub.external)
+	assert.Equal(t, []string{"cloudgrep", "cloudgrep", "cloudgrep"}, stub.sessions)
+
+	//the providers use the credentials of the role
+	creds, err := providers[2].(Provider).config.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "222222222222", creds.AccessKeyID)
+
+	//no account can be assumed
+	providerCfg.AssumeRole = cfg.AssumeRole{RoleArns: []string{"arn:aws:iam::denied:role/cloudgrep-readonly"}, SessionName: "audit"}
+	_, err = newProviders(context.Background(), providerCfg, newSourceConfig(server.URL), zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "can't assume the role arn:aws:iam::denied:role/cloudgrep-readonly")
+	assert.ErrorContains(t, err, "AccessDenied")
+	assert.Equal(t, "audit", stub.sessions[len(stub.sessions)-1])
+}
+
+func TestNewProviders_sourceAccount(t *testing.T) {
+	server := httptest.NewServer(&stsServer{})
+	defer server.Close()
+
+	providers, err := newProviders(context.Background(), cfg.Provider{Cloud: "aws", Regions: []string{"us-east-1"}}, newSourceConfig(server.URL), zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, "source", providers[0].AccountId())
+}
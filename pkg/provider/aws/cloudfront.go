@@ -15,6 +15,10 @@ func (p *Provider) register_cloudfront(mapping map[string]mapper) {
 		FetchFunc: p.fetch_cloudfront_Distribution,
 		IdField:   "Id",
 		IsGlobal:  true,
+		IAMActions: []string{
+			"cloudfront:ListDistributions",
+			"cloudfront:ListTagsForResource",
+		},
 	}
 }
 
@@ -17,12 +17,21 @@ func (p *Provider) register_eks(mapping map[string]mapper) {
 		IdField:        "Arn",
 		DisplayIDField: "Name",
 		IsGlobal:       false,
+		IAMActions: []string{
+			"eks:ListClusters",
+			"eks:DescribeCluster",
+		},
 	}
 	mapping["eks.Nodegroup"] = mapper{
 		FetchFunc:      p.fetch_eks_Nodegroup,
 		IdField:        "NodegroupArn",
 		DisplayIDField: "NodegroupName",
 		IsGlobal:       false,
+		IAMActions: []string{
+			"eks:ListClusters",
+			"eks:ListNodegroups",
+			"eks:DescribeNodegroup",
+		},
 	}
 }
 
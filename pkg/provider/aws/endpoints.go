@@ -0,0 +1,92 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+)
+
+//validateEndpoints returns an error if an endpoint is not an http or https URL
+func validateEndpoints(c cfg.Endpoints) error {
+	urls := map[string]string{"url": c.URL}
+	for service, endpoint := range c.Services {
+		urls["services."+service] = endpoint
+	}
+	for name, endpoint := range urls {
+		if endpoint == "" && name == "url" {
+			continue
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint %v: '%v' is not an http or https URL", name, endpoint)
+		}
+	}
+	return nil
+}
+
+//endpointOptions returns the options to load the AWS config with the endpoints
+func endpointOptions(c cfg.Endpoints) ([]func(*config.LoadOptions) error, error) {
+	var options []func(*config.LoadOptions) error
+	if resolver := endpointResolver(c); resolver != nil {
+		options = append(options, config.WithEndpointResolverWithOptions(resolver))
+	}
+	if c.InsecureSkipVerify {
+		client := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			if tr.TLSClientConfig == nil {
+				tr.TLSClientConfig = &tls.Config{}
+			}
+			tr.TLSClientConfig.InsecureSkipVerify = true
+		})
+		options = append(options, config.WithHTTPClient(client))
+	}
+	if c.CABundle != "" {
+		bundle, err := os.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the CA bundle: %w", err)
+		}
+		options = append(options, config.WithCustomCABundle(bytes.NewReader(bundle)))
+	}
+	return options, nil
+}
+
+//endpointResolver returns the resolver of the configured endpoints, nil if no endpoint is configured
+//the default endpoint is used for the services without a configured endpoint
+func endpointResolver(c cfg.Endpoints) aws.EndpointResolverWithOptions {
+	if c.URL == "" && len(c.Services) == 0 {
+		return nil
+	}
+	services := make(map[string]string, len(c.Services))
+	for service, endpoint := range c.Services {
+		services[serviceKey(service)] = endpoint
+	}
+	return aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		key := serviceKey(service)
+		endpoint, found := services[key]
+		if !found {
+			endpoint = c.URL
+		}
+		if endpoint == "" {
+			return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+		}
+		return aws.Endpoint{
+			URL:    endpoint,
+			Source: aws.EndpointSourceCustom,
+			//the S3 client keeps the bucket name in the path if the host name is immutable
+			HostnameImmutable: key == "s3" && c.S3UsePathStyle,
+		}, nil
+	})
+}
+
+//serviceKey returns the key of a service in the endpoints, ex: "Elastic Load Balancing v2" -> "elasticloadbalancingv2"
+func serviceKey(service string) string {
+	return strings.ToLower(strings.ReplaceAll(service, " ", ""))
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestValidateEndpoints(t *testing.T) {
+	assert.NoError(t, validateEndpoints(cfg.Endpoints{}))
+	assert.NoError(t, validateEndpoints(cfg.Endpoints{URL: "http://localhost:4566", Services: map[string]string{"ec2": "https://vpce.ec2.us-east-1.vpce.amazonaws.com"}}))
+	assert.ErrorContains(t, validateEndpoints(cfg.Endpoints{URL: "localhost:4566"}), "invalid endpoint url: 'localhost:4566' is not an http or https URL")
+	assert.ErrorContains(t, validateEndpoints(cfg.Endpoints{Services: map[string]string{"s3": ""}}), "invalid endpoint services.s3")
+}
+
+func TestEndpointResolver(t *testing.T) {
+	assert.Nil(t, endpointResolver(cfg.Endpoints{}))
+
+	resolver := endpointResolver(cfg.Endpoints{
+		URL:            "http://localhost:4566",
+		Services:       map[string]string{"Ec2": "https://ec2.internal", "s3": "https://s3.internal", "elasticloadbalancingv2": "https://elb.internal"},
+		S3UsePathStyle: true,
+	})
+	endpoint, err := resolver.ResolveEndpoint("EC2", "us-east-1")
+	require.NoError(t, err)
+	assert.Equal(t, aws.Endpoint{URL: "https://ec2.internal", Source: aws.EndpointSourceCustom}, endpoint)
+	endpoint, err = resolver.ResolveEndpoint("Elastic Load Balancing v2", "us-east-1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://elb.internal", endpoint.URL)
+	endpoint, err = resolver.ResolveEndpoint("S3", "eu-west-1")
+	require.NoError(t, err)
+	assert.Equal(t, aws.Endpoint{URL: "https://s3.internal", Source: aws.EndpointSourceCustom, HostnameImmutable: true}, endpoint)
+	endpoint, err = resolver.ResolveEndpoint("SQS", "us-east-1")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:4566", endpoint.URL)
+
+	//the default endpoint is used for the other services
+	resolver = endpointResolver(cfg.Endpoints{Services: map[string]string{"ec2": "https://ec2.internal"}})
+	_, err = resolver.ResolveEndpoint("SQS", "us-east-1")
+	var notFound *aws.EndpointNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+}
+
+//newS3Server returns a TLS stub of the STS and S3 APIs, the S3 requests must use the path style
+func newS3Server(t *testing.T) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			(&stsServer{}).ServeHTTP(w, r)
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `<ListAllMyBucketsResult><Buckets><Bucket><Name>my-bucket</Name></Bucket></Buckets><Owner><ID>1</ID></Owner></ListAllMyBucketsResult>`)
+		case r.URL.Path == "/my-bucket" && r.URL.Query().Has("location"):
+			fmt.Fprint(w, `<LocationConstraint>eu-west-1</LocationConstraint>`)
+		case r.URL.Path == "/my-bucket" && r.URL.Query().Has("tagging"):
+			fmt.Fprint(w, `<Tagging><TagSet><Tag><Key>team</Key><Value>infra</Value></Tag></TagSet></Tagging>`)
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewProviders_endpoints(t *testing.T) {
+	server := newS3Server(t)
+	t.Setenv("AWS_ACCESS_KEY_ID", "source")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	providerCfg := cfg.Provider{
+		Cloud:     "aws",
+		Regions:   []string{"global"},
+		Endpoints: cfg.Endpoints{URL: server.URL, S3UsePathStyle: true},
+	}
+	providerCfg.Endpoints.InsecureSkipVerify = true
+	providers, err := NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, "source", providers[0].AccountId())
+
+	//the real fetch function is called on the endpoint
+	output := make(chan model.Resource, 10)
+	require.NoError(t, providers[0].FetchFunctions()["s3.Bucket"](context.Background(), output))
+	close(output)
+	var resources []model.Resource
+	for r := range output {
+		resources = append(resources, r)
+	}
+	require.Len(t, resources, 1)
+	assert.Equal(t, "my-bucket", resources[0].Id)
+	assert.Equal(t, model.Tags{{Key: "team", Value: "infra"}}, resources[0].Tags)
+
+	//the certificate of the server is not trusted, the request is not retried
+	providerCfg.Endpoints.InsecureSkipVerify = false
+	providerCfg.RateLimit.MaxAttempts = 1
+	_, err = NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "certificate")
+
+	//the CA bundle must be readable
+	providerCfg.Endpoints.CABundle = "/nonexistent/ca.pem"
+	_, err = NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "can't read the CA bundle")
+}
@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+)
+
+//permissionErrorCodes are the error codes returned when the credentials are not allowed to call an API
+var permissionErrorCodes = map[string]struct{}{
+	"AccessDenied":          {},
+	"AccessDeniedException": {},
+	"UnauthorizedOperation": {},
+	"UnauthorizedAccess":    {},
+	"AuthorizationError":    {},
+}
+
+//unsupportedRegionErrorCodes are the error codes returned when a region is not enabled for the account
+//the credentials are verified when the provider is created, so an invalid token means the region doesn't accept them
+var unsupportedRegionErrorCodes = map[string]struct{}{
+	"OptInRequired":               {},
+	"UnrecognizedClientException": {},
+	"InvalidClientTokenId":        {},
+}
+
+//iamActions are the IAM actions which are not named after their API operation
+var iamActions = map[string]string{
+	"s3:ListBuckets": "s3:ListAllMyBuckets",
+}
+
+//addErrorMiddleware classifies the errors of the API calls
+func addErrorMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(&errorMiddleware{throttles: retry.DefaultThrottles}, middleware.After)
+}
+
+//errorMiddleware wraps the error of an API call in a types.FetchError, once all its attempts failed
+type errorMiddleware struct {
+	throttles retry.IsErrorThrottles
+}
+
+func (m *errorMiddleware) ID() string {
+	return "CloudgrepError"
+}
+
+func (m *errorMiddleware) HandleInitialize(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+	out, metadata, err := next.HandleInitialize(ctx, in)
+	if err != nil {
+		err = m.classify(awsmiddleware.GetSigningName(ctx), awsmiddleware.GetOperationName(ctx), err)
+	}
+	return out, metadata, err
+}
+
+//classify returns the error of an operation of a service (its signing name) with its classification
+func (m *errorMiddleware) classify(service, operation string, err error) error {
+	fetchErr := &types.FetchError{Kind: model.ErrorKindUnknown, Operation: operation, Action: iamAction(service, operation), Err: err}
+	var apiErr smithy.APIError
+	var dnsErr *net.DNSError
+	var sendErr *smithyhttp.RequestSendError
+	if errors.As(err, &apiErr) {
+		fetchErr.Code = apiErr.ErrorCode()
+	}
+	_, permission := permissionErrorCodes[fetchErr.Code]
+	_, unsupportedRegion := unsupportedRegionErrorCodes[fetchErr.Code]
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		//the fetch was stopped, the error is not caused by the API
+	case permission:
+		fetchErr.Kind = model.ErrorKindPermission
+	case unsupportedRegion:
+		fetchErr.Kind = model.ErrorKindUnsupportedRegion
+	case m.throttles.IsErrorThrottle(err) == aws.TrueTernary:
+		fetchErr.Kind = model.ErrorKindThrottling
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		//the service has no endpoint in the region
+		fetchErr.Kind = model.ErrorKindUnsupportedRegion
+	case errors.As(err, &sendErr):
+		fetchErr.Kind = model.ErrorKindNetwork
+	}
+	return fetchErr
+}
+
+//iamAction returns the IAM action required by an operation of a service, the service is its signing name
+func iamAction(service, operation string) string {
+	if service == "" || operation == "" {
+		return ""
+	}
+	action := service + ":" + operation
+	if mapped, found := iamActions[action]; found {
+		return mapped
+	}
+	return action
+}
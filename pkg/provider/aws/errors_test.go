@@ -0,0 +1,65 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorMiddleware_permission(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AuthorizationError</Code><Message>not authorized to perform: SNS:ListTopics</Message></Error><RequestId>1</RequestId></ErrorResponse>`)
+	}))
+	defer server.Close()
+	p := newTestProvider(server.URL, cfg.RateLimit{})
+
+	err := p.fetchSnsTopic(context.Background(), make(chan model.Resource, 10))
+	require.Error(t, err)
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindPermission, fetchErr.Kind)
+	assert.Equal(t, "AuthorizationError", fetchErr.Code)
+	assert.Equal(t, "ListTopics", fetchErr.Operation)
+	assert.Equal(t, "sns:ListTopics", fetchErr.Action)
+	//the error message is not changed
+	assert.Contains(t, err.Error(), "operation error SNS: ListTopics")
+}
+
+func TestErrorMiddleware_throttling(t *testing.T) {
+	server, _ := newSnsServer(t, 10)
+	p := newTestProvider(server.URL, cfg.RateLimit{RequestsPerSecond: 100, MaxAttempts: 2, MaxBackoff: time.Millisecond})
+
+	err := p.fetchSnsTopic(context.Background(), make(chan model.Resource, 10))
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindThrottling, fetchErr.Kind)
+	assert.Equal(t, "Throttling", fetchErr.Code)
+}
+
+func TestErrorMiddleware_classify(t *testing.T) {
+	m := &errorMiddleware{throttles: retry.DefaultThrottles}
+	classify := func(err error) types.FetchError {
+		return types.ClassifyError(m.classify("ec2", "DescribeInstances", err))
+	}
+	assert.Equal(t, model.ErrorKindUnknown, classify(errors.New("foo")).Kind)
+	assert.Equal(t, model.ErrorKindUnknown, classify(context.Canceled).Kind)
+	assert.Equal(t, model.ErrorKindNetwork, classify(&smithyhttp.RequestSendError{Err: errors.New("connection reset")}).Kind)
+	unsupported := classify(&smithyhttp.RequestSendError{Err: &net.DNSError{Name: "ec2.ap-east-1.amazonaws.com", IsNotFound: true}})
+	assert.Equal(t, model.ErrorKindUnsupportedRegion, unsupported.Kind)
+	assert.Equal(t, "ec2:DescribeInstances", unsupported.Action)
+
+	assert.Equal(t, "s3:ListAllMyBuckets", iamAction("s3", "ListBuckets"))
+	assert.Equal(t, "", iamAction("", "ListBuckets"))
+}
@@ -17,6 +17,10 @@ func (p *Provider) register_iam_manual(mapping map[string]mapper) {
 		IdField:           "Arn",
 		DisplayIDField:    "UserName",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListUsers",
+			"iam:ListUserTags",
+		},
 	}
 	mapping["iam.InstanceProfile"] = mapper{
 		ServiceEndpointID: "iam",
@@ -24,6 +28,10 @@ func (p *Provider) register_iam_manual(mapping map[string]mapper) {
 		IdField:           "Arn",
 		DisplayIDField:    "InstanceProfileName",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListInstanceProfiles",
+			"iam:ListInstanceProfileTags",
+		},
 	}
 	mapping["iam.Role"] = mapper{
 		ServiceEndpointID: "iam",
@@ -31,6 +39,10 @@ func (p *Provider) register_iam_manual(mapping map[string]mapper) {
 		IdField:           "Arn",
 		DisplayIDField:    "RoleName",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListRoles",
+			"iam:ListRoleTags",
+		},
 	}
 }
 
@@ -0,0 +1,262 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	credentialsv1 "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+)
+
+const (
+	//organizationsRegion is the region of the Organizations API, it is a global service
+	organizationsRegion = "us-east-1"
+	//rootName is the name of the root of the organizational unit paths
+	rootName = "Root"
+)
+
+//targetAccount is an account to scan, the credentials of the source config are used if roleArn is not set
+type targetAccount struct {
+	roleArn string
+	//id, name and organizationalUnit are only set for the accounts of an organization
+	id                 string
+	name               string
+	organizationalUnit string
+	//unitIds are the ids of the organizational units containing the account, from the root
+	unitIds []string
+}
+
+func (a targetAccount) String() string {
+	if a.id != "" {
+		return fmt.Sprintf("the account %v (%v)", a.id, a.name)
+	}
+	return fmt.Sprintf("the role %v", a.roleArn)
+}
+
+//validateOrganization returns an error if the organization can't be scanned with the assume role config
+func validateOrganization(c cfg.Provider) error {
+	if !c.Organization.Enabled {
+		return nil
+	}
+	if c.AssumeRole.RoleArnTemplate == "" {
+		return fmt.Errorf("invalid organization: the roleArnTemplate of assumeRole must be set to scan the accounts of the organization")
+	}
+	if len(c.AssumeRole.Accounts) > 0 {
+		return fmt.Errorf("invalid organization: the accounts of assumeRole can't be set when the accounts of the organization are scanned")
+	}
+	return nil
+}
+
+//targetAccounts returns the accounts to scan: the configured roles, then the accounts of the organization
+//the source account is scanned with its own credentials if it is part of the organization accounts
+func targetAccounts(ctx context.Context, c cfg.Provider, sourceConfig aws.Config, sourceAccount string) ([]targetAccount, error) {
+	var accounts []targetAccount
+	for _, roleArn := range roleArns(c.AssumeRole) {
+		accounts = append(accounts, targetAccount{roleArn: roleArn})
+	}
+	if !c.Organization.Enabled {
+		return accounts, nil
+	}
+	client, err := newOrganizationsClient(sourceConfig)
+	if err != nil {
+		return nil, err
+	}
+	members, err := listOrganizationAccounts(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	var selected int
+	for _, member := range members {
+		if !member.matches(c.Organization) {
+			continue
+		}
+		if member.id != sourceAccount {
+			member.roleArn = strings.ReplaceAll(c.AssumeRole.RoleArnTemplate, accountPlaceholder, member.id)
+		}
+		accounts = append(accounts, member)
+		selected++
+	}
+	if selected == 0 {
+		return nil, fmt.Errorf("no active account of the organization matches the organizationalUnits, includeAccounts and excludeAccounts")
+	}
+	return accounts, nil
+}
+
+//matches returns true if the account is selected by the filters of the organization config
+func (a targetAccount) matches(c cfg.Organization) bool {
+	if len(c.OrganizationalUnits) > 0 && !a.inUnits(c.OrganizationalUnits) {
+		return false
+	}
+	if len(c.IncludeAccounts) > 0 && !a.matchesAny(c.IncludeAccounts) {
+		return false
+	}
+	return !a.matchesAny(c.ExcludeAccounts)
+}
+
+//inUnits returns true if one of the units, an id or a path, contains the account
+func (a targetAccount) inUnits(units []string) bool {
+	for _, unit := range units {
+		if a.organizationalUnit == unit || strings.HasPrefix(a.organizationalUnit, unit+"/") {
+			return true
+		}
+		for _, id := range a.unitIds {
+			if id == unit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//matchesAny returns true if the id or the name of the account matches one of the patterns
+func (a targetAccount) matchesAny(patterns []string) bool {
+	for _, pattern := range patterns {
+		for _, value := range []string{a.id, a.name} {
+			if matched, _ := path.Match(pattern, value); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//newOrganizationsClient returns a client of the Organizations API using the credentials and the endpoints of the config
+//the v1 SDK is used as the Organizations client of the v2 SDK requires a newer version of the SDK
+func newOrganizationsClient(config aws.Config) (*organizations.Organizations, error) {
+	configv1 := awsv1.NewConfig().
+		WithRegion(organizationsRegion).
+		WithCredentials(credentialsv1.NewCredentials(credentialsAdapter{provider: config.Credentials}))
+	//use the same transport, it can trust other certificates
+	if client, ok := config.HTTPClient.(*awshttp.BuildableClient); ok {
+		configv1 = configv1.WithHTTPClient(&http.Client{Transport: client.GetTransport(), Timeout: client.GetTimeout()})
+	}
+	if config.EndpointResolverWithOptions != nil {
+		endpoint, err := config.EndpointResolverWithOptions.ResolveEndpoint(organizations.ServiceID, organizationsRegion)
+		var notFound *aws.EndpointNotFoundError
+		if err != nil && !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("can't resolve the endpoint of the Organizations API: %w", err)
+		}
+		if err == nil {
+			configv1 = configv1.WithEndpoint(endpoint.URL)
+		}
+	}
+	sess, err := session.NewSession(configv1)
+	if err != nil {
+		return nil, fmt.Errorf("can't create the Organizations client: %w", err)
+	}
+	return organizations.New(sess), nil
+}
+
+//credentialsAdapter provides the credentials of the v2 SDK to the v1 SDK
+type credentialsAdapter struct {
+	provider aws.CredentialsProvider
+}
+
+func (a credentialsAdapter) Retrieve() (credentialsv1.Value, error) {
+	return a.RetrieveWithContext(context.Background())
+}
+
+func (a credentialsAdapter) RetrieveWithContext(ctx credentialsv1.Context) (credentialsv1.Value, error) {
+	creds, err := a.provider.Retrieve(ctx)
+	if err != nil {
+		return credentialsv1.Value{}, err
+	}
+	return credentialsv1.Value{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		ProviderName:    creds.Source,
+	}, nil
+}
+
+//IsExpired always returns true, the credentials are cached by the v2 provider
+func (a credentialsAdapter) IsExpired() bool {
+	return true
+}
+
+//listOrganizationAccounts returns the active accounts of the organization with their organizational unit
+func listOrganizationAccounts(ctx context.Context, client *organizations.Organizations) ([]targetAccount, error) {
+	var accounts []targetAccount
+	err := client.ListAccountsPagesWithContext(ctx, &organizations.ListAccountsInput{}, func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+		for _, account := range page.Accounts {
+			if awsv1.StringValue(account.Status) != organizations.AccountStatusActive {
+				continue
+			}
+			accounts = append(accounts, targetAccount{id: awsv1.StringValue(account.Id), name: awsv1.StringValue(account.Name)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't list the accounts of the organization: %w", err)
+	}
+	units := organizationalUnits{client: client, units: make(map[string]organizationalUnit)}
+	for i, account := range accounts {
+		unit, err := units.parent(ctx, account.id)
+		if err != nil {
+			return nil, err
+		}
+		accounts[i].organizationalUnit = unit.path
+		accounts[i].unitIds = unit.ids
+	}
+	return accounts, nil
+}
+
+//organizationalUnit is the path of an organizational unit, ex: Root/Production, and the ids of the units of the path
+type organizationalUnit struct {
+	path string
+	ids  []string
+}
+
+//organizationalUnits resolves the organizational units, they are cached as most accounts share them
+type organizationalUnits struct {
+	client *organizations.Organizations
+	units  map[string]organizationalUnit
+}
+
+//parent returns the organizational unit, or the root, containing an account or a unit
+func (o organizationalUnits) parent(ctx context.Context, childId string) (organizationalUnit, error) {
+	output, err := o.client.ListParentsWithContext(ctx, &organizations.ListParentsInput{ChildId: awsv1.String(childId)})
+	if err != nil {
+		return organizationalUnit{}, fmt.Errorf("can't get the parent of %v: %w", childId, err)
+	}
+	if len(output.Parents) == 0 {
+		return organizationalUnit{}, fmt.Errorf("can't get the parent of %v: no parent found", childId)
+	}
+	parent := output.Parents[0]
+	return o.unit(ctx, awsv1.StringValue(parent.Id), awsv1.StringValue(parent.Type))
+}
+
+//unit returns the organizational unit, or the root, of an id
+func (o organizationalUnits) unit(ctx context.Context, id string, parentType string) (organizationalUnit, error) {
+	if unit, found := o.units[id]; found {
+		return unit, nil
+	}
+	var unit organizationalUnit
+	if parentType == organizations.ParentTypeRoot {
+		unit = organizationalUnit{path: rootName, ids: []string{id}}
+	} else {
+		output, err := o.client.DescribeOrganizationalUnitWithContext(ctx, &organizations.DescribeOrganizationalUnitInput{OrganizationalUnitId: awsv1.String(id)})
+		if err != nil {
+			return organizationalUnit{}, fmt.Errorf("can't describe the organizational unit %v: %w", id, err)
+		}
+		parent, err := o.parent(ctx, id)
+		if err != nil {
+			return organizationalUnit{}, err
+		}
+		unit = organizationalUnit{
+			path: parent.path + "/" + awsv1.StringValue(output.OrganizationalUnit.Name),
+			ids:  append(append([]string{}, parent.ids...), id),
+		}
+	}
+	o.units[id] = unit
+	return unit, nil
+}
@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+//organizationServer is a stub of the Organizations API, the other requests are sent to the STS stub
+//the organization is:
+//Root: management (source), legacy (denied), sandbox (suspended)
+//Root/Production: prod-data (111111111111)
+//Root/Production/Web: prod-web (222222222222)
+type organizationServer struct {
+	stsServer
+}
+
+var organizationParents = map[string]string{
+	"source":       "r-root",
+	"denied":       "r-root",
+	"111111111111": "ou-prod",
+	"222222222222": "ou-web",
+	"ou-prod":      "r-root",
+	"ou-web":       "ou-prod",
+}
+
+var organizationUnitNames = map[string]string{
+	"ou-prod": "Production",
+	"ou-web":  "Web",
+}
+
+func (s *organizationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	if target == "" {
+		s.stsServer.ServeHTTP(w, r)
+		return
+	}
+	var input map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	switch strings.TrimPrefix(target, "AWSOrganizationsV20161128.") {
+	case "ListAccounts":
+		//the accounts are returned in two pages
+		if input["NextToken"] == "" {
+			fmt.Fprint(w, `{"Accounts":[{"Id":"source","Name":"management","Status":"ACTIVE"},{"Id":"111111111111","Name":"prod-data","Status":"ACTIVE"},{"Id":"333333333333","Name":"sandbox","Status":"SUSPENDED"}],"NextToken":"page-2"}`)
+			return
+		}
+		fmt.Fprint(w, `{"Accounts":[{"Id":"222222222222","Name":"prod-web","Status":"ACTIVE"},{"Id":"denied","Name":"legacy","Status":"ACTIVE"}]}`)
+	case "ListParents":
+		parent := organizationParents[input["ChildId"]]
+		parentType := "ORGANIZATIONAL_UNIT"
+		if strings.HasPrefix(parent, "r-") {
+			parentType = "ROOT"
+		}
+		fmt.Fprintf(w, `{"Parents":[{"Id":"%v","Type":"%v"}]}`, parent, parentType)
+	case "DescribeOrganizationalUnit":
+		id := input["OrganizationalUnitId"]
+		fmt.Fprintf(w, `{"OrganizationalUnit":{"Id":"%v","Name":"%v"}}`, id, organizationUnitNames[id])
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestValidateOrganization(t *testing.T) {
+	assert.NoError(t, validateOrganization(cfg.Provider{}))
+	assert.NoError(t, validateOrganization(cfg.Provider{Organization: cfg.Organization{Enabled: true}, AssumeRole: cfg.AssumeRole{RoleArnTemplate: "arn:aws:iam::{account}:role/r"}}))
+	assert.ErrorContains(t, validateOrganization(cfg.Provider{Organization: cfg.Organization{Enabled: true}}), "the roleArnTemplate of assumeRole must be set")
+	assert.ErrorContains(t, validateOrganization(cfg.Provider{Organization: cfg.Organization{Enabled: true}, AssumeRole: cfg.AssumeRole{RoleArnTemplate: "arn:aws:iam::{account}:role/r", Accounts: []string{"111111111111"}}}), "the accounts of assumeRole can't be set")
+}
+
+func TestTargetAccounts(t *testing.T) {
+	server := httptest.NewServer(&organizationServer{})
+	defer server.Close()
+
+	ids := func(organization cfg.Organization) []string {
+		accounts, err := targetAccounts(context.Background(), cfg.Provider{
+			AssumeRole:   cfg.AssumeRole{RoleArnTemplate: "arn:aws:iam::{account}:role/cloudgrep-readonly"},
+			Organization: organization,
+		}, newSourceConfig(server.URL), "source")
+		require.NoError(t, err)
+		var ids []string
+		for _, account := range accounts {
+			ids = append(ids, account.id)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"source", "111111111111", "222222222222", "denied"}, ids(cfg.Organization{Enabled: true}))
+	assert.Equal(t, []string{"111111111111", "222222222222"}, ids(cfg.Organization{Enabled: true, OrganizationalUnits: []string{"Root/Production"}}))
+	assert.Equal(t, []string{"222222222222"}, ids(cfg.Organization{Enabled: true, OrganizationalUnits: []string{"ou-web"}}))
+	//Root/Prod is not the path of a unit
+	assert.Equal(t, []string{"222222222222"}, ids(cfg.Organization{Enabled: true, OrganizationalUnits: []string{"Root/Prod", "ou-web"}}))
+	assert.Equal(t, []string{"111111111111"}, ids(cfg.Organization{Enabled: true, IncludeAccounts: []string{"prod-*"}, ExcludeAccounts: []string{"prod-web"}}))
+	assert.Equal(t, []string{"source", "111111111111"}, ids(cfg.Organization{Enabled: true, ExcludeAccounts: []string{"222222222222", "legacy"}}))
+
+	_, err := targetAccounts(context.Background(), cfg.Provider{
+		AssumeRole:   cfg.AssumeRole{RoleArnTemplate: "arn:aws:iam::{account}:role/cloudgrep-readonly"},
+		Organization: cfg.Organization{Enabled: true, IncludeAccounts: []string{"staging-*"}},
+	}, newSourceConfig(server.URL), "source")
+	assert.ErrorContains(t, err, "no active account of the organization matches")
+}
+
+func TestNewProviders_organization(t *testing.T) {
+	stub := &organizationServer{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	providerCfg := cfg.Provider{
+		Cloud:        "aws",
+		Regions:      []string{"us-east-1"},
+		AssumeRole:   cfg.AssumeRole{RoleArnTemplate: "arn:aws:iam::{account}:role/cloudgrep-readonly"},
+		Organization: cfg.Organization{Enabled: true},
+	}
+	providers, err := newProviders(context.Background(), providerCfg, newSourceConfig(server.URL), zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	//the management account uses the source credentials, the account which can't be assumed is skipped
+	var accounts []string
+	for _, p := range providers {
+		p := p.(Provider)
+		accounts = append(accounts, fmt.Sprintf("%v %v %v", p.AccountId(), p.accountName, p.organizationalUnit))
+	}
+	assert.Equal(t, []string{
+		"source management Root",
+		"111111111111 prod-data Root/Production",
+		"222222222222 prod-web Root/Production/Web",
+	}, accounts)
+	assert.Equal(t, []string{
+		"arn:aws:iam::111111111111:role/cloudgrep-readonly",
+		"arn:aws:iam::222222222222:role/cloudgrep-readonly",
+		"arn:aws:iam::denied:role/cloudgrep-readonly",
+	}, stub.assumed)
+
+	//the resources have the name and the organizational unit of the account
+	p := providers[2].(Provider)
+	resource, err := p.converterFor("s3.Bucket").ToResource(context.Background(), s3types.Bucket{Name: aws.String("bucket")}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "222222222222", resource.AccountId)
+	assert.Equal(t, "prod-web", resource.AccountName)
+	assert.Equal(t, "Root/Production/Web", resource.OrganizationalUnit)
+}
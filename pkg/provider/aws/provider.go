@@ -6,6 +6,8 @@
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/hashicorp/go-multierror"
 	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
 	"github.com/juandiegopalomino/cloudgrep/pkg/model"
 	regionutil "github.com/juandiegopalomino/cloudgrep/pkg/provider/aws/regions"
@@ -20,6 +22,9 @@ type Provider struct {
 	config    aws.Config
 	accountId string
 	region    regionutil.Region
+	//accountName and organizationalUnit are set when the account is discovered from an organization
+	accountName        string
+	organizationalUnit string
 }
 
 func (p Provider) String() string {
@@ -30,6 +35,10 @@ func (p Provider) AccountId() string {
 	return p.accountId
 }
 
+func (p Provider) Region() string {
+	return p.region.ID()
+}
+
 func (p Provider) FetchFunctions() map[string]types.FetchFunc {
 	funcMap := make(map[string]types.FetchFunc)
 	for resourceType, mapping := range p.getTypeMapping() {
@@ -55,9 +64,11 @@ func (p *Provider) converterFor(resourceType string) resourceconverter.ResourceC
 	region := p.region.ID()
 	factory := func() model.Resource {
 		return model.Resource{
-			AccountId: p.accountId,
-			Region:    region,
-			Type:      resourceType,
+			AccountId:          p.accountId,
+			AccountName:        p.accountName,
+			OrganizationalUnit: p.organizationalUnit,
+			Region:             region,
+			Type:               resourceType,
 		}
 	}
 
@@ -79,18 +90,98 @@ func (p *Provider) converterFor(resourceType string) resourceconverter.ResourceC
 
 func NewProviders(ctx context.Context, cfg cfg.Provider, logger *zap.Logger) ([]types.Provider, error) {
 	logger.Info("Connecting to AWS account")
-	if cfg.Profile != "" {
-		logger.Sugar().Infof("Using AWS profile '%v'", cfg.Profile)
+	profile := sourceProfile(cfg)
+	if profile != "" {
+		logger.Sugar().Infof("Using AWS profile '%v'", profile)
+	}
+	if err := validateRateLimit(cfg.RateLimit); err != nil {
+		return nil, err
+	}
+	if err := validateAssumeRole(cfg.AssumeRole); err != nil {
+		return nil, err
+	}
+	if err := validateOrganization(cfg); err != nil {
+		return nil, err
 	}
-	defaultConfig, err := config.LoadDefaultConfig(ctx,
-		config.WithSharedConfigProfile(cfg.Profile),
+	if err := validateEndpoints(cfg.Endpoints); err != nil {
+		return nil, err
+	}
+	options, err := endpointOptions(cfg.Endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Endpoints.URL != "" {
+		logger.Sugar().Infof("Using the AWS endpoint %v", cfg.Endpoints.URL)
+	}
+	options = append(options,
+		config.WithSharedConfigProfile(profile),
 		config.WithDefaultsMode(aws.DefaultsModeCrossRegion),
 	)
+	sourceConfig, err := config.LoadDefaultConfig(ctx, options...)
 	if err != nil {
 		return nil, err
 	}
+	return newProviders(ctx, cfg, sourceConfig, logger)
+}
+
+//newProviders returns the providers of the account of the source config, or of each account to scan: the accounts of
+//the roles to assume and the accounts of the organization
+//an account where the role can't be assumed is skipped, an error is returned if no account can be scanned
+func newProviders(ctx context.Context, cfg cfg.Provider, sourceConfig aws.Config, logger *zap.Logger) ([]types.Provider, error) {
+	var sourceAccount string
+	if cfg.Organization.Enabled {
+		logger.Info("Listing the accounts of the AWS organization")
+		identity, err := awsutil.VerifyCreds(ctx, sourceConfig)
+		if err != nil {
+			return nil, err
+		}
+		sourceAccount = *identity.Account
+	}
+	accounts, err := targetAccounts(ctx, cfg, sourceConfig, sourceAccount)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return newAccountProviders(ctx, cfg, sourceConfig, targetAccount{}, logger)
+	}
+	var providers []types.Provider
+	var errors *multierror.Error
+	for _, account := range accounts {
+		accountConfig := sourceConfig
+		if account.roleArn != "" {
+			logger.Sugar().Infof("Assuming the role %v", account.roleArn)
+			accountConfig = assumeRoleConfig(sourceConfig, account.roleArn, cfg.AssumeRole)
+			if _, err := accountConfig.Credentials.Retrieve(ctx); err != nil {
+				err = fmt.Errorf("can't assume the role %v: %w", account.roleArn, err)
+				logger.Sugar().Errorf("Skipping the account: %v", err)
+				errors = multierror.Append(errors, err)
+				continue
+			}
+		}
+		accountProviders, err := newAccountProviders(ctx, cfg, accountConfig, account, logger)
+		if err != nil {
+			err = fmt.Errorf("can't create the providers for %v: %w", account, err)
+			logger.Sugar().Errorf("Skipping the account: %v", err)
+			errors = multierror.Append(errors, err)
+			continue
+		}
+		providers = append(providers, accountProviders...)
+	}
+	if len(providers) == 0 {
+		return nil, errors.ErrorOrNil()
+	}
+	return providers, nil
+}
+
+//newAccountProviders returns a provider for each region of the account of the config
+func newAccountProviders(ctx context.Context, cfg cfg.Provider, accountConfig aws.Config, account targetAccount, logger *zap.Logger) ([]types.Provider, error) {
+	//the API options are copied, the accounts must not share them
+	accountConfig.APIOptions = append([]func(*middleware.Stack) error{}, accountConfig.APIOptions...)
+	//the limiters are shared by the regions of the account
+	configureRateLimit(&accountConfig, cfg.RateLimit)
+	accountConfig.APIOptions = append(accountConfig.APIOptions, addErrorMiddleware)
 
-	identity, err := awsutil.VerifyCreds(ctx, defaultConfig)
+	identity, err := awsutil.VerifyCreds(ctx, accountConfig)
 	if err != nil {
 		if err.Error() == "no AWS credentials found" {
 			err = fmt.Errorf("%w\nPlease set your AWS credentials using this guide: https://docs.aws.amazon.com/sdk-for-java/v1/developer-guide/setup-credentials.html", err)
@@ -98,27 +189,29 @@ func NewProviders(ctx context.Context, cfg cfg.Provider, logger *zap.Logger) ([]
 		return nil, err
 	}
 
-	regions, err := regionutil.SelectRegions(ctx, cfg.Regions, defaultConfig)
+	regions, err := regionutil.SelectRegions(ctx, cfg.Regions, accountConfig)
 	if err != nil {
 		return nil, fmt.Errorf("cannot select regions for AWS provider: %w", err)
 	}
 
-	regionutil.SetConfigRegion(&defaultConfig, regions)
+	regionutil.SetConfigRegion(&accountConfig, regions)
 	logger.Sugar().Infof("Using the following identity: %v", *identity.Arn)
 	logger.Sugar().Infof("Will look in regions %v", regions)
 	var providers []types.Provider
 
 	for _, region := range regions {
-		newConfig := defaultConfig.Copy()
+		newConfig := accountConfig.Copy()
 		if !region.IsGlobal() {
 			newConfig.Region = region.ID()
 		}
 
 		logger.Sugar().Infof("Creating provider for AWS region %v", region)
 		newProvider := Provider{
-			config:    newConfig,
-			accountId: *identity.Account,
-			region:    region,
+			config:             newConfig,
+			accountId:          *identity.Account,
+			region:             region,
+			accountName:        account.name,
+			organizationalUnit: account.organizationalUnit,
 		}
 		providers = append(providers, newProvider)
 	}
@@ -0,0 +1,231 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+)
+
+const (
+	//the default retry settings, a large scan can be throttled many times
+	defaultMaxAttempts = 10
+	defaultMaxBackoff  = 20 * time.Second
+	//the rate of the API calls is divided by this factor when they are throttled
+	throttleFactor = 2
+	//the minimum rate of the API calls when they are throttled
+	minRate = 0.5
+	//the rate is divided once for the concurrent calls throttled within this period
+	throttleCooldown = time.Second
+)
+
+//validateRateLimit returns an error if a value of the rate limit config is invalid
+func validateRateLimit(c cfg.RateLimit) error {
+	if c.RequestsPerSecond < 0 || c.Burst < 0 || c.MaxAttempts < 0 || c.MaxBackoff < 0 {
+		return fmt.Errorf("invalid rateLimit %+v, the values can't be negative", c)
+	}
+	return nil
+}
+
+//configureRateLimit sets the retryer and the rate limiter of the API calls on the AWS config
+func configureRateLimit(awsConfig *aws.Config, c cfg.RateLimit) {
+	limiters := &apiLimiters{cfg: c, limiters: make(map[string]*apiLimiter)}
+	awsConfig.Retryer = newRetryer(c)
+	awsConfig.APIOptions = append(awsConfig.APIOptions, limiters.addMiddleware)
+}
+
+//newRetryer returns the retryer of the clients, with an exponential backoff
+func newRetryer(c cfg.RateLimit) func() aws.Retryer {
+	maxAttempts, maxBackoff := defaultMaxAttempts, defaultMaxBackoff
+	if c.MaxAttempts > 0 {
+		maxAttempts = c.MaxAttempts
+	}
+	if c.MaxBackoff > 0 {
+		maxBackoff = c.MaxBackoff
+	}
+	return func() aws.Retryer {
+		return countingRetryer{retry.NewStandard(func(o *retry.StandardOptions) {
+			o.MaxAttempts = maxAttempts
+			o.MaxBackoff = maxBackoff
+		})}
+	}
+}
+
+//countingRetryer counts the retries in the fetch stats of the context
+type countingRetryer struct {
+	aws.RetryerV2
+}
+
+func (r countingRetryer) GetRetryToken(ctx context.Context, opErr error) (func(error) error, error) {
+	types.GetFetchStats(ctx).AddRetry()
+	return r.RetryerV2.GetRetryToken(ctx, opErr)
+}
+
+//apiLimiters are the limiters of an account, for each service in each region
+type apiLimiters struct {
+	cfg      cfg.RateLimit
+	lock     sync.Mutex
+	limiters map[string]*apiLimiter
+}
+
+func (l *apiLimiters) get(service, region string) *apiLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	key := service + "/" + region
+	limiter, found := l.limiters[key]
+	if !found {
+		limiter = newAPILimiter(l.cfg.RequestsPerSecond, l.cfg.Burst)
+		l.limiters[key] = limiter
+	}
+	return limiter
+}
+
+//addMiddleware limits each attempt of an API call, the retries included
+func (l *apiLimiters) addMiddleware(stack *middleware.Stack) error {
+	return stack.Finalize.Insert(&rateLimitMiddleware{limiters: l, throttles: retry.DefaultThrottles}, "Retry", middleware.After)
+}
+
+//rateLimitMiddleware waits for the limiter of the service before an attempt, the limiter is adapted to the result
+type rateLimitMiddleware struct {
+	limiters  *apiLimiters
+	throttles retry.IsErrorThrottles
+}
+
+func (m *rateLimitMiddleware) ID() string {
+	return "CloudgrepRateLimit"
+}
+
+func (m *rateLimitMiddleware) HandleFinalize(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+	limiter := m.limiters.get(awsmiddleware.GetServiceID(ctx), awsmiddleware.GetRegion(ctx))
+	if err := limiter.wait(ctx); err != nil {
+		return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+	}
+	out, metadata, err := next.HandleFinalize(ctx, in)
+	if err == nil {
+		limiter.succeeded()
+	} else if m.throttles.IsErrorThrottle(err) == aws.TrueTernary {
+		limiter.throttled()
+		types.GetFetchStats(ctx).AddThrottle()
+	}
+	return out, metadata, err
+}
+
+//apiLimiter is a token bucket with an adaptive rate: the rate is divided when an API call is throttled, then increased by 1 per second after successful calls
+//without a configured rate the API calls are not limited until they are throttled, the limit is removed once the rate is back to the one before the throttling
+type apiLimiter struct {
+	lock sync.Mutex
+	//maxRate is the configured rate, 0 if there is no limit
+	maxRate float64
+	burst   int
+	//rate is the current rate, 0 if there is no limit
+	rate   float64
+	tokens float64
+	last   time.Time
+	//restoreRate is the estimated rate when a call was first throttled without a limit
+	restoreRate float64
+	//lastThrottle is the last time the rate was divided
+	lastThrottle time.Time
+	//the calls in the current and in the previous second, they estimate the rate without a limit
+	windowStart     time.Time
+	windowCalls     int
+	lastWindowCalls int
+}
+
+func newAPILimiter(maxRate float64, burst int) *apiLimiter {
+	l := &apiLimiter{maxRate: maxRate, burst: burst, rate: maxRate}
+	l.tokens = l.capacity()
+	return l
+}
+
+//capacity is the maximum number of tokens of the bucket
+func (l *apiLimiter) capacity() float64 {
+	if l.burst > 0 {
+		return float64(l.burst)
+	}
+	return math.Max(1, l.rate)
+}
+
+//refill adds the tokens since the last refill and counts the call window, it must be called with the lock
+func (l *apiLimiter) refill(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.capacity(), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	if now.Sub(l.windowStart) >= time.Second {
+		l.lastWindowCalls = l.windowCalls
+		if now.Sub(l.windowStart) >= 2*time.Second {
+			l.lastWindowCalls = 0
+		}
+		l.windowStart, l.windowCalls = now, 0
+	}
+}
+
+//wait blocks until an API call can be made or the context is done
+func (l *apiLimiter) wait(ctx context.Context) error {
+	for {
+		l.lock.Lock()
+		l.refill(time.Now())
+		if l.rate == 0 || l.tokens >= 1 {
+			if l.rate != 0 {
+				l.tokens--
+			}
+			l.windowCalls++
+			l.lock.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.lock.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+//throttled divides the rate after a throttled call, unless it was divided during the cooldown
+func (l *apiLimiter) throttled() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := time.Now()
+	l.refill(now)
+	l.tokens = 0
+	if now.Sub(l.lastThrottle) < throttleCooldown {
+		return
+	}
+	l.lastThrottle = now
+	rate := l.rate
+	if rate == 0 {
+		//no limit: start from the rate of the calls
+		rate = math.Max(float64(l.lastWindowCalls), float64(l.windowCalls))
+		l.restoreRate = rate
+	}
+	l.rate = math.Max(minRate, rate/throttleFactor)
+}
+
+//succeeded increases the rate after a successful call, the rate of the calls is increased by 1 per second
+func (l *apiLimiter) succeeded() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.rate == 0 {
+		return
+	}
+	l.rate += 1 / l.rate
+	if l.maxRate > 0 {
+		l.rate = math.Min(l.rate, l.maxRate)
+	} else if l.rate >= l.restoreRate {
+		//back to the rate before the throttling, remove the limit
+		l.rate = 0
+	}
+}
+
@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRateLimit(t *testing.T) {
+	assert.NoError(t, validateRateLimit(cfg.RateLimit{}))
+	assert.NoError(t, validateRateLimit(cfg.RateLimit{RequestsPerSecond: 0.5, Burst: 2, MaxAttempts: 3, MaxBackoff: time.Second}))
+	assert.ErrorContains(t, validateRateLimit(cfg.RateLimit{RequestsPerSecond: -1}), "can't be negative")
+	assert.ErrorContains(t, validateRateLimit(cfg.RateLimit{MaxAttempts: -1}), "can't be negative")
+}
+
+func TestAPILimiter_rate(t *testing.T) {
+	ctx := context.Background()
+	limiter := newAPILimiter(20, 1)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		require.NoError(t, limiter.wait(ctx))
+	}
+	//the first call uses the burst, the 5 others wait 50ms each
+	assert.GreaterOrEqual(t, time.Since(start), 240*time.Millisecond)
+
+	//the wait stops with the context
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	limiter.throttled()
+	assert.ErrorIs(t, limiter.wait(canceledCtx), context.Canceled)
+}
+
+func TestAPILimiter_adaptive(t *testing.T) {
+	limiter := newAPILimiter(10, 0)
+	limiter.throttled()
+	assert.Equal(t, 5.0, limiter.rate)
+	//the concurrent calls throttled together divide the rate once
+	limiter.throttled()
+	assert.Equal(t, 5.0, limiter.rate)
+	limiter.succeeded()
+	assert.Equal(t, 5.2, limiter.rate)
+	//the rate is increased up to the configured rate
+	for i := 0; i < 100; i++ {
+		limiter.succeeded()
+	}
+	assert.Equal(t, 10.0, limiter.rate)
+
+	//the rate is divided again after the cooldown
+	limiter.lastThrottle = time.Now().Add(-throttleCooldown)
+	limiter.throttled()
+	assert.Equal(t, 5.0, limiter.rate)
+}
+
+func TestAPILimiter_unlimited(t *testing.T) {
+	ctx := context.Background()
+	limiter := newAPILimiter(0, 0)
+	for i := 0; i < 8; i++ {
+		require.NoError(t, limiter.wait(ctx))
+	}
+	assert.Equal(t, 0.0, limiter.rate)
+
+	//throttled: the rate starts from the rate of the calls
+	limiter.throttled()
+	assert.Equal(t, 4.0, limiter.rate)
+	for i := 0; i < 100 && limiter.rate != 0; i++ {
+		limiter.succeeded()
+	}
+	//back to the rate of the calls before the throttling: no limit
+	assert.Equal(t, 0.0, limiter.rate)
+}
+
+//newSnsServer returns a server for the SNS API, the first calls are throttled
+func newSnsServer(t *testing.T, throttled int32) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if atomic.AddInt32(&calls, 1) <= throttled {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error><RequestId>1</RequestId></ErrorResponse>`)
+			return
+		}
+		switch r.Form.Get("Action") {
+		case "ListTopics":
+			fmt.Fprint(w, `<ListTopicsResponse><ListTopicsResult><Topics><member><TopicArn>arn:aws:sns:us-east-1:123456789012:topic</TopicArn></member></Topics></ListTopicsResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></ListTopicsResponse>`)
+		case "ListTagsForResource":
+			fmt.Fprint(w, `<ListTagsForResourceResponse><ListTagsForResourceResult><Tags><member><Key>team</Key><Value>infra</Value></member></Tags></ListTagsForResourceResult><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></ListTagsForResourceResponse>`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func newTestProvider(url string, rateLimit cfg.RateLimit) Provider {
+	awsConfig := aws.Config{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: url}, nil
+		}),
+	}
+	configureRateLimit(&awsConfig, rateLimit)
+	awsConfig.APIOptions = append(awsConfig.APIOptions, addErrorMiddleware)
+	return Provider{config: awsConfig, accountId: "123456789012"}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	server, calls := newSnsServer(t, 2)
+	p := newTestProvider(server.URL, cfg.RateLimit{RequestsPerSecond: 100, MaxBackoff: time.Millisecond})
+
+	ctx, stats := types.WithFetchStats(context.Background())
+	output := make(chan model.Resource, 10)
+	require.NoError(t, p.fetchSnsTopic(ctx, output))
+	close(output)
+	var resources []model.Resource
+	for r := range output {
+		resources = append(resources, r)
+	}
+	require.Len(t, resources, 1)
+	assert.Equal(t, "arn:aws:sns:us-east-1:123456789012:topic", resources[0].Id)
+
+	//the throttled calls were retried
+	assert.Equal(t, int32(4), atomic.LoadInt32(calls))
+	assert.Equal(t, 2, stats.Throttles())
+	assert.Equal(t, 2, stats.Retries())
+}
+
+func TestRateLimitMiddleware_maxAttempts(t *testing.T) {
+	server, calls := newSnsServer(t, 10)
+	p := newTestProvider(server.URL, cfg.RateLimit{RequestsPerSecond: 100, MaxAttempts: 2, MaxBackoff: time.Millisecond})
+
+	ctx, stats := types.WithFetchStats(context.Background())
+	err := p.fetchSnsTopic(ctx, make(chan model.Resource, 10))
+	assert.ErrorContains(t, err, "Throttling")
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	assert.Equal(t, 2, stats.Throttles())
+	assert.Equal(t, 1, stats.Retries())
+}
@@ -1,10 +1,16 @@
 package aws
 
 import (
+	"fmt"
+	"path"
+
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
+// providerIAMActions are the IAM actions required by the provider itself, to list the regions of the account.
+var providerIAMActions = []string{"ec2:DescribeRegions"}
+
 func (p *Provider) buildTypeMapping() map[string]mapper {
 	mapping := map[string]mapper{}
 
@@ -25,3 +31,38 @@ func SupportedResources() []string {
 	slices.Sort(resources)
 	return resources
 }
+
+// IAMActions returns the sorted IAM actions required to fetch the given resource types, or all the supported types if none is given.
+// A resource type can be a pattern, for example "ec2.*".
+func IAMActions(resourceTypes []string) ([]string, error) {
+	mapping := (&Provider{}).buildTypeMapping()
+	if len(resourceTypes) == 0 {
+		resourceTypes = maps.Keys(mapping)
+	}
+	actions := make(map[string]struct{})
+	for _, action := range providerIAMActions {
+		actions[action] = struct{}{}
+	}
+	for _, pattern := range resourceTypes {
+		found := false
+		for resourceType, mapper := range mapping {
+			match, err := path.Match(pattern, resourceType)
+			if err != nil {
+				return nil, fmt.Errorf("invalid resource type pattern %v: %w", pattern, err)
+			}
+			if !match {
+				continue
+			}
+			found = true
+			for _, action := range mapper.IAMActions {
+				actions[action] = struct{}{}
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown resource type %v", pattern)
+		}
+	}
+	result := maps.Keys(actions)
+	slices.Sort(result)
+	return result, nil
+}
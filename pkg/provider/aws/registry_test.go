@@ -0,0 +1,22 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIAMActions(t *testing.T) {
+	//each type declares the actions it requires
+	for resourceType, mapper := range (&Provider{}).buildTypeMapping() {
+		assert.NotEmpty(t, mapper.IAMActions, resourceType)
+	}
+
+	actions, err := IAMActions([]string{"eks.*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ec2:DescribeRegions", "eks:DescribeCluster", "eks:DescribeNodegroup", "eks:ListClusters", "eks:ListNodegroups"}, actions)
+
+	_, err = IAMActions([]string{"[eks"})
+	assert.ErrorContains(t, err, "invalid resource type pattern")
+}
@@ -18,6 +18,11 @@ func (p *Provider) register_s3(mapping map[string]mapper) {
 		FetchFunc: p.fetch_s3_Bucket,
 		IdField:   "Name",
 		IsGlobal:  true,
+		IAMActions: []string{
+			"s3:ListAllMyBuckets",
+			"s3:GetBucketLocation",
+			"s3:GetBucketTagging",
+		},
 	}
 }
 
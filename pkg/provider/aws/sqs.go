@@ -19,6 +19,11 @@ func (p *Provider) register_sqs(mapping map[string]mapper) {
 		DisplayIDField:  "QueueArn",
 		IsGlobal:        false,
 		UseMapConverter: true,
+		IAMActions: []string{
+			"sqs:ListQueues",
+			"sqs:GetQueueAttributes",
+			"sqs:ListQueueTags",
+		},
 	}
 }
 
@@ -17,6 +17,8 @@ type mapper struct {
 	// Used to detect regions that don't support specific services.
 	// If not set, it is assumed this type's service is supported in all regions.
 	ServiceEndpointID string
+	// IAMActions are the IAM actions required to fetch this type, used to generate the read-only IAM policy.
+	IAMActions []string
 }
 
 func (p *Provider) getTypeMapping() map[string]mapper {
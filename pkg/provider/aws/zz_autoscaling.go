@@ -21,6 +21,9 @@ func (p *Provider) registerAutoscaling(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"autoscaling:DescribeAutoScalingGroups",
+		},
 	}
 }
 
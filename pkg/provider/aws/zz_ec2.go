@@ -21,6 +21,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeAddresses",
+		},
 	}
 	mapping["ec2.CapacityReservation"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -32,6 +35,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeCapacityReservations",
+		},
 	}
 	mapping["ec2.ClientVpnEndpoint"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -43,6 +49,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeClientVpnEndpoints",
+		},
 	}
 	mapping["ec2.Fleet"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -54,6 +63,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeFleets",
+		},
 	}
 	mapping["ec2.FlowLogs"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -65,6 +77,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeFlowLogs",
+		},
 	}
 	mapping["ec2.Image"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -76,6 +91,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeImages",
+		},
 	}
 	mapping["ec2.Instance"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -87,6 +105,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeInstances",
+		},
 	}
 	mapping["ec2.KeyPair"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -98,6 +119,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeKeyPairs",
+		},
 	}
 	mapping["ec2.LaunchTemplate"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -109,6 +133,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeLaunchTemplates",
+		},
 	}
 	mapping["ec2.NatGateway"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -120,6 +147,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeNatGateways",
+		},
 	}
 	mapping["ec2.NetworkAcl"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -131,6 +161,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeNetworkAcls",
+		},
 	}
 	mapping["ec2.NetworkInterface"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -142,6 +175,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeNetworkInterfaces",
+		},
 	}
 	mapping["ec2.ReservedInstance"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -153,6 +189,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeReservedInstances",
+		},
 	}
 	mapping["ec2.RouteTable"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -164,6 +203,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeRouteTables",
+		},
 	}
 	mapping["ec2.SecurityGroup"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -175,6 +217,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeSecurityGroups",
+		},
 	}
 	mapping["ec2.Snapshot"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -186,6 +231,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeSnapshots",
+		},
 	}
 	mapping["ec2.SpotInstanceRequest"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -197,6 +245,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeSpotInstanceRequests",
+		},
 	}
 	mapping["ec2.Subnet"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -208,6 +259,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeSubnets",
+		},
 	}
 	mapping["ec2.Volume"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -219,6 +273,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeVolumes",
+		},
 	}
 	mapping["ec2.Vpc"] = mapper{
 		ServiceEndpointID: "ec2",
@@ -230,6 +287,9 @@ func (p *Provider) registerEc2(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"ec2:DescribeVpcs",
+		},
 	}
 }
 
@@ -17,6 +17,10 @@ func (p *Provider) registerElasticache(mapping map[string]mapper) {
 		FetchFunc:         p.fetchElasticacheCacheCluster,
 		IdField:           "ARN",
 		IsGlobal:          false,
+		IAMActions: []string{
+			"elasticache:DescribeCacheClusters",
+			"elasticache:ListTagsForResource",
+		},
 	}
 }
 
@@ -18,6 +18,10 @@ func (p *Provider) registerElb(mapping map[string]mapper) {
 		IdField:           "LoadBalancerArn",
 		DisplayIDField:    "LoadBalancerName",
 		IsGlobal:          false,
+		IAMActions: []string{
+			"elasticloadbalancing:DescribeLoadBalancers",
+			"elasticloadbalancing:DescribeTags",
+		},
 	}
 }
 
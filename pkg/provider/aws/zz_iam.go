@@ -17,6 +17,10 @@ func (p *Provider) registerIam(mapping map[string]mapper) {
 		FetchFunc:         p.fetchIamOpenIDConnectProvider,
 		IdField:           "Arn",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListOpenIDConnectProviders",
+			"iam:ListOpenIDConnectProviderTags",
+		},
 	}
 	mapping["iam.Policy"] = mapper{
 		ServiceEndpointID: "iam",
@@ -24,18 +28,30 @@ func (p *Provider) registerIam(mapping map[string]mapper) {
 		IdField:           "Arn",
 		DisplayIDField:    "PolicyName",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListPolicies",
+			"iam:ListPolicyTags",
+		},
 	}
 	mapping["iam.SAMLProvider"] = mapper{
 		ServiceEndpointID: "iam",
 		FetchFunc:         p.fetchIamSAMLProvider,
 		IdField:           "Arn",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListSAMLProviders",
+			"iam:ListSAMLProviderTags",
+		},
 	}
 	mapping["iam.VirtualMFADevice"] = mapper{
 		ServiceEndpointID: "iam",
 		FetchFunc:         p.fetchIamVirtualMFADevice,
 		IdField:           "SerialNumber",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"iam:ListVirtualMFADevices",
+			"iam:ListMFADeviceTags",
+		},
 	}
 }
 
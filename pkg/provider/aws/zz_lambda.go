@@ -18,6 +18,10 @@ func (p *Provider) registerLambda(mapping map[string]mapper) {
 		IdField:           "FunctionArn",
 		DisplayIDField:    "FunctionName",
 		IsGlobal:          false,
+		IAMActions: []string{
+			"lambda:ListFunctions",
+			"lambda:GetFunction",
+		},
 	}
 }
 
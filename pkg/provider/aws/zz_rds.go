@@ -21,6 +21,9 @@ func (p *Provider) registerRds(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"rds:DescribeDBClusters",
+		},
 	}
 	mapping["rds.DBClusterSnapshot"] = mapper{
 		ServiceEndpointID: "rds",
@@ -32,6 +35,9 @@ func (p *Provider) registerRds(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"rds:DescribeDBClusterSnapshots",
+		},
 	}
 	mapping["rds.DBInstance"] = mapper{
 		ServiceEndpointID: "rds",
@@ -43,6 +49,9 @@ func (p *Provider) registerRds(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"rds:DescribeDBInstances",
+		},
 	}
 	mapping["rds.DBSnapshot"] = mapper{
 		ServiceEndpointID: "rds",
@@ -54,6 +63,9 @@ func (p *Provider) registerRds(mapping map[string]mapper) {
 			Key:   "Key",
 			Value: "Value",
 		},
+		IAMActions: []string{
+			"rds:DescribeDBSnapshots",
+		},
 	}
 }
 
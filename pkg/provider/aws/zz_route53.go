@@ -17,6 +17,10 @@ func (p *Provider) registerRoute53(mapping map[string]mapper) {
 		FetchFunc:         p.fetchRoute53HealthCheck,
 		IdField:           "Id",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"route53:ListHealthChecks",
+			"route53:ListTagsForResources",
+		},
 	}
 	mapping["route53.HostedZone"] = mapper{
 		ServiceEndpointID: "route53",
@@ -24,6 +28,10 @@ func (p *Provider) registerRoute53(mapping map[string]mapper) {
 		IdField:           "Id",
 		DisplayIDField:    "Name",
 		IsGlobal:          true,
+		IAMActions: []string{
+			"route53:ListHostedZones",
+			"route53:ListTagsForResources",
+		},
 	}
 }
 
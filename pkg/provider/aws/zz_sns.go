@@ -17,6 +17,10 @@ func (p *Provider) registerSns(mapping map[string]mapper) {
 		FetchFunc:         p.fetchSnsTopic,
 		IdField:           "TopicArn",
 		IsGlobal:          false,
+		IAMActions: []string{
+			"sns:ListTopics",
+			"sns:ListTagsForResource",
+		},
 	}
 }
 
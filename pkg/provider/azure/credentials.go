@@ -0,0 +1,99 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	//defaultAuthorityHost is the Azure Active Directory endpoint, it can be overridden with AZURE_AUTHORITY_HOST
+	defaultAuthorityHost = "https://login.microsoftonline.com"
+	//managementResource is the resource of the access tokens, the permissions are granted by the Azure roles
+	managementResource = "https://management.azure.com/"
+)
+
+//azCommand is the Azure CLI command, used when there is no service principal
+var azCommand = "az"
+
+//newTokenSource returns the tokens of the service principal if a client id is set in the config or in AZURE_CLIENT_ID, otherwise the tokens of the Azure CLI
+//each setting of the service principal falls back to its environment variable: AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET
+func newTokenSource(ctx context.Context, config cfg.Azure) (oauth2.TokenSource, error) {
+	tenantId := valueOrEnv(config.TenantId, "AZURE_TENANT_ID")
+	clientId := valueOrEnv(config.ClientId, "AZURE_CLIENT_ID")
+	if clientId == "" {
+		return oauth2.ReuseTokenSource(nil, cliTokenSource{tenantId: tenantId}), nil
+	}
+	clientSecret := valueOrEnv(os.ExpandEnv(config.ClientSecret), "AZURE_CLIENT_SECRET")
+	if tenantId == "" || clientSecret == "" {
+		return nil, fmt.Errorf("the tenant id and the client secret of the Azure service principal %v are required", clientId)
+	}
+	authorityHost := valueOrEnv("", "AZURE_AUTHORITY_HOST")
+	if authorityHost == "" {
+		authorityHost = defaultAuthorityHost
+	}
+	credentials := clientcredentials.Config{
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		TokenURL:     fmt.Sprintf("%v/%v/oauth2/v2.0/token", strings.TrimSuffix(authorityHost, "/"), tenantId),
+		Scopes:       []string{managementResource + ".default"},
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+	return credentials.TokenSource(ctx), nil
+}
+
+func valueOrEnv(value string, key string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(key)
+}
+
+//cliTokenSource returns the access tokens of the user logged in with "az login"
+type cliTokenSource struct {
+	tenantId string
+}
+
+//cliToken is the output of "az account get-access-token"
+type cliToken struct {
+	AccessToken string `json:"accessToken"`
+	//ExpiresOn is the local expiration time, ex: 2022-06-21 10:13:51.000000
+	ExpiresOn string `json:"expiresOn"`
+	//Expiration is the expiration Unix timestamp, only returned by the recent versions of the Azure CLI
+	Expiration int64 `json:"expires_on"`
+}
+
+func (s cliTokenSource) Token() (*oauth2.Token, error) {
+	args := []string{"account", "get-access-token", "--resource", managementResource, "--output", "json"}
+	if s.tenantId != "" {
+		args = append(args, "--tenant", s.tenantId)
+	}
+	output, err := exec.Command(azCommand, args...).Output()
+	if err != nil {
+		var stderr string
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("can't get an access token from the Azure CLI: %w %v\nPlease log in with \"az login\" or set a service principal", err, stderr)
+	}
+	var token cliToken
+	if err := json.Unmarshal(output, &token); err != nil {
+		return nil, fmt.Errorf("can't decode the access token of the Azure CLI: %w", err)
+	}
+	expiry := time.Unix(token.Expiration, 0)
+	if token.Expiration == 0 {
+		expiry, err = time.ParseInLocation("2006-01-02 15:04:05.999999", token.ExpiresOn, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiration of the access token of the Azure CLI: %w", err)
+		}
+	}
+	return &oauth2.Token{AccessToken: token.AccessToken, TokenType: "Bearer", Expiry: expiry}, nil
+}
@@ -0,0 +1,187 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/juandiegopalomino/cloudgrep/pkg/util"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"golang.org/x/oauth2"
+)
+
+//Provider fetches the resources of an Azure subscription with Azure Resource Graph
+type Provider struct {
+	graph        resourceGraph
+	subscription subscription
+	//locations are the locations of the resources to keep, all the locations if empty
+	locations []string
+}
+
+//subscription is a subscription to scan, the name and the management group are only known for the discovered subscriptions
+type subscription struct {
+	id              string
+	name            string
+	managementGroup string
+}
+
+func (p Provider) String() string {
+	return fmt.Sprintf("Azure Provider for subscription %v", p.subscription.id)
+}
+
+func (p Provider) AccountId() string {
+	return p.subscription.id
+}
+
+//Region is empty as a provider fetches the resources of all the locations
+func (p Provider) Region() string {
+	return ""
+}
+
+func (p Provider) FetchFunctions() map[string]types.FetchFunc {
+	funcMap := make(map[string]types.FetchFunc)
+	for _, azureType := range resourceTypes {
+		funcMap["azure."+azureType] = p.fetchFunc("azure."+azureType, azureType)
+	}
+	return funcMap
+}
+
+//fetchFunc returns the function fetching the resources of an Azure type, the pages of the results are sent as they are read
+func (p Provider) fetchFunc(resourceType string, azureType string) types.FetchFunc {
+	return func(ctx context.Context, output chan<- model.Resource) error {
+		request := queryRequest{Subscriptions: []string{p.subscription.id}, Query: resourcesQuery(azureType)}
+		for {
+			page, err := p.graph.query(ctx, request)
+			if err != nil {
+				return fmt.Errorf("failed to fetch %s: %w", resourceType, err)
+			}
+			var resources []model.Resource
+			for _, raw := range page.Data {
+				resource, err := p.toResource(resourceType, raw)
+				if err != nil {
+					return err
+				}
+				if len(p.locations) > 0 && !slices.Contains(p.locations, resource.Region) {
+					continue
+				}
+				resources = append(resources, resource)
+			}
+			if err := util.SendAllFromSlice(ctx, output, resources); err != nil {
+				return err
+			}
+			if page.SkipToken == "" {
+				return nil
+			}
+			request.Options.SkipToken = page.SkipToken
+		}
+	}
+}
+
+//toResource converts a row of the Resources table
+func (p Provider) toResource(resourceType string, raw json.RawMessage) (model.Resource, error) {
+	var result resourceResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return model.Resource{}, fmt.Errorf("can't decode the %v resource: %w", resourceType, err)
+	}
+	if result.Id == "" {
+		return model.Resource{}, fmt.Errorf("could not find the id of a %v resource", resourceType)
+	}
+	resource := model.Resource{
+		Id:                 result.Id,
+		DisplayId:          result.Name,
+		AccountId:          p.subscription.id,
+		AccountName:        p.subscription.name,
+		OrganizationalUnit: p.subscription.managementGroup,
+		Region:             result.Location,
+		Type:               resourceType,
+		RawData:            []byte(raw),
+	}
+	for key, value := range result.Tags {
+		resource.Tags = append(resource.Tags, model.Tag{Key: key, Value: value})
+	}
+	sort.Slice(resource.Tags, func(i, j int) bool {
+		return resource.Tags[i].Key < resource.Tags[j].Key
+	})
+	return resource, nil
+}
+
+//NewProviders returns a provider for each subscription, the credentials are the service principal if set, otherwise the Azure CLI
+func NewProviders(ctx context.Context, config cfg.Provider, logger *zap.Logger) ([]types.Provider, error) {
+	logger.Info("Connecting to Azure")
+	tokenSource, err := newTokenSource(ctx, config.Azure)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tokenSource.Token(); err != nil {
+		return nil, fmt.Errorf("invalid Azure credentials: %w", err)
+	}
+	endpoint := defaultEndpoint
+	if config.Azure.Endpoint != "" {
+		logger.Sugar().Infof("Using the Azure Resource Manager endpoint %v", config.Azure.Endpoint)
+		endpoint = config.Azure.Endpoint
+	}
+	graph := resourceGraph{client: oauth2.NewClient(ctx, tokenSource), endpoint: endpoint}
+	subscriptions, err := targetSubscriptions(ctx, graph, config.Azure.Subscriptions, config.Azure.ManagementGroups)
+	if err != nil {
+		return nil, err
+	}
+	return newProviders(graph, subscriptions, config.Locations(), logger), nil
+}
+
+//targetSubscriptions returns the subscriptions followed by the subscriptions of the management groups
+//all the subscriptions of the credentials are returned if there is no subscription and no management group
+func targetSubscriptions(ctx context.Context, graph resourceGraph, subscriptionIds []string, managementGroups []string) ([]subscription, error) {
+	var subscriptions []subscription
+	for _, id := range subscriptionIds {
+		subscriptions = append(subscriptions, subscription{id: id})
+	}
+	add := func(managementGroup string) error {
+		var groups []string
+		if managementGroup != "" {
+			groups = []string{managementGroup}
+		}
+		results, err := graph.subscriptions(ctx, groups)
+		if err != nil {
+			return fmt.Errorf("can't list the Azure subscriptions: %w", err)
+		}
+		for _, result := range results {
+			if slices.IndexFunc(subscriptions, func(s subscription) bool { return s.id == result.SubscriptionId }) < 0 {
+				subscriptions = append(subscriptions, subscription{id: result.SubscriptionId, name: result.Name, managementGroup: managementGroup})
+			}
+		}
+		return nil
+	}
+	for _, managementGroup := range managementGroups {
+		if err := add(managementGroup); err != nil {
+			return nil, err
+		}
+	}
+	if len(subscriptionIds) == 0 && len(managementGroups) == 0 {
+		if err := add(""); err != nil {
+			return nil, err
+		}
+	}
+	if len(subscriptions) == 0 {
+		return nil, fmt.Errorf("no Azure subscription found, please set the subscriptions or the management groups of the azure provider")
+	}
+	return subscriptions, nil
+}
+
+//newProviders returns a provider for each subscription, using the Resource Graph client
+func newProviders(graph resourceGraph, subscriptions []subscription, locations []string, logger *zap.Logger) []types.Provider {
+	var providers []types.Provider
+	for _, subscription := range subscriptions {
+		logger.Sugar().Infof("Creating provider for Azure subscription %v", subscription.id)
+		providers = append(providers, Provider{
+			graph:        graph,
+			subscription: subscription,
+			locations:    locations,
+		})
+	}
+	return providers
+}
@@ -0,0 +1,277 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+const (
+	subscriptionId = "00000000-0000-0000-0000-000000000001"
+	otherId        = "00000000-0000-0000-0000-000000000002"
+)
+
+//armServer is a stub of Azure Resource Manager and of the Azure AD token endpoint
+//the virtual machines are returned in two pages, the storage accounts can't be listed
+type armServer struct {
+	t *testing.T
+	//authorizations are the Authorization headers of the queries
+	authorizations []string
+	//requests are the queries
+	requests []queryRequest
+}
+
+func (s *armServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/my-tenant/oauth2/v2.0/token" {
+		assert.NoError(s.t, r.ParseForm())
+		assert.Equal(s.t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(s.t, "my-client", r.PostForm.Get("client_id"))
+		assert.Equal(s.t, "my-secret", r.PostForm.Get("client_secret"))
+		assert.Equal(s.t, "https://management.azure.com/.default", r.PostForm.Get("scope"))
+		fmt.Fprint(w, `{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`)
+		return
+	}
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+	if r.Method != http.MethodPost || r.URL.Path != "/providers/Microsoft.ResourceGraph/resources" {
+		s.t.Errorf("unexpected request %v %v", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	assert.Equal(s.t, "2021-03-01", r.URL.Query().Get("api-version"))
+	var request queryRequest
+	assert.NoError(s.t, json.NewDecoder(r.Body).Decode(&request))
+	s.requests = append(s.requests, request)
+	assert.Equal(s.t, 1000, request.Options.Top)
+	assert.Equal(s.t, "objectArray", request.Options.ResultFormat)
+	switch request.Query {
+	case subscriptionsQuery:
+		if len(request.ManagementGroups) > 0 {
+			fmt.Fprintf(w, `{"data":[{"subscriptionId":"%v","name":"Production"}]}`, otherId)
+			return
+		}
+		fmt.Fprintf(w, `{"data":[{"subscriptionId":"%v","name":"Development"},{"subscriptionId":"%v","name":"Production"}]}`, subscriptionId, otherId)
+	case resourcesQuery("Microsoft.Compute/virtualMachines"):
+		if request.Options.SkipToken == "" {
+			fmt.Fprintf(w, `{"totalRecords":2,"count":1,"$skipToken":"page-2","data":[{"id":"/subscriptions/%v/resourceGroups/my-group/providers/Microsoft.Compute/virtualMachines/vm-1","name":"vm-1","type":"microsoft.compute/virtualmachines","location":"westeurope","subscriptionId":"%v","tags":{"team":"infra","env":"prod"},"properties":{"provisioningState":"Succeeded"}}]}`, subscriptionId, subscriptionId)
+			return
+		}
+		assert.Equal(s.t, "page-2", request.Options.SkipToken)
+		fmt.Fprintf(w, `{"totalRecords":2,"count":1,"data":[{"id":"/subscriptions/%v/resourceGroups/my-group/providers/Microsoft.Compute/virtualMachines/vm-2","name":"vm-2","type":"microsoft.compute/virtualmachines","location":"eastus","subscriptionId":"%v","tags":null}]}`, subscriptionId, subscriptionId)
+	case resourcesQuery("Microsoft.Storage/storageAccounts"):
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error":{"code":"AuthorizationFailed","message":"The client does not have authorization to perform action 'Microsoft.ResourceGraph/resources/read'"}}`)
+	default:
+		fmt.Fprint(w, `{"totalRecords":0,"count":0,"data":[]}`)
+	}
+}
+
+func fetchAll(p types.Provider, resourceType string) ([]model.Resource, error) {
+	output := make(chan model.Resource, 10)
+	err := p.FetchFunctions()[resourceType](context.Background(), output)
+	close(output)
+	var resources []model.Resource
+	for r := range output {
+		resources = append(resources, r)
+	}
+	return resources, err
+}
+
+func TestFetchFunctions(t *testing.T) {
+	stub := &armServer{t: t}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	graph := resourceGraph{client: server.Client(), endpoint: server.URL}
+	providers := newProviders(graph, []subscription{{id: subscriptionId, name: "Development", managementGroup: "my-group"}}, nil, zaptest.NewLogger(t))
+	require.Len(t, providers, 1)
+	p := providers[0]
+	assert.Equal(t, subscriptionId, p.AccountId())
+	assert.Equal(t, "", p.Region())
+	assert.Equal(t, len(resourceTypes), len(p.FetchFunctions()))
+
+	resources, err := fetchAll(p, "azure.Microsoft.Compute/virtualMachines")
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	vm := resources[0]
+	assert.Equal(t, "/subscriptions/"+subscriptionId+"/resourceGroups/my-group/providers/Microsoft.Compute/virtualMachines/vm-1", vm.Id)
+	assert.Equal(t, "vm-1", vm.DisplayId)
+	assert.Equal(t, subscriptionId, vm.AccountId)
+	assert.Equal(t, "Development", vm.AccountName)
+	assert.Equal(t, "my-group", vm.OrganizationalUnit)
+	assert.Equal(t, "westeurope", vm.Region)
+	assert.Equal(t, "azure.Microsoft.Compute/virtualMachines", vm.Type)
+	assert.Equal(t, model.Tags{{Key: "env", Value: "prod"}, {Key: "team", Value: "infra"}}, vm.Tags)
+	var rawData map[string]any
+	require.NoError(t, json.Unmarshal(vm.RawData, &rawData))
+	assert.Equal(t, map[string]any{"provisioningState": "Succeeded"}, rawData["properties"])
+	assert.Equal(t, "eastus", resources[1].Region)
+	assert.Empty(t, resources[1].Tags)
+	assert.Equal(t, []string{subscriptionId}, stub.requests[0].Subscriptions)
+
+	//no resource
+	resources, err = fetchAll(p, "azure.Microsoft.Web/sites")
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+
+	//the missing permission is reported
+	_, err = fetchAll(p, "azure.Microsoft.Storage/storageAccounts")
+	require.ErrorContains(t, err, "failed to fetch azure.Microsoft.Storage/storageAccounts: resource graph query returned AuthorizationFailed")
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindPermission, fetchErr.Kind)
+	assert.Equal(t, "AuthorizationFailed", fetchErr.Code)
+	assert.Equal(t, "Microsoft.ResourceGraph/resources/read", fetchErr.Action)
+}
+
+func TestFetchFunctions_locations(t *testing.T) {
+	server := httptest.NewServer(&armServer{t: t})
+	defer server.Close()
+
+	graph := resourceGraph{client: server.Client(), endpoint: server.URL}
+	p := newProviders(graph, []subscription{{id: subscriptionId}}, []string{"eastus", "global"}, zaptest.NewLogger(t))[0]
+	resources, err := fetchAll(p, "azure.Microsoft.Compute/virtualMachines")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "vm-2", resources[0].DisplayId)
+	assert.Empty(t, resources[0].AccountName)
+}
+
+func TestNewFetchError(t *testing.T) {
+	err := newFetchError(http.StatusTooManyRequests, []byte(`{"error":{"code":"RateLimiting","message":"Please provide below info when asking for support"}}`))
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindThrottling, fetchErr.Kind)
+	assert.Equal(t, "RateLimiting", fetchErr.Code)
+
+	//the body is not an Azure Resource Manager error
+	err = newFetchError(http.StatusBadGateway, []byte("bad gateway"))
+	fetchErr = types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindUnknown, fetchErr.Kind)
+	assert.Equal(t, "Bad Gateway", fetchErr.Code)
+	assert.EqualError(t, err, "resource graph query returned Bad Gateway: bad gateway")
+}
+
+func TestTargetSubscriptions(t *testing.T) {
+	stub := &armServer{t: t}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	graph := resourceGraph{client: server.Client(), endpoint: server.URL}
+	ctx := context.Background()
+
+	//all the subscriptions by default
+	subscriptions, err := targetSubscriptions(ctx, graph, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []subscription{{id: subscriptionId, name: "Development"}, {id: otherId, name: "Production"}}, subscriptions)
+	assert.Empty(t, stub.requests[0].ManagementGroups)
+
+	//the subscriptions are not listed
+	subscriptions, err = targetSubscriptions(ctx, graph, []string{subscriptionId}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []subscription{{id: subscriptionId}}, subscriptions)
+	assert.Len(t, stub.requests, 1)
+
+	//the subscriptions of the management group are added
+	subscriptions, err = targetSubscriptions(ctx, graph, []string{subscriptionId, otherId}, []string{"my-group"})
+	require.NoError(t, err)
+	assert.Equal(t, []subscription{{id: subscriptionId}, {id: otherId}}, subscriptions)
+	subscriptions, err = targetSubscriptions(ctx, graph, []string{subscriptionId}, []string{"my-group"})
+	require.NoError(t, err)
+	assert.Equal(t, []subscription{{id: subscriptionId}, {id: otherId, name: "Production", managementGroup: "my-group"}}, subscriptions)
+	assert.Equal(t, []string{"my-group"}, stub.requests[2].ManagementGroups)
+}
+
+func TestNewProviders(t *testing.T) {
+	stub := &armServer{t: t}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	t.Setenv("AZURE_AUTHORITY_HOST", server.URL)
+	t.Setenv("AZURE_TENANT_ID", "")
+	t.Setenv("AZURE_CLIENT_ID", "")
+	t.Setenv("AZURE_CLIENT_SECRET", "")
+	t.Setenv("MY_CLIENT_SECRET", "my-secret")
+
+	//the service principal is used, the subscriptions are listed
+	providerCfg := cfg.Provider{
+		Cloud: "azure",
+		Azure: cfg.Azure{
+			TenantId:     "my-tenant",
+			ClientId:     "my-client",
+			ClientSecret: "${MY_CLIENT_SECRET}",
+			Endpoint:     server.URL,
+		},
+	}
+	providers, err := NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	assert.Equal(t, subscriptionId, providers[0].AccountId())
+	assert.Equal(t, otherId, providers[1].AccountId())
+	resources, err := fetchAll(providers[0], "azure.Microsoft.Compute/virtualMachines")
+	require.NoError(t, err)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, []string{"Bearer test-token", "Bearer test-token", "Bearer test-token"}, stub.authorizations)
+
+	//the service principal is read from the environment
+	t.Setenv("AZURE_TENANT_ID", "my-tenant")
+	t.Setenv("AZURE_CLIENT_ID", "my-client")
+	t.Setenv("AZURE_CLIENT_SECRET", "my-secret")
+	providers, err = NewProviders(context.Background(), cfg.Provider{Cloud: "azure", Azure: cfg.Azure{Subscriptions: []string{otherId}, Endpoint: server.URL}}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, otherId, providers[0].AccountId())
+
+	//the secret is required
+	t.Setenv("AZURE_CLIENT_SECRET", "")
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "azure", Azure: cfg.Azure{Endpoint: server.URL}}, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "the tenant id and the client secret of the Azure service principal my-client are required")
+}
+
+//writeAzCommand writes a script printing the output of "az account get-access-token"
+func writeAzCommand(t *testing.T, script string) {
+	command := filepath.Join(t.TempDir(), "az")
+	require.NoError(t, os.WriteFile(command, []byte("#!/bin/sh\n"+script), 0700))
+	previous := azCommand
+	azCommand = command
+	t.Cleanup(func() { azCommand = previous })
+}
+
+func TestNewProviders_cli(t *testing.T) {
+	stub := &armServer{t: t}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	t.Setenv("AZURE_TENANT_ID", "")
+	t.Setenv("AZURE_CLIENT_ID", "")
+
+	writeAzCommand(t, `echo '{"accessToken":"cli-token","expiresOn":"2099-01-01 10:00:00.000000","subscription":"`+subscriptionId+`","tokenType":"Bearer"}'`)
+	providerCfg := cfg.Provider{Cloud: "azure", Azure: cfg.Azure{Subscriptions: []string{subscriptionId}, Endpoint: server.URL}}
+	providers, err := NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	_, err = fetchAll(providers[0], "azure.Microsoft.Web/sites")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bearer cli-token"}, stub.authorizations)
+
+	//the Unix expiration of the recent versions is used
+	token, err := cliTokenSource{}.Token()
+	require.NoError(t, err)
+	assert.Equal(t, 2099, token.Expiry.Year())
+	writeAzCommand(t, `echo '{"accessToken":"cli-token","expiresOn":"invalid","expires_on":4070908800}'`)
+	token, err = cliTokenSource{}.Token()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4070908800), token.Expiry.Unix())
+
+	//the user is not logged in
+	writeAzCommand(t, `echo "Please run 'az login' to setup account." >&2; exit 1`)
+	_, err = NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "invalid Azure credentials: can't get an access token from the Azure CLI: exit status 1 Please run 'az login' to setup account.")
+}
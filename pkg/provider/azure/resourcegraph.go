@@ -0,0 +1,162 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+)
+
+const (
+	//defaultEndpoint is the endpoint of Azure Resource Manager
+	defaultEndpoint = "https://management.azure.com"
+	//apiVersion is the version of the Resource Graph API
+	apiVersion = "2021-03-01"
+	//readPermission is the permission required to query the resources, the resources are also filtered by the read permission on each of them
+	readPermission = "Microsoft.ResourceGraph/resources/read"
+	//pageSize is the maximum number of resources returned per page, the maximum allowed by the API
+	pageSize = 1000
+	//subscriptionsQuery lists the subscriptions in the scope of the query
+	subscriptionsQuery = "ResourceContainers | where type =~ 'microsoft.resources/subscriptions' | project subscriptionId, name | order by subscriptionId asc"
+)
+
+//resourceGraph queries Azure Resource Graph
+type resourceGraph struct {
+	client   *http.Client
+	endpoint string
+}
+
+//queryRequest is the body of a Resource Graph query, the query is run in the subscriptions or the management groups
+type queryRequest struct {
+	Subscriptions    []string     `json:"subscriptions,omitempty"`
+	ManagementGroups []string     `json:"managementGroups,omitempty"`
+	Query            string       `json:"query"`
+	Options          queryOptions `json:"options"`
+}
+
+type queryOptions struct {
+	Top          int    `json:"$top"`
+	SkipToken    string `json:"$skipToken,omitempty"`
+	ResultFormat string `json:"resultFormat"`
+}
+
+//queryResponse is a page of the response of a Resource Graph query
+type queryResponse struct {
+	//Data is kept as is to be stored as the raw data of the resources
+	Data      []json.RawMessage `json:"data"`
+	SkipToken string            `json:"$skipToken"`
+}
+
+//resourceResult are the fields of a row of the Resources table used to create a resource
+type resourceResult struct {
+	//Id is the resource id, ex: /subscriptions/xxx/resourceGroups/my-group/providers/Microsoft.Compute/virtualMachines/vm-1
+	Id       string            `json:"id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Location string            `json:"location"`
+	Tags     map[string]string `json:"tags"`
+}
+
+//subscriptionResult is a row of the subscriptions query
+type subscriptionResult struct {
+	SubscriptionId string `json:"subscriptionId"`
+	Name           string `json:"name"`
+}
+
+//apiError is the error returned by Azure Resource Manager
+type apiError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+//resourcesQuery returns the query of the resources of an Azure type
+func resourcesQuery(azureType string) string {
+	return fmt.Sprintf("Resources | where type =~ '%v' | order by id asc", strings.ToLower(azureType))
+}
+
+//query returns a page of the results of a query
+func (g resourceGraph) query(ctx context.Context, request queryRequest) (queryResponse, error) {
+	request.Options.Top = pageSize
+	request.Options.ResultFormat = "objectArray"
+	body, err := json.Marshal(request)
+	if err != nil {
+		return queryResponse{}, err
+	}
+	queryUrl := fmt.Sprintf("%v/providers/Microsoft.ResourceGraph/resources?api-version=%v", g.endpoint, apiVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, queryUrl, bytes.NewReader(body))
+	if err != nil {
+		return queryResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return queryResponse{}, fmt.Errorf("can't query the resources: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return queryResponse{}, fmt.Errorf("can't read the resources: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return queryResponse{}, newFetchError(resp.StatusCode, body)
+	}
+	var page queryResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		return queryResponse{}, fmt.Errorf("can't decode the resources: %w", err)
+	}
+	return page, nil
+}
+
+//subscriptions returns the subscriptions in the management groups, all the subscriptions of the credentials if there is no management group
+func (g resourceGraph) subscriptions(ctx context.Context, managementGroups []string) ([]subscriptionResult, error) {
+	request := queryRequest{ManagementGroups: managementGroups, Query: subscriptionsQuery}
+	var subscriptions []subscriptionResult
+	for {
+		page, err := g.query(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range page.Data {
+			var subscription subscriptionResult
+			if err := json.Unmarshal(raw, &subscription); err != nil {
+				return nil, fmt.Errorf("can't decode the subscription: %w", err)
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+		if page.SkipToken == "" {
+			return subscriptions, nil
+		}
+		request.Options.SkipToken = page.SkipToken
+	}
+}
+
+//newFetchError returns the classified error of a failed query
+func newFetchError(statusCode int, body []byte) error {
+	var apiErr apiError
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Error.Code == "" {
+		apiErr.Error.Code = http.StatusText(statusCode)
+		apiErr.Error.Message = string(body)
+	}
+	fetchErr := &types.FetchError{
+		Kind:      model.ErrorKindUnknown,
+		Code:      apiErr.Error.Code,
+		Operation: "Resources",
+		Action:    readPermission,
+		Err:       fmt.Errorf("resource graph query returned %v: %v", apiErr.Error.Code, apiErr.Error.Message),
+	}
+	switch statusCode {
+	case http.StatusForbidden:
+		fetchErr.Kind = model.ErrorKindPermission
+	case http.StatusTooManyRequests:
+		fetchErr.Kind = model.ErrorKindThrottling
+	}
+	return fetchErr
+}
@@ -0,0 +1,23 @@
+package azure
+
+//resourceTypes are the supported Azure resource types, the resource type in cloudgrep is "azure." followed by the Azure type
+//see https://learn.microsoft.com/en-us/azure/governance/resource-graph/reference/supported-tables-resources
+var resourceTypes = []string{
+	"Microsoft.Compute/disks",
+	"Microsoft.Compute/snapshots",
+	"Microsoft.Compute/virtualMachineScaleSets",
+	"Microsoft.Compute/virtualMachines",
+	"Microsoft.ContainerRegistry/registries",
+	"Microsoft.ContainerService/managedClusters",
+	"Microsoft.DocumentDB/databaseAccounts",
+	"Microsoft.KeyVault/vaults",
+	"Microsoft.Network/loadBalancers",
+	"Microsoft.Network/networkInterfaces",
+	"Microsoft.Network/networkSecurityGroups",
+	"Microsoft.Network/publicIPAddresses",
+	"Microsoft.Network/virtualNetworks",
+	"Microsoft.Sql/servers",
+	"Microsoft.Sql/servers/databases",
+	"Microsoft.Storage/storageAccounts",
+	"Microsoft.Web/sites",
+}
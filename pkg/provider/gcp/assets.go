@@ -0,0 +1,103 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+)
+
+const (
+	//defaultEndpoint is the endpoint of the Cloud Asset API
+	defaultEndpoint = "https://cloudasset.googleapis.com"
+	//searchPermission is the IAM permission required to search the resources
+	searchPermission = "cloudasset.assets.searchAllResources"
+	//pageSize is the maximum number of resources returned per page, the maximum allowed by the API
+	pageSize = "500"
+)
+
+//searchResponse is a page of the response of searchAllResources
+type searchResponse struct {
+	//Results are kept as is to be stored as the raw data of the resources
+	Results       []json.RawMessage `json:"results"`
+	NextPageToken string            `json:"nextPageToken"`
+}
+
+//resourceResult are the fields of a result of searchAllResources used to create a resource
+type resourceResult struct {
+	//Name is the full resource name, ex: //compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/vm-1
+	Name        string            `json:"name"`
+	AssetType   string            `json:"assetType"`
+	DisplayName string            `json:"displayName"`
+	Location    string            `json:"location"`
+	Labels      map[string]string `json:"labels"`
+}
+
+//apiError is the error returned by the Google APIs
+type apiError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+//searchAllResources returns a page of the resources of an asset type in the project
+func (p Provider) searchAllResources(ctx context.Context, assetType string, pageToken string) (searchResponse, error) {
+	query := url.Values{}
+	query.Set("assetTypes", assetType)
+	query.Set("pageSize", pageSize)
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	searchUrl := fmt.Sprintf("%v/v1/projects/%v:searchAllResources?%v", p.endpoint, url.PathEscape(p.project), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, searchUrl, nil)
+	if err != nil {
+		return searchResponse{}, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return searchResponse{}, fmt.Errorf("can't search the resources: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return searchResponse{}, fmt.Errorf("can't read the resources: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return searchResponse{}, newFetchError(resp.StatusCode, body)
+	}
+	var page searchResponse
+	if err := json.Unmarshal(body, &page); err != nil {
+		return searchResponse{}, fmt.Errorf("can't decode the resources: %w", err)
+	}
+	return page, nil
+}
+
+//newFetchError returns the classified error of a failed API call
+func newFetchError(statusCode int, body []byte) error {
+	var apiErr apiError
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Error.Status == "" {
+		apiErr.Error.Status = http.StatusText(statusCode)
+		apiErr.Error.Message = string(body)
+	}
+	fetchErr := &types.FetchError{
+		Kind:      model.ErrorKindUnknown,
+		Code:      apiErr.Error.Status,
+		Operation: "SearchAllResources",
+		Action:    searchPermission,
+		Err:       fmt.Errorf("searchAllResources returned %v: %v", apiErr.Error.Status, apiErr.Error.Message),
+	}
+	switch statusCode {
+	case http.StatusForbidden:
+		fetchErr.Kind = model.ErrorKindPermission
+	case http.StatusTooManyRequests:
+		fetchErr.Kind = model.ErrorKindThrottling
+	}
+	return fetchErr
+}
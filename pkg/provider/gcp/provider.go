@@ -0,0 +1,169 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/juandiegopalomino/cloudgrep/pkg/util"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+//cloudPlatformScope is the OAuth scope of the credentials, the permissions are granted by the IAM roles
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+//Provider fetches the resources of a GCP project with the Cloud Asset API
+type Provider struct {
+	client   *http.Client
+	endpoint string
+	project  string
+	//locations are the locations of the resources to keep, all the locations if empty
+	locations []string
+}
+
+func (p Provider) String() string {
+	return fmt.Sprintf("GCP Provider for project %v", p.project)
+}
+
+func (p Provider) AccountId() string {
+	return p.project
+}
+
+//Region is empty as a provider fetches the resources of all the locations
+func (p Provider) Region() string {
+	return ""
+}
+
+func (p Provider) FetchFunctions() map[string]types.FetchFunc {
+	funcMap := make(map[string]types.FetchFunc)
+	for resourceType, assetType := range assetTypes {
+		funcMap[resourceType] = p.fetchFunc(resourceType, assetType)
+	}
+	return funcMap
+}
+
+//fetchFunc returns the function fetching the resources of an asset type, the pages of the results are sent as they are read
+func (p Provider) fetchFunc(resourceType string, assetType string) types.FetchFunc {
+	return func(ctx context.Context, output chan<- model.Resource) error {
+		var pageToken string
+		for {
+			page, err := p.searchAllResources(ctx, assetType, pageToken)
+			if err != nil {
+				return fmt.Errorf("failed to fetch %s: %w", resourceType, err)
+			}
+			var resources []model.Resource
+			for _, raw := range page.Results {
+				resource, err := p.toResource(resourceType, raw)
+				if err != nil {
+					return err
+				}
+				if len(p.locations) > 0 && !slices.Contains(p.locations, resource.Region) {
+					continue
+				}
+				resources = append(resources, resource)
+			}
+			if err := util.SendAllFromSlice(ctx, output, resources); err != nil {
+				return err
+			}
+			if page.NextPageToken == "" {
+				return nil
+			}
+			pageToken = page.NextPageToken
+		}
+	}
+}
+
+//toResource converts a result of searchAllResources, the labels are the tags
+func (p Provider) toResource(resourceType string, raw json.RawMessage) (model.Resource, error) {
+	var result resourceResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return model.Resource{}, fmt.Errorf("can't decode the %v resource: %w", resourceType, err)
+	}
+	if result.Name == "" {
+		return model.Resource{}, fmt.Errorf("could not find the name of a %v resource", resourceType)
+	}
+	resource := model.Resource{
+		Id:        result.Name,
+		DisplayId: result.DisplayName,
+		AccountId: p.project,
+		Region:    result.Location,
+		Type:      resourceType,
+		RawData:   []byte(raw),
+	}
+	for key, value := range result.Labels {
+		resource.Tags = append(resource.Tags, model.Tag{Key: key, Value: value})
+	}
+	sort.Slice(resource.Tags, func(i, j int) bool {
+		return resource.Tags[i].Key < resource.Tags[j].Key
+	})
+	return resource, nil
+}
+
+//NewProviders returns a provider for each project, the credentials are loaded from the credentials file or the application default credentials
+func NewProviders(ctx context.Context, config cfg.Provider, logger *zap.Logger) ([]types.Provider, error) {
+	logger.Info("Connecting to GCP")
+	credentials, err := loadCredentials(ctx, config.GCP.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := credentials.TokenSource.Token(); err != nil {
+		return nil, fmt.Errorf("invalid GCP credentials: %w", err)
+	}
+	projects := config.GCP.Projects
+	if len(projects) == 0 {
+		if credentials.ProjectID == "" {
+			return nil, fmt.Errorf("no GCP project found, please set the projects of the gcp provider")
+		}
+		projects = []string{credentials.ProjectID}
+	}
+	endpoint := defaultEndpoint
+	if config.GCP.Endpoint != "" {
+		logger.Sugar().Infof("Using the Cloud Asset endpoint %v", config.GCP.Endpoint)
+		endpoint = config.GCP.Endpoint
+	}
+	return newProviders(oauth2.NewClient(ctx, credentials.TokenSource), endpoint, projects, config.Locations(), logger), nil
+}
+
+//loadCredentials returns the credentials of the service account key file if set, otherwise the application default credentials
+func loadCredentials(ctx context.Context, credentialsFile string) (*google.Credentials, error) {
+	if credentialsFile == "" {
+		credentials, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("no GCP credentials found: %w\nPlease set your application default credentials using this guide: https://cloud.google.com/docs/authentication/provide-credentials-adc", err)
+		}
+		return credentials, nil
+	}
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the GCP credentials file: %w", err)
+	}
+	credentials, err := google.CredentialsFromJSON(ctx, data, cloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GCP credentials file %v: %w", credentialsFile, err)
+	}
+	return credentials, nil
+}
+
+//newProviders returns a provider for each project, using the client and the endpoint of the Cloud Asset API
+func newProviders(client *http.Client, endpoint string, projects []string, locations []string, logger *zap.Logger) []types.Provider {
+	var providers []types.Provider
+	for _, project := range projects {
+		logger.Sugar().Infof("Creating provider for GCP project %v", project)
+		providers = append(providers, Provider{
+			client:    client,
+			endpoint:  endpoint,
+			project:   project,
+			locations: locations,
+		})
+	}
+	return providers
+}
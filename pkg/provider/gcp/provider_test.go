@@ -0,0 +1,187 @@
+package gcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+//assetServer is a stub of the Cloud Asset API and of the OAuth token endpoint
+//the instances are returned in two pages, the buckets can't be listed
+type assetServer struct {
+	t *testing.T
+	//authorizations are the Authorization headers of the search requests
+	authorizations []string
+}
+
+func (s *assetServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/token" {
+		fmt.Fprint(w, `{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`)
+		return
+	}
+	s.authorizations = append(s.authorizations, r.Header.Get("Authorization"))
+	if r.URL.Path != "/v1/projects/my-project:searchAllResources" {
+		s.t.Errorf("unexpected request %v", r.URL)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	assert.Equal(s.t, "500", r.URL.Query().Get("pageSize"))
+	switch assetType, pageToken := r.URL.Query().Get("assetTypes"), r.URL.Query().Get("pageToken"); {
+	case assetType == "compute.googleapis.com/Instance" && pageToken == "":
+		fmt.Fprint(w, `{"results":[{"name":"//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/vm-1","assetType":"compute.googleapis.com/Instance","project":"projects/123456","displayName":"vm-1","location":"us-central1-a","labels":{"team":"infra","env":"prod"},"state":"RUNNING"}],"nextPageToken":"page-2"}`)
+	case assetType == "compute.googleapis.com/Instance" && pageToken == "page-2":
+		fmt.Fprint(w, `{"results":[{"name":"//compute.googleapis.com/projects/my-project/zones/europe-west1-b/instances/vm-2","assetType":"compute.googleapis.com/Instance","project":"projects/123456","displayName":"vm-2","location":"europe-west1-b"}]}`)
+	case assetType == "storage.googleapis.com/Bucket":
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error":{"code":403,"message":"The caller does not have permission","status":"PERMISSION_DENIED"}}`)
+	default:
+		fmt.Fprint(w, `{}`)
+	}
+}
+
+func fetchAll(p types.Provider, resourceType string) ([]model.Resource, error) {
+	output := make(chan model.Resource, 10)
+	err := p.FetchFunctions()[resourceType](context.Background(), output)
+	close(output)
+	var resources []model.Resource
+	for r := range output {
+		resources = append(resources, r)
+	}
+	return resources, err
+}
+
+func TestFetchFunctions(t *testing.T) {
+	server := httptest.NewServer(&assetServer{t: t})
+	defer server.Close()
+
+	providers := newProviders(server.Client(), server.URL, []string{"my-project"}, nil, zaptest.NewLogger(t))
+	require.Len(t, providers, 1)
+	p := providers[0]
+	assert.Equal(t, "my-project", p.AccountId())
+	assert.Equal(t, "", p.Region())
+	assert.Equal(t, len(assetTypes), len(p.FetchFunctions()))
+
+	resources, err := fetchAll(p, "compute.Instance")
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	vm := resources[0]
+	assert.Equal(t, "//compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/vm-1", vm.Id)
+	assert.Equal(t, "vm-1", vm.DisplayId)
+	assert.Equal(t, "my-project", vm.AccountId)
+	assert.Equal(t, "us-central1-a", vm.Region)
+	assert.Equal(t, "compute.Instance", vm.Type)
+	assert.Equal(t, model.Tags{{Key: "env", Value: "prod"}, {Key: "team", Value: "infra"}}, vm.Tags)
+	var rawData map[string]any
+	require.NoError(t, json.Unmarshal(vm.RawData, &rawData))
+	assert.Equal(t, "RUNNING", rawData["state"])
+	assert.Equal(t, "europe-west1-b", resources[1].Region)
+	assert.Empty(t, resources[1].Tags)
+
+	//no resource
+	resources, err = fetchAll(p, "pubsub.Topic")
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+
+	//the missing permission is reported
+	_, err = fetchAll(p, "storage.Bucket")
+	require.ErrorContains(t, err, "failed to fetch storage.Bucket: searchAllResources returned PERMISSION_DENIED: The caller does not have permission")
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindPermission, fetchErr.Kind)
+	assert.Equal(t, "PERMISSION_DENIED", fetchErr.Code)
+	assert.Equal(t, "cloudasset.assets.searchAllResources", fetchErr.Action)
+}
+
+func TestFetchFunctions_locations(t *testing.T) {
+	server := httptest.NewServer(&assetServer{t: t})
+	defer server.Close()
+
+	p := newProviders(server.Client(), server.URL, []string{"my-project"}, []string{"europe-west1-b", "global"}, zaptest.NewLogger(t))[0]
+	resources, err := fetchAll(p, "compute.Instance")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "vm-2", resources[0].DisplayId)
+}
+
+func TestNewFetchError(t *testing.T) {
+	err := newFetchError(http.StatusTooManyRequests, []byte(`{"error":{"code":429,"message":"Quota exceeded","status":"RESOURCE_EXHAUSTED"}}`))
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindThrottling, fetchErr.Kind)
+	assert.Equal(t, "RESOURCE_EXHAUSTED", fetchErr.Code)
+
+	//the body is not a Google API error
+	err = newFetchError(http.StatusBadGateway, []byte("bad gateway"))
+	fetchErr = types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindUnknown, fetchErr.Kind)
+	assert.Equal(t, "Bad Gateway", fetchErr.Code)
+	assert.EqualError(t, err, "searchAllResources returned Bad Gateway: bad gateway")
+}
+
+//writeCredentialsFile writes a service account key file using the token endpoint of the server
+func writeCredentialsFile(t *testing.T, tokenURL string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	credentials, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "my-project",
+		"private_key_id": "1",
+		"private_key":    string(keyPEM),
+		"client_email":   "cloudgrep@my-project.iam.gserviceaccount.com",
+		"client_id":      "1",
+		"token_uri":      tokenURL,
+	})
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "credentials.json")
+	require.NoError(t, os.WriteFile(file, credentials, 0600))
+	return file
+}
+
+func TestNewProviders(t *testing.T) {
+	stub := &assetServer{t: t}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	//the project of the credentials is used by default
+	providerCfg := cfg.Provider{
+		Cloud: "gcp",
+		GCP: cfg.GCP{
+			CredentialsFile: writeCredentialsFile(t, server.URL+"/token"),
+			Endpoint:        server.URL,
+		},
+	}
+	providers, err := NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, "my-project", providers[0].AccountId())
+	resources, err := fetchAll(providers[0], "compute.Instance")
+	require.NoError(t, err)
+	assert.Len(t, resources, 2)
+	assert.Equal(t, []string{"Bearer test-token", "Bearer test-token"}, stub.authorizations)
+
+	providerCfg.GCP.Projects = []string{"my-project", "other-project"}
+	providers, err = NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	assert.Equal(t, "other-project", providers[1].AccountId())
+
+	providerCfg.GCP.CredentialsFile = filepath.Join(t.TempDir(), "missing.json")
+	_, err = NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "can't read the GCP credentials file")
+}
@@ -0,0 +1,23 @@
+package gcp
+
+//assetTypes maps the supported resource types to their Cloud Asset type
+//see https://cloud.google.com/asset-inventory/docs/supported-asset-types
+var assetTypes = map[string]string{
+	"bigquery.Dataset":             "bigquery.googleapis.com/Dataset",
+	"bigquery.Table":               "bigquery.googleapis.com/Table",
+	"cloudfunctions.CloudFunction": "cloudfunctions.googleapis.com/CloudFunction",
+	"compute.Address":              "compute.googleapis.com/Address",
+	"compute.Disk":                 "compute.googleapis.com/Disk",
+	"compute.Firewall":             "compute.googleapis.com/Firewall",
+	"compute.Instance":             "compute.googleapis.com/Instance",
+	"compute.Network":              "compute.googleapis.com/Network",
+	"compute.Snapshot":             "compute.googleapis.com/Snapshot",
+	"compute.Subnetwork":           "compute.googleapis.com/Subnetwork",
+	"container.Cluster":            "container.googleapis.com/Cluster",
+	"iam.ServiceAccount":           "iam.googleapis.com/ServiceAccount",
+	"pubsub.Subscription":          "pubsub.googleapis.com/Subscription",
+	"pubsub.Topic":                 "pubsub.googleapis.com/Topic",
+	"run.Service":                  "run.googleapis.com/Service",
+	"sqladmin.Instance":            "sqladmin.googleapis.com/Instance",
+	"storage.Bucket":               "storage.googleapis.com/Bucket",
+}
@@ -0,0 +1,197 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+)
+
+//pageSize is the maximum number of objects returned per page
+const pageSize = "500"
+
+//defaultKinds are the kinds listed if the kinds are not set in the config
+var defaultKinds = []string{
+	"apps/v1/Deployment",
+	"apps/v1/StatefulSet",
+	"v1/Service",
+	"networking.k8s.io/v1/Ingress",
+	"v1/PersistentVolumeClaim",
+	"v1/Node",
+}
+
+//kind is a group, version and kind (GVK), the group is empty for the core kinds
+type kind struct {
+	group   string
+	version string
+	kind    string
+}
+
+//parseKind parses a GVK, ex: "apps/v1/Deployment", the group is omitted for the core kinds, ex: "v1/Service"
+func parseKind(gvk string) (kind, error) {
+	parts := strings.Split(gvk, "/")
+	for _, part := range parts {
+		if part == "" {
+			return kind{}, fmt.Errorf("invalid kind '%v', the format is group/version/Kind, ex: apps/v1/Deployment", gvk)
+		}
+	}
+	switch len(parts) {
+	case 2:
+		return kind{version: parts[0], kind: parts[1]}, nil
+	case 3:
+		return kind{group: parts[0], version: parts[1], kind: parts[2]}, nil
+	}
+	return kind{}, fmt.Errorf("invalid kind '%v', the format is group/version/Kind, ex: apps/v1/Deployment", gvk)
+}
+
+//resourceType is the type of the resources of the kind, ex: kubernetes.Service, kubernetes.apps/Deployment
+//the version is not part of the type so the resources don't change type when the kind is listed with another version
+func (k kind) resourceType() string {
+	if k.group == "" {
+		return "kubernetes." + k.kind
+	}
+	return fmt.Sprintf("kubernetes.%v/%v", k.group, k.kind)
+}
+
+//path is the API path of the group version
+func (k kind) path() string {
+	if k.group == "" {
+		return "/api/" + k.version
+	}
+	return fmt.Sprintf("/apis/%v/%v", k.group, k.version)
+}
+
+//apiResource is the API resource of a kind, ex: deployments
+type apiResource struct {
+	//Name is the plural name of the kind, ex: deployments
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+//qualifiedName is the name of the API resource used in the RBAC rules and by kubectl, ex: deployments.apps
+func (r apiResource) qualifiedName(k kind) string {
+	if k.group == "" {
+		return r.Name
+	}
+	return r.Name + "." + k.group
+}
+
+//objectList is a page of objects
+type objectList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	//Items are kept as is to be stored as the raw data of the resources
+	Items []json.RawMessage `json:"items"`
+}
+
+//objectMetadata are the fields of an object used to create a resource
+type objectMetadata struct {
+	Metadata struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		Labels    map[string]string `json:"labels"`
+	} `json:"metadata"`
+}
+
+//status is the error returned by the API server
+type status struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+//get decodes the response of a GET request to the API server
+//the errors are classified with the operation and the RBAC action, ex: "list deployments.apps"
+func (p Provider) get(ctx context.Context, path string, query url.Values, operation string, action string, output any) error {
+	requestUrl := p.server + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can't call the API server: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("can't read the response of the API server: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return newFetchError(resp.StatusCode, body, operation, action)
+	}
+	if err := json.Unmarshal(body, output); err != nil {
+		return fmt.Errorf("can't decode the response of the API server: %w", err)
+	}
+	return nil
+}
+
+//discover returns the API resource of a kind, an error if the kind is not served by the API server, ex: a CRD which is not installed
+func (p Provider) discover(ctx context.Context, k kind) (apiResource, error) {
+	var resources struct {
+		Resources []apiResource `json:"resources"`
+	}
+	err := p.get(ctx, k.path(), nil, "Discovery", "get "+k.path(), &resources)
+	var fetchErr *types.FetchError
+	if errors.As(err, &fetchErr) && fetchErr.Code == "NotFound" {
+		return apiResource{}, fmt.Errorf("the group version %v is not served by the cluster", strings.TrimPrefix(k.path(), "/apis/"))
+	}
+	if err != nil {
+		return apiResource{}, err
+	}
+	for _, r := range resources.Resources {
+		//the subresources are skipped, ex: deployments/status
+		if r.Kind == k.kind && !strings.Contains(r.Name, "/") {
+			return r, nil
+		}
+	}
+	return apiResource{}, fmt.Errorf("the kind %v is not served by the cluster in %v", k.kind, strings.TrimPrefix(k.path(), "/apis/"))
+}
+
+//list returns a page of the objects of all the namespaces
+func (p Provider) list(ctx context.Context, k kind, r apiResource, continueToken string) (objectList, error) {
+	query := url.Values{}
+	query.Set("limit", pageSize)
+	if continueToken != "" {
+		query.Set("continue", continueToken)
+	}
+	var page objectList
+	err := p.get(ctx, k.path()+"/"+r.Name, query, "List", "list "+r.qualifiedName(k), &page)
+	return page, err
+}
+
+//newFetchError returns the classified error of a failed API call
+func newFetchError(statusCode int, body []byte, operation string, action string) error {
+	var s status
+	if err := json.Unmarshal(body, &s); err != nil || s.Reason == "" {
+		s.Reason = strings.ReplaceAll(http.StatusText(statusCode), " ", "")
+		if s.Message == "" {
+			s.Message = string(body)
+		}
+	}
+	fetchErr := &types.FetchError{
+		Kind:      model.ErrorKindUnknown,
+		Code:      s.Reason,
+		Operation: operation,
+		Action:    action,
+		Err:       fmt.Errorf("the API server returned %v: %v", s.Reason, s.Message),
+	}
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		fetchErr.Kind = model.ErrorKindPermission
+	case http.StatusTooManyRequests:
+		fetchErr.Kind = model.ErrorKindThrottling
+	}
+	return fetchErr
+}
@@ -0,0 +1,282 @@
+package kubernetes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v2"
+)
+
+//kubeconfig is a kubeconfig file, only the settings used to connect to the clusters are read
+//see https://kubernetes.io/docs/concepts/configuration/organize-cluster-access-kubeconfig/
+type kubeconfig struct {
+	CurrentContext string         `yaml:"current-context"`
+	Clusters       []namedCluster `yaml:"clusters"`
+	Users          []namedUser    `yaml:"users"`
+	Contexts       []namedContext `yaml:"contexts"`
+}
+
+type namedCluster struct {
+	Name    string  `yaml:"name"`
+	Cluster cluster `yaml:"cluster"`
+}
+
+type cluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	TLSServerName            string `yaml:"tls-server-name"`
+}
+
+type namedUser struct {
+	Name string `yaml:"name"`
+	User user   `yaml:"user"`
+}
+
+type user struct {
+	Token                 string      `yaml:"token"`
+	TokenFile             string      `yaml:"tokenFile"`
+	ClientCertificate     string      `yaml:"client-certificate"`
+	ClientCertificateData string      `yaml:"client-certificate-data"`
+	ClientKey             string      `yaml:"client-key"`
+	ClientKeyData         string      `yaml:"client-key-data"`
+	Exec                  *execConfig `yaml:"exec"`
+}
+
+//execConfig is a credential plugin, ex: "aws eks get-token"
+type execConfig struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	Env        []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+type namedContext struct {
+	Name    string      `yaml:"name"`
+	Context contextInfo `yaml:"context"`
+}
+
+type contextInfo struct {
+	Cluster string `yaml:"cluster"`
+	User    string `yaml:"user"`
+}
+
+//kubeconfigPaths returns the path of the kubeconfig if set, otherwise the paths of KUBECONFIG, otherwise ~/.kube/config
+func kubeconfigPaths(path string) ([]string, error) {
+	if path != "" {
+		return []string{path}, nil
+	}
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		var paths []string
+		for _, p := range filepath.SplitList(env) {
+			if p != "" {
+				paths = append(paths, p)
+			}
+		}
+		return paths, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("can't find the kubeconfig: %w", err)
+	}
+	return []string{filepath.Join(home, ".kube", "config")}, nil
+}
+
+//loadKubeconfig reads and merges the kubeconfig files, the first file setting a value wins like with kubectl
+//the relative paths of the files are resolved from the directory of the kubeconfig
+func loadKubeconfig(paths []string) (kubeconfig, error) {
+	var merged kubeconfig
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			//the files of KUBECONFIG which don't exist are ignored by kubectl
+			if os.IsNotExist(err) && len(paths) > 1 {
+				continue
+			}
+			return kubeconfig{}, fmt.Errorf("can't read the kubeconfig: %w", err)
+		}
+		var config kubeconfig
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return kubeconfig{}, fmt.Errorf("invalid kubeconfig %v: %w", path, err)
+		}
+		dir := filepath.Dir(path)
+		if merged.CurrentContext == "" {
+			merged.CurrentContext = config.CurrentContext
+		}
+		for _, c := range config.Clusters {
+			if _, found := merged.cluster(c.Name); !found {
+				c.Cluster.CertificateAuthority = resolvePath(dir, c.Cluster.CertificateAuthority)
+				merged.Clusters = append(merged.Clusters, c)
+			}
+		}
+		for _, u := range config.Users {
+			if _, found := merged.user(u.Name); !found {
+				u.User.TokenFile = resolvePath(dir, u.User.TokenFile)
+				u.User.ClientCertificate = resolvePath(dir, u.User.ClientCertificate)
+				u.User.ClientKey = resolvePath(dir, u.User.ClientKey)
+				merged.Users = append(merged.Users, u)
+			}
+		}
+		for _, c := range config.Contexts {
+			if _, found := merged.context(c.Name); !found {
+				merged.Contexts = append(merged.Contexts, c)
+			}
+		}
+	}
+	return merged, nil
+}
+
+func resolvePath(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func (k kubeconfig) cluster(name string) (cluster, bool) {
+	for _, c := range k.Clusters {
+		if c.Name == name {
+			return c.Cluster, true
+		}
+	}
+	return cluster{}, false
+}
+
+func (k kubeconfig) user(name string) (user, bool) {
+	for _, u := range k.Users {
+		if u.Name == name {
+			return u.User, true
+		}
+	}
+	return user{}, false
+}
+
+func (k kubeconfig) context(name string) (contextInfo, bool) {
+	for _, c := range k.Contexts {
+		if c.Name == name {
+			return c.Context, true
+		}
+	}
+	return contextInfo{}, false
+}
+
+//newClient returns the client of the API server of a cluster, authenticated as the user
+func newClient(c cluster, u user) (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipTLSVerify, ServerName: c.TLSServerName}
+	ca, err := fileOrData(c.CertificateAuthority, c.CertificateAuthorityData)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the certificate authority: %w", err)
+	}
+	if ca != nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid certificate authority, no PEM certificate found")
+		}
+	}
+	cert, err := fileOrData(u.ClientCertificate, u.ClientCertificateData)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the client certificate: %w", err)
+	}
+	if cert != nil {
+		key, err := fileOrData(u.ClientKey, u.ClientKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the client key: %w", err)
+		}
+		keyPair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{keyPair}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	tokenSource, err := newTokenSource(u)
+	if err != nil {
+		return nil, err
+	}
+	if tokenSource == nil {
+		return &http.Client{Transport: transport}, nil
+	}
+	return &http.Client{Transport: &oauth2.Transport{Source: tokenSource, Base: transport}}, nil
+}
+
+//fileOrData returns the content of the file if set, otherwise the base64 decoded data, nil if none is set
+func fileOrData(file string, data string) ([]byte, error) {
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return nil, nil
+}
+
+//newTokenSource returns the bearer tokens of the user, nil if the user doesn't use a token
+func newTokenSource(u user) (oauth2.TokenSource, error) {
+	switch {
+	case u.Token != "":
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: u.Token}), nil
+	case u.TokenFile != "":
+		token, err := os.ReadFile(u.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the token file: %w", err)
+		}
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: strings.TrimSpace(string(token))}), nil
+	case u.Exec != nil:
+		return oauth2.ReuseTokenSource(nil, execTokenSource{config: *u.Exec}), nil
+	}
+	return nil, nil
+}
+
+//execTokenSource returns the tokens of a credential plugin
+//see https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins
+type execTokenSource struct {
+	config execConfig
+}
+
+//execCredential is the output of a credential plugin
+type execCredential struct {
+	Status struct {
+		Token               string    `json:"token"`
+		ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+func (s execTokenSource) Token() (*oauth2.Token, error) {
+	cmd := exec.Command(s.config.Command, s.config.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range s.config.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf(`KUBERNETES_EXEC_INFO={"apiVersion":"%v","kind":"ExecCredential","spec":{"interactive":false}}`, s.config.APIVersion))
+	output, err := cmd.Output()
+	if err != nil {
+		var stderr string
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			stderr = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("can't get a token from the credential plugin %v: %w %v", s.config.Command, err, stderr)
+	}
+	var credential execCredential
+	if err := json.Unmarshal(output, &credential); err != nil {
+		return nil, fmt.Errorf("can't decode the credential of the plugin %v: %w", s.config.Command, err)
+	}
+	if credential.Status.Token == "" {
+		return nil, fmt.Errorf("the credential plugin %v didn't return a token", s.config.Command)
+	}
+	return &oauth2.Token{AccessToken: credential.Status.Token, Expiry: credential.Status.ExpirationTimestamp}, nil
+}
@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	require.NoError(t, os.WriteFile(first, []byte(`
+current-context: dev
+clusters:
+- name: dev
+  cluster:
+    server: https://dev.example.com
+    certificate-authority: certs/ca.pem
+users:
+- name: dev
+  user:
+    tokenFile: /var/run/token
+contexts:
+- name: dev
+  context: {cluster: dev, user: dev}
+`), 0600))
+	second := filepath.Join(dir, "second")
+	require.NoError(t, os.WriteFile(second, []byte(`
+current-context: prod
+clusters:
+- name: dev
+  cluster:
+    server: https://other.example.com
+- name: prod
+  cluster:
+    server: https://prod.example.com
+users:
+- name: prod
+  user:
+    client-certificate: prod.crt
+    client-key: prod.key
+contexts:
+- name: prod
+  context: {cluster: prod, user: prod}
+`), 0600))
+
+	//the first file setting a value wins, the relative paths are resolved from the directory of the file
+	kubeconfig, err := loadKubeconfig([]string{first, second})
+	require.NoError(t, err)
+	assert.Equal(t, "dev", kubeconfig.CurrentContext)
+	dev, found := kubeconfig.cluster("dev")
+	require.True(t, found)
+	assert.Equal(t, cluster{Server: "https://dev.example.com", CertificateAuthority: filepath.Join(dir, "certs", "ca.pem")}, dev)
+	devUser, _ := kubeconfig.user("dev")
+	assert.Equal(t, "/var/run/token", devUser.TokenFile)
+	prodUser, _ := kubeconfig.user("prod")
+	assert.Equal(t, filepath.Join(dir, "prod.crt"), prodUser.ClientCertificate)
+	assert.Equal(t, filepath.Join(dir, "prod.key"), prodUser.ClientKey)
+	prod, found := kubeconfig.context("prod")
+	require.True(t, found)
+	assert.Equal(t, contextInfo{Cluster: "prod", User: "prod"}, prod)
+
+	//a missing file is only ignored when it is not the only one
+	_, err = loadKubeconfig([]string{filepath.Join(dir, "missing"), second})
+	assert.NoError(t, err)
+	_, err = loadKubeconfig([]string{filepath.Join(dir, "missing")})
+	assert.ErrorContains(t, err, "can't read the kubeconfig")
+}
+
+func TestParseKind(t *testing.T) {
+	k, err := parseKind("v1/Service")
+	require.NoError(t, err)
+	assert.Equal(t, kind{version: "v1", kind: "Service"}, k)
+	assert.Equal(t, "/api/v1", k.path())
+	assert.Equal(t, "kubernetes.Service", k.resourceType())
+
+	k, err = parseKind("networking.k8s.io/v1/Ingress")
+	require.NoError(t, err)
+	assert.Equal(t, "/apis/networking.k8s.io/v1", k.path())
+	assert.Equal(t, "kubernetes.networking.k8s.io/Ingress", k.resourceType())
+	assert.Equal(t, "ingresses.networking.k8s.io", apiResource{Name: "ingresses"}.qualifiedName(k))
+
+	for _, invalid := range []string{"Service", "apps//Deployment", "a/b/c/d", ""} {
+		_, err = parseKind(invalid)
+		assert.ErrorContains(t, err, "the format is group/version/Kind", invalid)
+	}
+}
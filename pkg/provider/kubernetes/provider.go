@@ -0,0 +1,216 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/juandiegopalomino/cloudgrep/pkg/util"
+	"go.uber.org/zap"
+)
+
+//Provider fetches the objects of a Kubernetes cluster, using a context of the kubeconfig
+type Provider struct {
+	client *http.Client
+	server string
+	//context is the kubeconfig context, cluster is the name of its cluster
+	context string
+	cluster string
+	kinds   []kind
+}
+
+func (p Provider) String() string {
+	return fmt.Sprintf("Kubernetes Provider for context %v", p.context)
+}
+
+//AccountId is the name of the cluster in the kubeconfig
+func (p Provider) AccountId() string {
+	return p.cluster
+}
+
+//Region is empty as a cluster has no region
+func (p Provider) Region() string {
+	return ""
+}
+
+func (p Provider) FetchFunctions() map[string]types.FetchFunc {
+	funcMap := make(map[string]types.FetchFunc)
+	for _, k := range p.kinds {
+		funcMap[k.resourceType()] = p.fetchFunc(k)
+	}
+	return funcMap
+}
+
+//fetchFunc returns the function fetching the objects of a kind in all the namespaces, the pages are sent as they are read
+func (p Provider) fetchFunc(k kind) types.FetchFunc {
+	return func(ctx context.Context, output chan<- model.Resource) error {
+		r, err := p.discover(ctx, k)
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %w", k.resourceType(), err)
+		}
+		var continueToken string
+		for {
+			page, err := p.list(ctx, k, r, continueToken)
+			if err != nil {
+				return fmt.Errorf("failed to fetch %s: %w", k.resourceType(), err)
+			}
+			var resources []model.Resource
+			for _, raw := range page.Items {
+				resource, err := p.toResource(k, r, raw)
+				if err != nil {
+					return err
+				}
+				resources = append(resources, resource)
+			}
+			if err := util.SendAllFromSlice(ctx, output, resources); err != nil {
+				return err
+			}
+			if page.Metadata.Continue == "" {
+				return nil
+			}
+			continueToken = page.Metadata.Continue
+		}
+	}
+}
+
+//toResource converts an object, the labels are the tags
+//the id is the path of the object in the cluster, ex: my-cluster/namespaces/default/deployments.apps/web
+func (p Provider) toResource(k kind, r apiResource, raw json.RawMessage) (model.Resource, error) {
+	var object objectMetadata
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return model.Resource{}, fmt.Errorf("can't decode the %v object: %w", k.resourceType(), err)
+	}
+	if object.Metadata.Name == "" {
+		return model.Resource{}, fmt.Errorf("could not find the name of a %v object", k.resourceType())
+	}
+	path := []string{p.cluster}
+	if object.Metadata.Namespace != "" {
+		path = append(path, "namespaces", object.Metadata.Namespace)
+	}
+	path = append(path, r.qualifiedName(k), object.Metadata.Name)
+	rawData, err := withoutManagedFields(raw)
+	if err != nil {
+		return model.Resource{}, fmt.Errorf("can't decode the %v object: %w", k.resourceType(), err)
+	}
+	resource := model.Resource{
+		Id:        strings.Join(path, "/"),
+		DisplayId: object.Metadata.Name,
+		AccountId: p.cluster,
+		Namespace: object.Metadata.Namespace,
+		Type:      k.resourceType(),
+		RawData:   rawData,
+	}
+	for key, value := range object.Metadata.Labels {
+		resource.Tags = append(resource.Tags, model.Tag{Key: key, Value: value})
+	}
+	sort.Slice(resource.Tags, func(i, j int) bool {
+		return resource.Tags[i].Key < resource.Tags[j].Key
+	})
+	return resource, nil
+}
+
+//withoutManagedFields removes the managed fields of an object, they are only used by the server-side apply and are as large as the object
+func withoutManagedFields(raw json.RawMessage) ([]byte, error) {
+	var object map[string]any
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+	metadata, ok := object["metadata"].(map[string]any)
+	if !ok || metadata["managedFields"] == nil {
+		return raw, nil
+	}
+	delete(metadata, "managedFields")
+	return json.Marshal(object)
+}
+
+//NewProviders returns a provider for each context of the kubeconfig, the default is the current context
+func NewProviders(ctx context.Context, config cfg.Provider, logger *zap.Logger) ([]types.Provider, error) {
+	logger.Info("Connecting to Kubernetes")
+	kinds, err := parseKinds(config.Kubernetes.Kinds)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := kubeconfigPaths(config.Kubernetes.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig, err := loadKubeconfig(paths)
+	if err != nil {
+		return nil, err
+	}
+	contexts := config.Kubernetes.Contexts
+	if len(contexts) == 0 {
+		if kubeconfig.CurrentContext == "" {
+			return nil, fmt.Errorf("no current context in the kubeconfig, please set the contexts of the kubernetes provider")
+		}
+		contexts = []string{kubeconfig.CurrentContext}
+	}
+	var providers []types.Provider
+	for _, name := range contexts {
+		provider, err := newProvider(kubeconfig, name, kinds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig context %v: %w", name, err)
+		}
+		var version struct {
+			GitVersion string `json:"gitVersion"`
+		}
+		if err := provider.get(ctx, "/version", nil, "Version", "get /version", &version); err != nil {
+			return nil, fmt.Errorf("can't connect to the cluster of the context %v: %w", name, err)
+		}
+		logger.Sugar().Infof("Creating provider for Kubernetes context %v, cluster %v version %v", name, provider.cluster, version.GitVersion)
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+//parseKinds returns the kinds to list, the default kinds if not set
+func parseKinds(gvks []string) ([]kind, error) {
+	if len(gvks) == 0 {
+		gvks = defaultKinds
+	}
+	var kinds []kind
+	resourceTypes := make(map[string]string)
+	for _, gvk := range gvks {
+		k, err := parseKind(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if other, found := resourceTypes[k.resourceType()]; found {
+			return nil, fmt.Errorf("invalid kind '%v', the kind is already listed with '%v'", gvk, other)
+		}
+		resourceTypes[k.resourceType()] = gvk
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+//newProvider returns the provider of a context of the kubeconfig
+func newProvider(kubeconfig kubeconfig, name string, kinds []kind) (Provider, error) {
+	context, found := kubeconfig.context(name)
+	if !found {
+		return Provider{}, fmt.Errorf("the context is not in the kubeconfig")
+	}
+	cluster, found := kubeconfig.cluster(context.Cluster)
+	if !found || cluster.Server == "" {
+		return Provider{}, fmt.Errorf("the server of the cluster '%v' is not in the kubeconfig", context.Cluster)
+	}
+	//a context without user uses the anonymous access
+	user, _ := kubeconfig.user(context.User)
+	client, err := newClient(cluster, user)
+	if err != nil {
+		return Provider{}, err
+	}
+	return Provider{
+		client:  client,
+		server:  strings.TrimSuffix(cluster.Server, "/"),
+		context: name,
+		cluster: context.Cluster,
+		kinds:   kinds,
+	}, nil
+}
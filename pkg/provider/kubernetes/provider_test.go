@@ -0,0 +1,264 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+//apiServer is a stub of the Kubernetes API server
+//the deployments are returned in two pages, the nodes can't be listed and the missing.example.com group is not served
+type apiServer struct {
+	t *testing.T
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"Unauthorized","reason":"Unauthorized","code":401}`)
+		return
+	}
+	if r.URL.Path != "/version" && r.URL.Query().Get("limit") != "" {
+		assert.Equal(s.t, "500", r.URL.Query().Get("limit"))
+	}
+	switch r.URL.Path {
+	case "/version":
+		fmt.Fprint(w, `{"major":"1","minor":"24","gitVersion":"v1.24.1"}`)
+	case "/api/v1":
+		fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"v1","resources":[{"name":"services","namespaced":true,"kind":"Service"},{"name":"services/status","namespaced":true,"kind":"Service"},{"name":"nodes","namespaced":false,"kind":"Node"},{"name":"persistentvolumeclaims","namespaced":true,"kind":"PersistentVolumeClaim"}]}`)
+	case "/apis/apps/v1":
+		fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"apps/v1","resources":[{"name":"deployments/status","namespaced":true,"kind":"Deployment"},{"name":"deployments","namespaced":true,"kind":"Deployment"},{"name":"statefulsets","namespaced":true,"kind":"StatefulSet"}]}`)
+	case "/apis/networking.k8s.io/v1":
+		fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"networking.k8s.io/v1","resources":[{"name":"ingresses","namespaced":true,"kind":"Ingress"}]}`)
+	case "/apis/stable.example.com/v1":
+		fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"stable.example.com/v1","resources":[{"name":"crontabs","namespaced":true,"kind":"CronTab"}]}`)
+	case "/apis/apps/v1/deployments":
+		if r.URL.Query().Get("continue") == "" {
+			fmt.Fprint(w, `{"kind":"DeploymentList","apiVersion":"apps/v1","metadata":{"continue":"page-2"},"items":[{"metadata":{"name":"web","namespace":"default","uid":"1","labels":{"team":"infra","app":"web"},"managedFields":[{"manager":"kubectl"}]},"spec":{"replicas":3}}]}`)
+			return
+		}
+		assert.Equal(s.t, "page-2", r.URL.Query().Get("continue"))
+		fmt.Fprint(w, `{"kind":"DeploymentList","apiVersion":"apps/v1","metadata":{},"items":[{"metadata":{"name":"api","namespace":"backend","uid":"2"},"spec":{"replicas":1}}]}`)
+	case "/apis/stable.example.com/v1/crontabs":
+		fmt.Fprint(w, `{"kind":"CronTabList","apiVersion":"stable.example.com/v1","metadata":{},"items":[{"metadata":{"name":"backup","namespace":"ops","labels":{"team":"ops"}},"spec":{"cronSpec":"0 1 * * *"}}]}`)
+	case "/api/v1/nodes":
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"nodes is forbidden: User \"cloudgrep\" cannot list resource \"nodes\" in API group \"\" at the cluster scope","reason":"Forbidden","details":{"kind":"nodes"},"code":403}`)
+	case "/apis/missing.example.com/v1":
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "404 page not found")
+	default:
+		fmt.Fprint(w, `{"metadata":{},"items":[]}`)
+	}
+}
+
+//newAPIServer returns a TLS stub of the API server and its certificate authority in base64
+func newAPIServer(t *testing.T) (*httptest.Server, string) {
+	server := httptest.NewTLSServer(&apiServer{t: t})
+	t.Cleanup(server.Close)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server, base64.StdEncoding.EncodeToString(ca)
+}
+
+//writeKubeconfig writes a kubeconfig with a context using the server, the user of the context must be defined in users
+func writeKubeconfig(t *testing.T, server string, caData string, users string) string {
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod-cluster
+  cluster:
+    server: %v
+    certificate-authority-data: %v
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: cloudgrep
+    namespace: default
+- name: unknown-cluster
+  context:
+    cluster: missing
+    user: cloudgrep
+users:
+%v`, server, caData, users)
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+	return path
+}
+
+func fetchAll(p types.Provider, resourceType string) ([]model.Resource, error) {
+	output := make(chan model.Resource, 10)
+	err := p.FetchFunctions()[resourceType](context.Background(), output)
+	close(output)
+	var resources []model.Resource
+	for r := range output {
+		resources = append(resources, r)
+	}
+	return resources, err
+}
+
+func TestFetchFunctions(t *testing.T) {
+	server, caData := newAPIServer(t)
+	kubeconfig := writeKubeconfig(t, server.URL, caData, `- name: cloudgrep
+  user:
+    token: test-token
+`)
+	providerCfg := cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kubeconfig: kubeconfig, Kinds: []string{"apps/v1/Deployment", "v1/Node", "v1/Service", "stable.example.com/v1/CronTab", "missing.example.com/v1/Widget", "apps/v1/Widget"}}}
+	providers, err := NewProviders(context.Background(), providerCfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	p := providers[0]
+	assert.Equal(t, "prod-cluster", p.AccountId())
+	assert.Equal(t, "", p.Region())
+	assert.Equal(t, "Kubernetes Provider for context prod", p.String())
+	assert.Len(t, p.FetchFunctions(), 6)
+
+	resources, err := fetchAll(p, "kubernetes.apps/Deployment")
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	web := resources[0]
+	assert.Equal(t, "prod-cluster/namespaces/default/deployments.apps/web", web.Id)
+	assert.Equal(t, "web", web.DisplayId)
+	assert.Equal(t, "prod-cluster", web.AccountId)
+	assert.Equal(t, "default", web.Namespace)
+	assert.Equal(t, "", web.Region)
+	assert.Equal(t, "kubernetes.apps/Deployment", web.Type)
+	assert.Equal(t, model.Tags{{Key: "app", Value: "web"}, {Key: "team", Value: "infra"}}, web.Tags)
+	var rawData map[string]any
+	require.NoError(t, json.Unmarshal(web.RawData, &rawData))
+	assert.Equal(t, map[string]any{"replicas": float64(3)}, rawData["spec"])
+	//the managed fields are removed
+	assert.Equal(t, map[string]any{"name": "web", "namespace": "default", "uid": "1", "labels": map[string]any{"team": "infra", "app": "web"}}, rawData["metadata"])
+	assert.Equal(t, "backend", resources[1].Namespace)
+	assert.Empty(t, resources[1].Tags)
+
+	//a custom resource
+	resources, err = fetchAll(p, "kubernetes.stable.example.com/CronTab")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	assert.Equal(t, "prod-cluster/namespaces/ops/crontabs.stable.example.com/backup", resources[0].Id)
+	assert.Equal(t, model.Tags{{Key: "team", Value: "ops"}}, resources[0].Tags)
+
+	//no object
+	resources, err = fetchAll(p, "kubernetes.Service")
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+
+	//the missing permission is reported
+	_, err = fetchAll(p, "kubernetes.Node")
+	require.ErrorContains(t, err, `failed to fetch kubernetes.Node: the API server returned Forbidden: nodes is forbidden: User "cloudgrep" cannot list resource "nodes"`)
+	fetchErr := types.ClassifyError(err)
+	assert.Equal(t, model.ErrorKindPermission, fetchErr.Kind)
+	assert.Equal(t, "Forbidden", fetchErr.Code)
+	assert.Equal(t, "List", fetchErr.Operation)
+	assert.Equal(t, "list nodes", fetchErr.Action)
+
+	//the kinds which are not served
+	_, err = fetchAll(p, "kubernetes.missing.example.com/Widget")
+	assert.EqualError(t, err, "failed to fetch kubernetes.missing.example.com/Widget: the group version missing.example.com/v1 is not served by the cluster")
+	_, err = fetchAll(p, "kubernetes.apps/Widget")
+	assert.EqualError(t, err, "failed to fetch kubernetes.apps/Widget: the kind Widget is not served by the cluster in apps/v1")
+}
+
+func TestNewProviders(t *testing.T) {
+	server, caData := newAPIServer(t)
+	kubeconfig := writeKubeconfig(t, server.URL, caData, `- name: cloudgrep
+  user:
+    token: test-token
+`)
+
+	//the default kubeconfig and kinds are used
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing")+string(filepath.ListSeparator)+kubeconfig)
+	providers, err := NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes"}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 1)
+	assert.Equal(t, []string{
+		"kubernetes.Node",
+		"kubernetes.PersistentVolumeClaim",
+		"kubernetes.Service",
+		"kubernetes.apps/Deployment",
+		"kubernetes.apps/StatefulSet",
+		"kubernetes.networking.k8s.io/Ingress",
+	}, sortedKeys(providers[0].FetchFunctions()))
+
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Contexts: []string{"prod", "unknown-cluster"}}}, zaptest.NewLogger(t))
+	assert.EqualError(t, err, "invalid kubeconfig context unknown-cluster: the server of the cluster 'missing' is not in the kubeconfig")
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Contexts: []string{"dev"}}}, zaptest.NewLogger(t))
+	assert.EqualError(t, err, "invalid kubeconfig context dev: the context is not in the kubeconfig")
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kinds: []string{"Deployment"}}}, zaptest.NewLogger(t))
+	assert.EqualError(t, err, "invalid kind 'Deployment', the format is group/version/Kind, ex: apps/v1/Deployment")
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kinds: []string{"apps/v1/Deployment", "apps/v1beta1/Deployment"}}}, zaptest.NewLogger(t))
+	assert.EqualError(t, err, "invalid kind 'apps/v1beta1/Deployment', the kind is already listed with 'apps/v1/Deployment'")
+
+	//the token is not valid
+	kubeconfig = writeKubeconfig(t, server.URL, caData, `- name: cloudgrep
+  user:
+    token: expired
+`)
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kubeconfig: kubeconfig}}, zaptest.NewLogger(t))
+	assert.EqualError(t, err, "can't connect to the cluster of the context prod: the API server returned Unauthorized: Unauthorized")
+
+	//the certificate of the server is not trusted
+	kubeconfig = writeKubeconfig(t, server.URL, "", `- name: cloudgrep
+  user:
+    token: test-token
+`)
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kubeconfig: kubeconfig}}, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "certificate")
+}
+
+func sortedKeys(funcMap map[string]types.FetchFunc) []string {
+	var keys []string
+	for key := range funcMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestNewProviders_exec(t *testing.T) {
+	server, caData := newAPIServer(t)
+	plugin := filepath.Join(t.TempDir(), "get-token")
+	require.NoError(t, os.WriteFile(plugin, []byte(`#!/bin/sh
+[ "$1" = "--cluster" ] && [ "$CLUSTER_ENV" = "prod" ] || exit 1
+echo '{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","status":{"token":"test-token","expirationTimestamp":"2099-01-01T00:00:00Z"}}'
+`), 0700))
+	kubeconfig := writeKubeconfig(t, server.URL, caData, fmt.Sprintf(`- name: cloudgrep
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: %v
+      args: [--cluster, prod]
+      env:
+      - name: CLUSTER_ENV
+        value: prod
+`, plugin))
+	providers, err := NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kubeconfig: kubeconfig}}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	resources, err := fetchAll(providers[0], "kubernetes.apps/Deployment")
+	require.NoError(t, err)
+	assert.Len(t, resources, 2)
+
+	//the plugin fails
+	require.NoError(t, os.WriteFile(plugin, []byte("#!/bin/sh\necho 'no credentials' >&2\nexit 1\n"), 0700))
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "kubernetes", Kubernetes: cfg.Kubernetes{Kubeconfig: kubeconfig}}, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "can't get a token from the credential plugin "+plugin+": exit status 1 no credentials")
+}
@@ -6,6 +6,10 @@
 
 	"github.com/juandiegopalomino/cloudgrep/pkg/config"
 	"github.com/juandiegopalomino/cloudgrep/pkg/provider/aws"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/azure"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/gcp"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/kubernetes"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/terraform"
 	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
 	"go.uber.org/zap"
 )
@@ -17,8 +21,20 @@
 var extraProviders map[string][]Provider = map[string][]Provider{}
 
 func NewProviders(ctx context.Context, config config.Provider, logger *zap.Logger) ([]Provider, error) {
-	if config.Cloud == "aws" {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	switch config.Cloud {
+	case "aws":
 		return aws.NewProviders(ctx, config, logger)
+	case "gcp":
+		return gcp.NewProviders(ctx, config, logger)
+	case "azure":
+		return azure.NewProviders(ctx, config, logger)
+	case "kubernetes":
+		return kubernetes.NewProviders(ctx, config, logger)
+	case "terraform":
+		return terraform.NewProviders(ctx, config, logger)
 	}
 	if providers, ok := extraProviders[config.Cloud]; ok {
 		return providers, nil
@@ -38,6 +38,11 @@ func TestNewProviders(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "foo", providers[0].AccountId())
 
+	//the options of another cloud are rejected
+	fakeCfg.GCP.Projects = []string{"my-project"}
+	_, err = NewProviders(ctx, fakeCfg, logger)
+	require.ErrorContains(t, err, "gcp can only be set for the gcp cloud, not fake")
+
 }
 
 func fakeProviders() []Provider {
@@ -0,0 +1,175 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/juandiegopalomino/cloudgrep/pkg/util"
+	"go.uber.org/zap"
+)
+
+//stateExtension is the extension of the state files searched in the directories
+const stateExtension = ".tfstate"
+
+//Provider reads the resources of a Terraform state file, no credentials are used
+type Provider struct {
+	path   string
+	logger *zap.Logger
+	//types are the resource types of the last successful read, their fetch fails if the state can't be read
+	types *stateTypes
+	//stateIds identifies all the resources by their state and their address, it is set when a cloud is scanned too:
+	//the cloud ids are then the ids of the scanned resources
+	stateIds bool
+}
+
+type stateTypes struct {
+	sync.Mutex
+	resourceTypes []string
+}
+
+func (p Provider) String() string {
+	return fmt.Sprintf("Terraform Provider for state %v", p.path)
+}
+
+//WithStateIds returns the provider identifying all the resources by their state and their address
+func (p Provider) WithStateIds() Provider {
+	p.stateIds = true
+	return p
+}
+
+//AccountId is the absolute path of the state file, it is the account of the resources of the state
+func (p Provider) AccountId() string {
+	return p.path
+}
+
+//Region is empty as a state file can declare resources in several regions
+func (p Provider) Region() string {
+	return ""
+}
+
+//FetchFunctions reads the state file, the state is read again at each call to get the resources of the run
+func (p Provider) FetchFunctions() map[string]types.FetchFunc {
+	funcMap := make(map[string]types.FetchFunc)
+	resources, err := readState(p.path)
+	if err != nil {
+		p.logger.Sugar().Errorf("Can't read the Terraform state %v: %v", p.path, err)
+		p.types.Lock()
+		defer p.types.Unlock()
+		for _, resourceType := range p.types.resourceTypes {
+			funcMap[resourceType] = failedFetchFunc(resourceType, err)
+		}
+		return funcMap
+	}
+	if p.stateIds {
+		for i := range resources {
+			resources[i].Id = stateId(p.path, resources[i].DisplayId)
+		}
+	}
+	resourcesByType, resourceTypes := groupByType(resources)
+	for resourceType, typeResources := range resourcesByType {
+		funcMap[resourceType] = fetchFunc(typeResources)
+	}
+	p.types.Lock()
+	p.types.resourceTypes = resourceTypes
+	p.types.Unlock()
+	return funcMap
+}
+
+//groupByType returns the resources by type and the sorted types
+func groupByType(resources []declaredResource) (map[string][]model.Resource, []string) {
+	resourcesByType := make(map[string][]model.Resource)
+	var resourceTypes []string
+	for _, resource := range resources {
+		if _, found := resourcesByType[resource.Type]; !found {
+			resourceTypes = append(resourceTypes, resource.Type)
+		}
+		resourcesByType[resource.Type] = append(resourcesByType[resource.Type], resource.Resource)
+	}
+	sort.Strings(resourceTypes)
+	return resourcesByType, resourceTypes
+}
+
+func fetchFunc(resources []model.Resource) types.FetchFunc {
+	return func(ctx context.Context, output chan<- model.Resource) error {
+		return util.SendAllFromSlice(ctx, output, resources)
+	}
+}
+
+//failedFetchFunc returns the error of the state, the fetch of the type is reported as failed
+func failedFetchFunc(resourceType string, err error) types.FetchFunc {
+	return func(ctx context.Context, output chan<- model.Resource) error {
+		return fmt.Errorf("failed to fetch %s: %w", resourceType, err)
+	}
+}
+
+//NewProviders returns a provider for each state file, the directories are searched recursively for .tfstate files
+func NewProviders(ctx context.Context, config cfg.Provider, logger *zap.Logger) ([]types.Provider, error) {
+	logger.Info("Reading the Terraform states")
+	if len(config.Terraform.States) == 0 {
+		return nil, fmt.Errorf("no Terraform state, please set the states of the terraform provider")
+	}
+	paths, err := statePaths(config.Terraform.States)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no Terraform state found in %v", config.Terraform.States)
+	}
+	var providers []types.Provider
+	for _, path := range paths {
+		//the state is read to report the invalid states before the run
+		resources, err := readState(path)
+		if err != nil {
+			return nil, err
+		}
+		_, resourceTypes := groupByType(resources)
+		logger.Sugar().Infof("Creating provider for Terraform state %v", path)
+		providers = append(providers, Provider{path: path, logger: logger, types: &stateTypes{resourceTypes: resourceTypes}})
+	}
+	return providers, nil
+}
+
+//statePaths returns the absolute paths of the state files, the files in the directories are returned in lexical order
+//the paths are part of the ids of the resources, they don't depend on the working directory
+func statePaths(states []string) ([]string, error) {
+	var paths []string
+	for _, state := range states {
+		root, err := filepath.Abs(state)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the Terraform state: %w", err)
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, fmt.Errorf("can't read the Terraform state: %w", err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			//the .terraform directories contain the cache of the modules and the providers
+			if entry.IsDir() && entry.Name() == ".terraform" {
+				return filepath.SkipDir
+			}
+			if !entry.IsDir() && filepath.Ext(path) == stateExtension {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("can't search the Terraform states in %v: %w", state, err)
+		}
+	}
+	return paths, nil
+}
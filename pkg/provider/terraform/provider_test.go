@@ -0,0 +1,188 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	cfg "github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func fetchAll(p types.Provider, resourceType string) ([]model.Resource, error) {
+	output := make(chan model.Resource, 10)
+	err := p.FetchFunctions()[resourceType](context.Background(), output)
+	close(output)
+	var resources []model.Resource
+	for r := range output {
+		resources = append(resources, r)
+	}
+	return resources, err
+}
+
+func sortedKeys(funcMap map[string]types.FetchFunc) []string {
+	var keys []string
+	for key := range funcMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReadState(t *testing.T) {
+	statePath, err := filepath.Abs("testdata/terraform.tfstate")
+	require.NoError(t, err)
+	resources, err := readState(statePath)
+	require.NoError(t, err)
+	require.Len(t, resources, 7)
+	resourcesById := make(map[string]model.Resource)
+	for _, r := range resources {
+		resourcesById[r.Id] = r.Resource
+	}
+
+	//the id is the id in the cloud, the display id is the address in the state, the account is the state
+	//the tags include the default tags
+	instance := resourcesById["i-0a1b2c3d4e5f60001"]
+	assert.Equal(t, "ec2.Instance", instance.Type)
+	assert.Equal(t, "aws_instance.web[0]", instance.DisplayId)
+	assert.Equal(t, statePath, instance.AccountId)
+	assert.Equal(t, "us-east-1", instance.Region)
+	assert.Equal(t, "", instance.Module)
+	assert.Equal(t, model.ManagedByTerraform, instance.ManagedBy)
+	assert.Equal(t, model.Tags{{Key: "Name", Value: "web-0"}, {Key: "team", Value: "web"}}, instance.Tags)
+	var rawData map[string]any
+	require.NoError(t, json.Unmarshal(instance.RawData, &rawData))
+	assert.Equal(t, "t3.micro", rawData["instance_type"])
+	assert.Empty(t, resourcesById["i-0a1b2c3d4e5f60002"].Tags)
+
+	//the bucket has no region in its ARN, its region is an attribute
+	bucket := resourcesById["my-assets"]
+	assert.Equal(t, "s3.Bucket", bucket.Type)
+	assert.Equal(t, "module.storage.aws_s3_bucket.assets", bucket.DisplayId)
+	assert.Equal(t, "module.storage", bucket.Module)
+	assert.Equal(t, "eu-west-1", bucket.Region)
+	assert.Equal(t, model.Tags{{Key: "env", Value: "prod"}}, bucket.Tags)
+
+	//the id of the policy is the bucket name, the bucket keeps it and the policy is identified by its state and its address
+	policy := resourcesById["terraform:"+statePath+"/module.storage.aws_s3_bucket_policy.assets"]
+	assert.Equal(t, "terraform.aws_s3_bucket_policy", policy.Type)
+	assert.Equal(t, "module.storage", policy.Module)
+
+	//the ARN is the id of the IAM roles, IAM is global
+	role := resourcesById["arn:aws:iam::123456789012:role/api"]
+	assert.Equal(t, "iam.Role", role.Type)
+	assert.Equal(t, `aws_iam_role.app["api"]`, role.DisplayId)
+	assert.Equal(t, "global", role.Region)
+
+	//the sensitive attributes are removed
+	db := resourcesById["main"]
+	assert.Equal(t, "rds.DBInstance", db.Type)
+	require.NoError(t, json.Unmarshal(db.RawData, &rawData))
+	assert.NotContains(t, rawData, "password")
+	assert.Equal(t, "main", rawData["identifier"])
+
+	assert.Equal(t, "route53.HostedZone", resourcesById["/hostedzone/Z1D633PJN98FT9"].Type)
+
+	//only the version 4 is supported
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":3,"serial":1,"modules":[]}`), 0600))
+	_, err = readState(path)
+	assert.EqualError(t, err, "unsupported version 3 of the Terraform state "+path+", only the version 4 is supported")
+}
+
+func TestNewProviders(t *testing.T) {
+	//the directories are searched recursively, the .terraform directories are skipped
+	providers, err := NewProviders(context.Background(), cfg.Provider{Cloud: "terraform", Terraform: cfg.Terraform{States: []string{"testdata/terraform.tfstate", "testdata/live"}}}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	require.Len(t, providers, 2)
+	p := providers[0]
+	//the path is absolute, the ids don't depend on the working directory
+	statePath, err := filepath.Abs("testdata/terraform.tfstate")
+	require.NoError(t, err)
+	assert.Equal(t, statePath, p.AccountId())
+	assert.Equal(t, "", p.Region())
+	assert.Equal(t, []string{
+		"ec2.Instance",
+		"iam.Role",
+		"rds.DBInstance",
+		"route53.HostedZone",
+		"s3.Bucket",
+		"terraform.aws_s3_bucket_policy",
+	}, sortedKeys(p.FetchFunctions()))
+	resources, err := fetchAll(p, "ec2.Instance")
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "i-0a1b2c3d4e5f60001", resources[0].Id)
+
+	//when a cloud is scanned too, all the resources are identified by their state and their address
+	resources, err = fetchAll(p.(Provider).WithStateIds(), "ec2.Instance")
+	require.NoError(t, err)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "terraform:"+statePath+"/aws_instance.web[0]", resources[0].Id)
+
+	assert.Equal(t, filepath.Join(filepath.Dir(statePath), "live", "network", "terraform.tfstate"), providers[1].AccountId())
+	resources, err = fetchAll(providers[1], "ec2.Vpc")
+	require.NoError(t, err)
+	require.Len(t, resources, 1)
+	//the account of the resources is the account of the provider, the fetch failures of the provider apply to them
+	assert.Equal(t, providers[1].AccountId(), resources[0].AccountId)
+	assert.Equal(t, "eu-west-1", resources[0].Region)
+
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "terraform"}, zaptest.NewLogger(t))
+	assert.EqualError(t, err, "no Terraform state, please set the states of the terraform provider")
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "terraform", Terraform: cfg.Terraform{States: []string{t.TempDir()}}}, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "no Terraform state found in")
+	_, err = NewProviders(context.Background(), cfg.Provider{Cloud: "terraform", Terraform: cfg.Terraform{States: []string{"testdata/missing.tfstate"}}}, zaptest.NewLogger(t))
+	assert.ErrorContains(t, err, "can't read the Terraform state")
+}
+
+func TestFetchFunctions_invalidState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	data, err := os.ReadFile("testdata/terraform.tfstate")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	providers, err := NewProviders(context.Background(), cfg.Provider{Cloud: "terraform", Terraform: cfg.Terraform{States: []string{path}}}, zaptest.NewLogger(t))
+	require.NoError(t, err)
+
+	//the fetch of the types of the last read fails
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0600))
+	funcMap := providers[0].FetchFunctions()
+	assert.Len(t, funcMap, 6)
+	err = funcMap["s3.Bucket"](context.Background(), make(chan model.Resource))
+	assert.ErrorContains(t, err, "failed to fetch s3.Bucket: invalid Terraform state "+path)
+}
+
+func TestManagedIds(t *testing.T) {
+	ids, err := ManagedIds([]string{"testdata/terraform.tfstate", "testdata/live"})
+	require.NoError(t, err)
+	//the ids of the types without cloudgrep type and of the data sources are skipped, the ARNs are included
+	expected := []string{
+		"i-0a1b2c3d4e5f60001",
+		"arn:aws:ec2:us-east-1:123456789012:instance/i-0a1b2c3d4e5f60001",
+		"i-0a1b2c3d4e5f60002",
+		"arn:aws:ec2:us-east-1:123456789012:instance/i-0a1b2c3d4e5f60002",
+		"my-assets",
+		"arn:aws:s3:::my-assets",
+		"arn:aws:iam::123456789012:role/api",
+		"main",
+		"arn:aws:rds:us-east-1:123456789012:db:main",
+		"/hostedzone/Z1D633PJN98FT9",
+		"arn:aws:route53:::hostedzone/Z1D633PJN98FT9",
+		"vpc-0a1b2c3d",
+		"arn:aws:ec2:eu-west-1:210987654321:vpc/vpc-0a1b2c3d",
+	}
+	assert.Len(t, ids, len(expected))
+	for _, id := range expected {
+		assert.True(t, ids[id], id)
+	}
+
+	_, err = ManagedIds([]string{"testdata/missing.tfstate"})
+	assert.ErrorContains(t, err, "can't read the Terraform state")
+}
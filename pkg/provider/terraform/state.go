@@ -0,0 +1,253 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+)
+
+//stateVersion is the supported version of the state format, used since Terraform 0.12
+const stateVersion = 4
+
+//state is a Terraform state file, only the managed resources are read
+type state struct {
+	Version   int             `json:"version"`
+	Resources []stateResource `json:"resources"`
+}
+
+type stateResource struct {
+	//Module is the module address, empty for the root module, ex: module.vpc
+	Module    string          `json:"module"`
+	Mode      string          `json:"mode"`
+	Type      string          `json:"type"`
+	Name      string          `json:"name"`
+	Instances []stateInstance `json:"instances"`
+}
+
+type stateInstance struct {
+	//IndexKey is the index of the instances created with count (a number) or for_each (a string)
+	IndexKey any `json:"index_key"`
+	//Attributes are kept as is to be stored as the raw data of the resources
+	Attributes json.RawMessage `json:"attributes"`
+	//SensitiveAttributes are the paths of the sensitive values, ex: [[{"type":"get_attr","value":"password"}]]
+	SensitiveAttributes []statePath `json:"sensitive_attributes"`
+}
+
+type statePath []struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+//instanceAttributes are the attributes of an instance used to create a resource
+type instanceAttributes map[string]any
+
+//declaredResource is a resource declared in a state, cloudId is its id in the cloud, ex: the id of an EC2 instance
+type declaredResource struct {
+	model.Resource
+	cloudId string
+}
+
+//readState reads a state file and returns its resources
+func readState(path string) ([]declaredResource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the Terraform state: %w", err)
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid Terraform state %v: %w", path, err)
+	}
+	if s.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported version %v of the Terraform state %v, only the version %v is supported", s.Version, path, stateVersion)
+	}
+	var resources []declaredResource
+	for _, r := range s.Resources {
+		//the data sources are not declared by the configuration
+		if r.Mode != "managed" {
+			continue
+		}
+		for _, instance := range r.Instances {
+			resource, err := toResource(path, r, instance)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Terraform state %v: %w", path, err)
+			}
+			if resource.cloudId != "" {
+				resources = append(resources, resource)
+			}
+		}
+	}
+	setIds(path, resources)
+	return resources, nil
+}
+
+//setIds sets the cloud id as the id of the resources, a resource with the cloud id of another resource of the state is identified by the state and its address
+//the resources with a cloudgrep type get their cloud id first, the id of the other types can be the id of another resource, ex: the id of an aws_s3_bucket_policy is the bucket name
+func setIds(path string, resources []declaredResource) {
+	used := make(map[string]bool, len(resources))
+	for _, unmapped := range []bool{false, true} {
+		for i := range resources {
+			r := &resources[i]
+			if strings.HasPrefix(r.Type, unmappedTypePrefix) != unmapped {
+				continue
+			}
+			if used[r.cloudId] {
+				r.Id = stateId(path, r.DisplayId)
+				continue
+			}
+			r.Id = r.cloudId
+			used[r.cloudId] = true
+		}
+	}
+}
+
+//stateId returns the id made of the state and the address of a resource, ex: terraform:/live/terraform.tfstate/aws_instance.web[0]
+//it is used when the cloud id of the resource is already used
+func stateId(path string, address string) string {
+	return model.TerraformStateIdPrefix + path + "/" + address
+}
+
+//ManagedIds returns the ids and the ARNs of the resources of the state files, they are the ids of the resources managed by Terraform
+//the directories are searched recursively for .tfstate files
+func ManagedIds(states []string) (map[string]bool, error) {
+	paths, err := statePaths(states)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, path := range paths {
+		resources, err := readState(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range resources {
+			//the id of the types without cloudgrep type can be the id of another resource, ex: the id of an aws_s3_bucket_policy is the bucket name
+			if !strings.HasPrefix(r.Type, unmappedTypePrefix) {
+				ids[r.cloudId] = true
+			}
+			var attributes struct {
+				Arn string `json:"arn"`
+			}
+			if err := json.Unmarshal(r.RawData, &attributes); err == nil && attributes.Arn != "" {
+				ids[attributes.Arn] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+//toResource converts an instance of a resource, the display id is the address, the account is the state like the account of its provider
+//the cloud id is the attribute used as id by the cloudgrep provider of the type, ex: the arn of an IAM role, the id is set by setIds
+//the resource has no cloud id if the id attribute is not set, ex: the instance is tainted before being created
+func toResource(path string, r stateResource, instance stateInstance) (declaredResource, error) {
+	address := r.address(instance.IndexKey)
+	var attributes instanceAttributes
+	if err := json.Unmarshal(instance.Attributes, &attributes); err != nil {
+		return declaredResource{}, fmt.Errorf("can't decode the attributes of %v: %w", address, err)
+	}
+	mapping, found := typeMappings[r.Type]
+	if !found {
+		mapping = typeMapping{resourceType: unmappedTypePrefix + r.Type}
+	}
+	if mapping.idAttribute == "" {
+		mapping.idAttribute = "id"
+	}
+	cloudId := attributes.string(mapping.idAttribute)
+	if cloudId == "" {
+		return declaredResource{}, nil
+	}
+	rawData, err := withoutSensitiveAttributes(instance)
+	if err != nil {
+		return declaredResource{}, fmt.Errorf("can't decode the attributes of %v: %w", address, err)
+	}
+	resource := model.Resource{
+		DisplayId: address,
+		AccountId: path,
+		Region:    attributes.region(),
+		Module:    r.Module,
+		ManagedBy: model.ManagedByTerraform,
+		Type:      mapping.resourceType,
+		RawData:   rawData,
+	}
+	//tags_all includes the default tags of the provider
+	tags := attributes.stringMap("tags_all")
+	if len(tags) == 0 {
+		tags = attributes.stringMap("tags")
+	}
+	for key, value := range tags {
+		resource.Tags = append(resource.Tags, model.Tag{Key: key, Value: value})
+	}
+	sort.Slice(resource.Tags, func(i, j int) bool {
+		return resource.Tags[i].Key < resource.Tags[j].Key
+	})
+	return declaredResource{Resource: resource, cloudId: mapping.idPrefix + cloudId}, nil
+}
+
+//address returns the address of an instance, ex: module.vpc.aws_subnet.private[0]
+func (r stateResource) address(indexKey any) string {
+	address := r.Type + "." + r.Name
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	switch key := indexKey.(type) {
+	case float64:
+		address += fmt.Sprintf("[%v]", key)
+	case string:
+		address += fmt.Sprintf("[%q]", key)
+	}
+	return address
+}
+
+func (a instanceAttributes) string(name string) string {
+	value, _ := a[name].(string)
+	return value
+}
+
+func (a instanceAttributes) stringMap(name string) map[string]string {
+	values, _ := a[name].(map[string]any)
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		if s, ok := value.(string); ok {
+			result[key] = s
+		}
+	}
+	return result
+}
+
+//region returns the region of the ARN, the region is global for the global services, ex: IAM
+//the region attribute is used for the resources without ARN, ex: an S3 bucket
+func (a instanceAttributes) region() string {
+	region := a.string("region")
+	//arn:partition:service:region:account-id:resource
+	if parts := strings.SplitN(a.string("arn"), ":", 6); len(parts) == 6 && parts[0] == "arn" {
+		if parts[3] != "" {
+			region = parts[3]
+		} else if region == "" {
+			region = "global"
+		}
+	}
+	return region
+}
+
+//withoutSensitiveAttributes returns the attributes without the sensitive ones, ex: the password of a database
+//the whole top level attribute is removed if one of its values is sensitive
+func withoutSensitiveAttributes(instance stateInstance) ([]byte, error) {
+	if len(instance.SensitiveAttributes) == 0 {
+		return instance.Attributes, nil
+	}
+	var attributes map[string]any
+	if err := json.Unmarshal(instance.Attributes, &attributes); err != nil {
+		return nil, err
+	}
+	for _, path := range instance.SensitiveAttributes {
+		if len(path) > 0 && path[0].Type == "get_attr" {
+			if name, ok := path[0].Value.(string); ok {
+				delete(attributes, name)
+			}
+		}
+	}
+	return json.Marshal(attributes)
+}
@@ -0,0 +1,61 @@
+package terraform
+
+//unmappedTypePrefix is the prefix of the types of the Terraform resources without cloudgrep type
+const unmappedTypePrefix = "terraform."
+
+//typeMapping is the cloudgrep type of a Terraform resource type
+type typeMapping struct {
+	resourceType string
+	//idAttribute is the attribute with the id used by the AWS provider of cloudgrep, the default is "id"
+	idAttribute string
+	//idPrefix is added to the id attribute to get the id of the cloudgrep provider, ex: the hosted zones ids start with /hostedzone/
+	idPrefix string
+}
+
+//typeMappings maps the Terraform AWS resource types to the cloudgrep types
+//the other resource types are named "terraform." followed by the Terraform type
+var typeMappings = map[string]typeMapping{
+	"aws_alb":                         {resourceType: "elb.LoadBalancer", idAttribute: "arn"},
+	"aws_ami":                         {resourceType: "ec2.Image"},
+	"aws_autoscaling_group":           {resourceType: "autoscaling.AutoScalingGroup"},
+	"aws_cloudfront_distribution":     {resourceType: "cloudfront.Distribution"},
+	"aws_db_cluster_snapshot":         {resourceType: "rds.DBClusterSnapshot"},
+	"aws_db_instance":                 {resourceType: "rds.DBInstance", idAttribute: "identifier"},
+	"aws_db_snapshot":                 {resourceType: "rds.DBSnapshot"},
+	"aws_ebs_snapshot":                {resourceType: "ec2.Snapshot"},
+	"aws_ebs_volume":                  {resourceType: "ec2.Volume"},
+	"aws_ec2_capacity_reservation":    {resourceType: "ec2.CapacityReservation"},
+	"aws_ec2_client_vpn_endpoint":     {resourceType: "ec2.ClientVpnEndpoint"},
+	"aws_ec2_fleet":                   {resourceType: "ec2.Fleet"},
+	"aws_eip":                         {resourceType: "ec2.Address"},
+	"aws_eks_cluster":                 {resourceType: "eks.Cluster", idAttribute: "arn"},
+	"aws_eks_node_group":              {resourceType: "eks.Nodegroup", idAttribute: "arn"},
+	"aws_elasticache_cluster":         {resourceType: "elasticache.CacheCluster", idAttribute: "arn"},
+	"aws_flow_log":                    {resourceType: "ec2.FlowLogs"},
+	"aws_iam_instance_profile":        {resourceType: "iam.InstanceProfile", idAttribute: "arn"},
+	"aws_iam_openid_connect_provider": {resourceType: "iam.OpenIDConnectProvider", idAttribute: "arn"},
+	"aws_iam_policy":                  {resourceType: "iam.Policy", idAttribute: "arn"},
+	"aws_iam_role":                    {resourceType: "iam.Role", idAttribute: "arn"},
+	"aws_iam_saml_provider":           {resourceType: "iam.SAMLProvider", idAttribute: "arn"},
+	"aws_iam_user":                    {resourceType: "iam.User", idAttribute: "arn"},
+	"aws_iam_virtual_mfa_device":      {resourceType: "iam.VirtualMFADevice", idAttribute: "arn"},
+	"aws_instance":                    {resourceType: "ec2.Instance"},
+	"aws_key_pair":                    {resourceType: "ec2.KeyPair", idAttribute: "key_pair_id"},
+	"aws_lambda_function":             {resourceType: "lambda.Function", idAttribute: "arn"},
+	"aws_launch_template":             {resourceType: "ec2.LaunchTemplate"},
+	"aws_lb":                          {resourceType: "elb.LoadBalancer", idAttribute: "arn"},
+	"aws_nat_gateway":                 {resourceType: "ec2.NatGateway"},
+	"aws_network_acl":                 {resourceType: "ec2.NetworkAcl"},
+	"aws_network_interface":           {resourceType: "ec2.NetworkInterface"},
+	"aws_rds_cluster":                 {resourceType: "rds.DBCluster"},
+	"aws_route53_health_check":        {resourceType: "route53.HealthCheck"},
+	"aws_route53_zone":                {resourceType: "route53.HostedZone", idAttribute: "zone_id", idPrefix: "/hostedzone/"},
+	"aws_route_table":                 {resourceType: "ec2.RouteTable"},
+	"aws_s3_bucket":                   {resourceType: "s3.Bucket"},
+	"aws_security_group":              {resourceType: "ec2.SecurityGroup"},
+	"aws_sns_topic":                   {resourceType: "sns.Topic", idAttribute: "arn"},
+	"aws_spot_instance_request":       {resourceType: "ec2.SpotInstanceRequest"},
+	"aws_sqs_queue":                   {resourceType: "sqs.Queue"},
+	"aws_subnet":                      {resourceType: "ec2.Subnet"},
+	"aws_vpc":                         {resourceType: "ec2.Vpc"},
+}
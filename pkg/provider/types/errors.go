@@ -0,0 +1,45 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+)
+
+//FetchError is an error of a fetch function classified by the provider
+type FetchError struct {
+	//Kind is one of the model.ErrorKind values
+	Kind string
+	//Code is the error code returned by the cloud, empty if there was no response
+	Code string
+	//Operation is the API operation which failed
+	Operation string
+	//Action is the permission required by the operation, for example the IAM action "ec2:DescribeInstances"
+	Action string
+	Err    error
+}
+
+func (e *FetchError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+//ClassifyError returns the classification of the error of a fetch function
+//the classification of the provider is used if found, otherwise the network errors are detected and the other errors are unknown
+func ClassifyError(err error) FetchError {
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		return *fetchErr
+	}
+	var netErr net.Error
+	//a context error implements net.Error, it is not a network error
+	if errors.As(err, &netErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return FetchError{Kind: model.ErrorKindNetwork, Err: err}
+	}
+	return FetchError{Kind: model.ErrorKindUnknown, Err: err}
+}
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+//FetchStats counts the API calls of a fetch function which were throttled or retried
+//the provider updates the stats found in the context of the fetch function
+type FetchStats struct {
+	throttles int64
+	retries   int64
+}
+
+type fetchStatsKey struct{}
+
+//WithFetchStats returns a context collecting the stats of a fetch function
+func WithFetchStats(ctx context.Context) (context.Context, *FetchStats) {
+	stats := &FetchStats{}
+	return context.WithValue(ctx, fetchStatsKey{}, stats), stats
+}
+
+//GetFetchStats returns the stats of the context, nil if the context doesn't collect stats
+func GetFetchStats(ctx context.Context) *FetchStats {
+	stats, _ := ctx.Value(fetchStatsKey{}).(*FetchStats)
+	return stats
+}
+
+//AddThrottle counts a throttled API call, it does nothing on a nil stats
+func (s *FetchStats) AddThrottle() {
+	if s != nil {
+		atomic.AddInt64(&s.throttles, 1)
+	}
+}
+
+//AddRetry counts a retried API call, it does nothing on a nil stats
+func (s *FetchStats) AddRetry() {
+	if s != nil {
+		atomic.AddInt64(&s.retries, 1)
+	}
+}
+
+func (s *FetchStats) Throttles() int {
+	if s == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&s.throttles))
+}
+
+func (s *FetchStats) Retries() int {
+	if s == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&s.retries))
+}
@@ -10,6 +10,8 @@
 type Provider interface {
 	//For a cloud provider this would be the account/project ID, set to empty if not relevant
 	AccountId() string
+	//For a cloud provider this would be the region/location, set to empty if not relevant
+	Region() string
 	String() string
 	FetchFunctions() map[string]FetchFunc
 }
@@ -0,0 +1,227 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+)
+
+//Schedule returns the time of the next run
+type Schedule interface {
+	//Next returns the next run time after t
+	Next(t time.Time) time.Time
+}
+
+//NewSchedule returns the refresh schedule of the datastore config, nil if no schedule is configured
+func NewSchedule(cfg config.Datastore) (Schedule, error) {
+	if cfg.RefreshInterval != 0 && cfg.RefreshSchedule != "" {
+		return nil, fmt.Errorf("refreshInterval and refreshSchedule can't be both set")
+	}
+	if cfg.RefreshInterval < 0 {
+		return nil, fmt.Errorf("invalid refreshInterval %v, it must be positive", cfg.RefreshInterval)
+	}
+	if cfg.RefreshInterval > 0 {
+		return intervalSchedule(cfg.RefreshInterval), nil
+	}
+	if cfg.RefreshSchedule != "" {
+		schedule, err := ParseCron(cfg.RefreshSchedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refreshSchedule: %w", err)
+		}
+		return schedule, nil
+	}
+	return nil, nil
+}
+
+//intervalSchedule runs at a fixed interval
+type intervalSchedule time.Duration
+
+func (i intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+//cronSchedule runs at the times matching a cron expression, each field is a set of allowed values
+type cronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	//the days of the month and of the week are combined with "or" when both are restricted,
+	//a field is restricted if some values are not allowed, ex: "*/1" is not restricted
+	anyDay     bool
+	anyWeekday bool
+}
+
+//cronField describes the allowed values of a field of a cron expression
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	//7 is also Sunday
+	{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+//ParseCron parses a standard cron expression: minute, hour, day of month, month and day of week
+//a field can be *, a value, a range (1-5), a step (*/15 or 0-30/10) or a list of them (1,15)
+//the months and the days of week can be names (jan, mon), the descriptors @hourly, @daily, @weekly, @monthly and @yearly are supported
+func ParseCron(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if descriptor, found := cronDescriptors[strings.ToLower(expr)]; found {
+		expr = descriptor
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("expected %v fields in cron expression '%v', got %v", len(cronFields), expr, len(fields))
+	}
+	values := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		var err error
+		values[i], err = cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%v': %w", expr, err)
+		}
+	}
+	//Sunday is 0 or 7
+	if values[4][7] {
+		values[4][0] = true
+	}
+	return cronSchedule{
+		minutes:    values[0],
+		hours:      values[1],
+		days:       values[2],
+		months:     values[3],
+		weekdays:   values[4],
+		anyDay:     cronFields[2].all(values[2]),
+		anyWeekday: cronFields[4].all(values[4]),
+	}, nil
+}
+
+//all returns true if all the values of the field are allowed, Sunday is only counted once
+func (f cronField) all(values map[int]bool) bool {
+	for v := f.min; v <= f.max; v++ {
+		if !values[v] && !(f.max == 7 && v == 7) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f cronField) parse(field string) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step '%v' for the %v", stepPart, f.name)
+			}
+		}
+		var start, end int
+		if rangePart == "*" {
+			start, end = f.min, f.max
+		} else {
+			startPart, endPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = f.value(startPart); err != nil {
+				return nil, err
+			}
+			end = start
+			if isRange {
+				if end, err = f.value(endPart); err != nil {
+					return nil, err
+				}
+			} else if hasStep {
+				//a step after a single value runs until the max, ex: 5/15
+				end = f.max
+			}
+			if end < start {
+				return nil, fmt.Errorf("invalid range '%v' for the %v", rangePart, f.name)
+			}
+		}
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, found := f.names[strings.ToLower(s)]; found {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value '%v' for the %v, expected %v-%v", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+//maxCronSearch bounds the search of the next time, an expression like "0 0 30 2 *" never matches
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+func (c cronSchedule) Next(t time.Time) time.Time {
+	//the next minute, the seconds are ignored
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+	for next.Before(limit) {
+		if !c.months[int(next.Month())] {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.matchDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.hours[next.Hour()] {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.minutes[next.Minute()] {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+func (c cronSchedule) matchDay(t time.Time) bool {
+	day := c.days[t.Day()]
+	weekday := c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	}
+	return day || weekday
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSchedule(t *testing.T) {
+	schedule, err := NewSchedule(config.Datastore{})
+	require.NoError(t, err)
+	require.Nil(t, schedule)
+
+	now := time.Date(2022, 6, 20, 14, 0, 0, 0, time.UTC)
+	schedule, err = NewSchedule(config.Datastore{RefreshInterval: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, now.Add(time.Hour), schedule.Next(now))
+
+	schedule, err = NewSchedule(config.Datastore{RefreshSchedule: "@daily"})
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2022, 6, 21, 0, 0, 0, 0, time.UTC), schedule.Next(now))
+
+	_, err = NewSchedule(config.Datastore{RefreshInterval: time.Hour, RefreshSchedule: "@daily"})
+	require.ErrorContains(t, err, "can't be both set")
+	_, err = NewSchedule(config.Datastore{RefreshInterval: -time.Hour})
+	require.ErrorContains(t, err, "invalid refreshInterval")
+	_, err = NewSchedule(config.Datastore{RefreshSchedule: "0 25 * * *"})
+	require.ErrorContains(t, err, "invalid refreshSchedule")
+}
+
+func TestCronNext(t *testing.T) {
+	//a Monday
+	now := time.Date(2022, 6, 20, 14, 7, 30, 0, time.UTC)
+	testCases := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2022, 6, 20, 14, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2022, 6, 20, 14, 15, 0, 0, time.UTC)},
+		{"5 * * * *", time.Date(2022, 6, 20, 15, 5, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2022, 6, 20, 18, 0, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2022, 6, 21, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2022, 6, 20, 17, 0, 0, 0, time.UTC)},
+		{"0 8,20 * * *", time.Date(2022, 6, 20, 20, 0, 0, 0, time.UTC)},
+		{"0 2 * * mon-fri", time.Date(2022, 6, 21, 2, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2022, 6, 26, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2022, 6, 26, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		//the day of month and the day of week are combined with "or"
+		{"0 0 25 * fri", time.Date(2022, 6, 24, 0, 0, 0, 0, time.UTC)},
+		{"0 0 21 * mon", time.Date(2022, 6, 21, 0, 0, 0, 0, time.UTC)},
+		//a field allowing all the values is not restricted
+		{"0 0 */1 * 1", time.Date(2022, 6, 27, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1-31 * mon", time.Date(2022, 6, 27, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 0-6", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1-7", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2022, 6, 20, 15, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2022, 6, 26, 0, 0, 0, 0, time.UTC)},
+		//never matches
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			schedule, err := ParseCron(tc.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.next, schedule.Next(now))
+		})
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	testCases := []struct {
+		expr string
+		err  string
+	}{
+		{"* * * *", "expected 5 fields"},
+		{"60 * * * *", "invalid value '60' for the minute"},
+		{"* * 0 * *", "invalid value '0' for the day of month"},
+		{"* * * foo *", "invalid value 'foo' for the month"},
+		{"*/0 * * * *", "invalid step '0'"},
+		{"10-5 * * * *", "invalid range '10-5'"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := ParseCron(tc.expr)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"go.uber.org/zap"
+)
+
+//RunFunc runs the engine, it returns when it's done fetching
+type RunFunc func(context.Context) error
+
+//Scheduler refreshes the resources on a schedule
+//a tick is skipped if a run is still fetching, including a run not started by the scheduler
+type Scheduler struct {
+	schedule Schedule
+	logger   *zap.Logger
+	ds       datastore.Datastore
+	run      RunFunc
+	//running is 1 while a scheduled run is in progress
+	running int32
+	lock    sync.Mutex
+	nextRun time.Time
+}
+
+//NewScheduler returns the scheduler of the datastore config, nil if no schedule is configured
+func NewScheduler(cfg config.Datastore, logger *zap.Logger, ds datastore.Datastore, run RunFunc) (*Scheduler, error) {
+	schedule, err := NewSchedule(cfg)
+	if err != nil || schedule == nil {
+		return nil, err
+	}
+	return &Scheduler{schedule: schedule, logger: logger, ds: ds, run: run}, nil
+}
+
+//Start runs the engine on the schedule until the context is done
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		for {
+			next := s.schedule.Next(time.Now())
+			if next.IsZero() {
+				s.logger.Sugar().Warn("the refresh schedule has no next run, stopping the scheduled refresh")
+				s.setNextRun(time.Time{})
+				return
+			}
+			s.setNextRun(next)
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				s.setNextRun(time.Time{})
+				return
+			case <-timer.C:
+			}
+			s.tick(ctx)
+		}
+	}()
+}
+
+//tick starts a run in the background, unless a run is still fetching
+func (s *Scheduler) tick(ctx context.Context) {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		s.logger.Sugar().Info("skipping the scheduled refresh, the previous one is still running")
+		return
+	}
+	status, err := s.ds.EngineStatus(ctx)
+	if err != nil {
+		s.logger.Sugar().Errorw("skipping the scheduled refresh, can't get the engine status", "error", err)
+		atomic.StoreInt32(&s.running, 0)
+		return
+	}
+	if status.Status == model.EventStatusFetching {
+		s.logger.Sugar().Info("skipping the scheduled refresh, the engine is already running")
+		atomic.StoreInt32(&s.running, 0)
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&s.running, 0)
+		s.logger.Sugar().Info("starting the scheduled refresh")
+		if err := s.run(ctx); err != nil {
+			s.logger.Sugar().Errorw("some error(s) when running the scheduled refresh", "error", err)
+		}
+	}()
+}
+
+func (s *Scheduler) setNextRun(next time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nextRun = next
+}
+
+//NextRun returns the time of the next scheduled run, zero if there is none
+func (s *Scheduler) NextRun() time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.nextRun
+}
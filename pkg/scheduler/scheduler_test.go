@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestDatastore(t *testing.T) datastore.Datastore {
+	cfg, err := config.GetDefault()
+	require.NoError(t, err)
+	cfg.Datastore.DataSourceName = "file::memory:"
+	ds, err := datastore.NewDatastore(context.Background(), cfg, zaptest.NewLogger(t))
+	require.NoError(t, err)
+	return ds
+}
+
+func TestNewScheduler(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	sched, err := NewScheduler(config.Datastore{}, logger, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, sched)
+	//no schedule, no next run
+	require.True(t, sched.NextRun().IsZero())
+
+	_, err = NewScheduler(config.Datastore{RefreshSchedule: "every day"}, logger, nil, nil)
+	require.ErrorContains(t, err, "invalid refreshSchedule")
+}
+
+func TestSchedulerTick(t *testing.T) {
+	ctx := context.Background()
+	ds := newTestDatastore(t)
+	var runs int32
+	release := make(chan struct{})
+	run := func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		if err := ds.WriteEvent(ctx, model.NewEngineEventStart()); err != nil {
+			return err
+		}
+		<-release
+		return ds.WriteEvent(ctx, model.NewEngineEventEnd(nil))
+	}
+	sched, err := NewScheduler(config.Datastore{RefreshInterval: time.Hour}, zaptest.NewLogger(t), ds, run)
+	require.NoError(t, err)
+
+	//the first tick starts a run
+	sched.tick(ctx)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 1 }, time.Second, time.Millisecond)
+	//the run is still fetching, the next tick is skipped
+	sched.tick(ctx)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+	close(release)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&sched.running) == 0 }, time.Second, time.Millisecond)
+
+	//a run not started by the scheduler is fetching
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventStart()))
+	sched.tick(ctx)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+	//the run is done, the next tick starts a run
+	require.NoError(t, ds.WriteEvent(ctx, model.NewEngineEventEnd(nil)))
+	sched.tick(ctx)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) == 2 }, time.Second, time.Millisecond)
+}
+
+func TestSchedulerStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ds := newTestDatastore(t)
+	var runs int32
+	run := func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+	sched, err := NewScheduler(config.Datastore{RefreshInterval: 20 * time.Millisecond}, zaptest.NewLogger(t), ds, run)
+	require.NoError(t, err)
+	before := time.Now()
+	sched.Start(ctx)
+	require.Eventually(t, func() bool { return !sched.NextRun().IsZero() }, time.Second, time.Millisecond)
+	assert.True(t, sched.NextRun().After(before))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 2 }, time.Second, time.Millisecond)
+
+	//the scheduler stops with the context
+	cancel()
+	require.Eventually(t, func() bool { return sched.NextRun().IsZero() }, time.Second, time.Millisecond)
+}
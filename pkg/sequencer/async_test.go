@@ -3,13 +3,18 @@
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
 	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
 	"github.com/juandiegopalomino/cloudgrep/pkg/testingutil/datastore"
 	providerutil "github.com/juandiegopalomino/cloudgrep/pkg/testingutil/provider"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 )
 
@@ -68,6 +73,23 @@ func TestAsyncRun_writeError(t *testing.T) {
 	assert.Equal(t, 0, ds.Count())
 }
 
+func TestAsyncRun_writeErrorBatch(t *testing.T) {
+	async := newAsync(t)
+	async.Flush = Flush{BatchSize: 1, Interval: time.Hour}
+	ds := &datastore.Blackhole{}
+	_, providers := makeProviders()
+
+	expectedErr := errors.New("foo")
+	ds.SetWriteErrorAt(2, expectedErr)
+
+	err := async.Run(context.Background(), ds, providers)
+	assert.ErrorIs(t, err, expectedErr)
+
+	//the resources of the other batches are still written
+	assert.Equal(t, 2, ds.Count())
+	assert.Equal(t, 2, ds.Writes())
+}
+
 func TestAsyncRun_fetchError(t *testing.T) {
 	async := newAsync(t)
 	ds := &datastore.Blackhole{}
@@ -82,6 +104,140 @@ func TestAsyncRun_fetchError(t *testing.T) {
 	assert.Equal(t, 3, ds.Count())
 }
 
+func TestAsyncRun_batches(t *testing.T) {
+	t.Parallel()
+
+	async := newAsync(t)
+	async.Flush = Flush{BatchSize: 2, Interval: time.Hour}
+	ds := &datastore.Blackhole{}
+	fakeProviders, providers := makeProviders()
+	fakeProviders[0].Foo.Count = 3
+
+	require.NoError(t, async.Run(context.Background(), ds, providers))
+	assert.Equal(t, 5, ds.Count())
+	//2 full batches, the last resource is written at the end
+	assert.Equal(t, 3, ds.Writes())
+}
+
+func TestAsyncRun_flushInterval(t *testing.T) {
+	t.Parallel()
+
+	async := newAsync(t)
+	async.Flush = Flush{BatchSize: 100, Interval: 50 * time.Millisecond}
+	ds := &datastore.Blackhole{}
+	fakeProviders, providers := makeProviders()
+	fakeProviders[0].Foo.DelayBefore = time.Second
+
+	done := make(chan error)
+	go func() {
+		done <- async.Run(context.Background(), ds, providers)
+	}()
+	//the resources of the fast provider are written before the end of the slow fetch
+	assert.Eventually(t, func() bool {
+		return ds.Count() == 2
+	}, 900*time.Millisecond, 10*time.Millisecond)
+	require.NoError(t, <-done)
+	assert.Equal(t, 3, ds.Count())
+}
+
+func TestAsyncRun_fetchTimeout(t *testing.T) {
+	t.Parallel()
+
+	async := newAsync(t)
+	async.Timeouts = config.Timeouts{Fetch: 500 * time.Millisecond}
+	ds := &datastore.Blackhole{}
+	fakeProviders, providers := makeProviders()
+	fakeProviders[0].Foo.Count = 3
+	fakeProviders[0].Foo.DelayBefore = 200 * time.Millisecond
+
+	err := async.Run(context.Background(), ds, providers)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "timeout, the fetch was stopped after 2 resource(s)")
+	//the resources fetched before the timeout are kept
+	assert.Equal(t, 4, ds.Count())
+}
+
+func TestAsyncRun_abandon(t *testing.T) {
+	defaultDelay := abandonDelay
+	abandonDelay = 100 * time.Millisecond
+	defer func() { abandonDelay = defaultDelay }()
+
+	async := newAsync(t)
+	async.Timeouts = config.Timeouts{Types: map[string]time.Duration{"stuck.Type": 100 * time.Millisecond}}
+	ds := &datastore.Blackhole{}
+	//the fetch function ignores its context
+	stuck := make(chan struct{})
+	defer close(stuck)
+	providers := []provider.Provider{&funcProvider{resourceType: "stuck.Type", fetchFunc: func(ctx context.Context, output chan<- model.Resource) error {
+		output <- model.Resource{Id: "r-1"}
+		<-stuck
+		return nil
+	}}}
+
+	err := async.Run(context.Background(), ds, providers)
+	assert.ErrorContains(t, err, "the fetch function didn't stop after 100ms")
+	assert.Equal(t, 1, ds.Count())
+}
+
+func TestFetchTimeout(t *testing.T) {
+	timeouts := config.Timeouts{
+		Fetch: time.Minute,
+		Types: map[string]time.Duration{"s3.Bucket": time.Hour, "s3.*": 10 * time.Minute, "*.Instance": 0},
+	}
+	assert.Equal(t, time.Hour, fetchTimeout(timeouts, "s3.Bucket"))
+	assert.Equal(t, 10*time.Minute, fetchTimeout(timeouts, "s3.Other"))
+	assert.Equal(t, time.Duration(0), fetchTimeout(timeouts, "ec2.Instance"))
+	assert.Equal(t, time.Minute, fetchTimeout(timeouts, "ec2.Volume"))
+
+	assert.NoError(t, ValidateTimeouts(timeouts))
+	assert.ErrorContains(t, ValidateTimeouts(config.Timeouts{Types: map[string]time.Duration{"s3.*": -1}}), "can't be negative")
+	assert.ErrorContains(t, ValidateTimeouts(config.Timeouts{Types: map[string]time.Duration{"[s3": 1}}), "invalid timeout pattern")
+}
+
+func TestNewResourceEventEnd(t *testing.T) {
+	p := &funcProvider{resourceType: "ec2.Instance"}
+	end := newResourceEventEnd(p, "ec2.Instance", nil, false)
+	assert.Equal(t, model.EventStatusSuccess, end.Status)
+	assert.Equal(t, "", end.ErrorKind)
+
+	//the classification of the provider is kept
+	denied := &types.FetchError{Kind: model.ErrorKindPermission, Code: "UnauthorizedOperation", Operation: "DescribeInstances", Action: "ec2:DescribeInstances", Err: errors.New("denied")}
+	end = newResourceEventEnd(p, "ec2.Instance", fmt.Errorf("can't fetch: %w", denied), false)
+	assert.Equal(t, model.EventStatusFailed, end.Status)
+	assert.Equal(t, "can't fetch: denied", end.Error)
+	assert.Equal(t, model.ErrorKindPermission, end.ErrorKind)
+	assert.Equal(t, "UnauthorizedOperation", end.ErrorCode)
+	assert.Equal(t, "DescribeInstances", end.ErrorOperation)
+	assert.Equal(t, "ec2:DescribeInstances", end.ErrorAction)
+
+	//the other errors are unknown
+	end = newResourceEventEnd(p, "ec2.Instance", context.DeadlineExceeded, true)
+	assert.Equal(t, model.EventStatusTimeout, end.Status)
+	assert.Equal(t, model.ErrorKindUnknown, end.ErrorKind)
+}
+
+//funcProvider is a provider with one fetch function
+type funcProvider struct {
+	resourceType string
+	fetchFunc    provider.FetchFunc
+}
+
+func (p *funcProvider) AccountId() string {
+	return "func"
+}
+
+func (p *funcProvider) Region() string {
+	return ""
+}
+
+func (p *funcProvider) String() string {
+	return "func"
+}
+
+func (p *funcProvider) FetchFunctions() map[string]provider.FetchFunc {
+	return map[string]provider.FetchFunc{p.resourceType: p.fetchFunc}
+}
+
 func makeProviders() ([]*providerutil.FakeProvider, []provider.Provider) {
 	fakeProviders := []*providerutil.FakeProvider{
 		{
@@ -0,0 +1,230 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider/types"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+//abandonDelay is how long a fetch function can run after its timeout, it is abandoned after this delay
+var abandonDelay = 10 * time.Second
+
+//fetch runs a fetch function, the start and the end of the fetch are written as resource events
+//the fetch is stopped after its timeout or at the deadline of the run if set, the resources fetched before are kept
+func fetch(ctx context.Context, logger *zap.Logger, ds datastore.Datastore, provider provider.Provider, resourceType string, fetchFunc provider.FetchFunc, timeout time.Duration, runDeadline time.Time, resourceChan chan<- model.Resource) error {
+	var fetchErrors *multierror.Error
+	start := model.NewResourceEventStart(provider.String(), resourceType)
+	start.AccountId, start.Region = provider.AccountId(), provider.Region()
+	if err := ds.WriteEvent(ctx, start); err != nil {
+		fetchErrors = multierror.Append(fetchErrors, err)
+	}
+	fetchCtx, stats := types.WithFetchStats(ctx)
+	deadline := runDeadline
+	if timeout > 0 && (deadline.IsZero() || time.Now().Add(timeout).Before(deadline)) {
+		deadline = time.Now().Add(timeout)
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		fetchCtx, cancel = context.WithDeadline(fetchCtx, deadline)
+		defer cancel()
+	}
+	count, err := countResources(ctx, fetchCtx, fetchFunc, resourceChan)
+	timedOut := err != nil && errors.Is(fetchCtx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		err = fmt.Errorf("timeout, the fetch was stopped after %v resource(s): %w", count, err)
+	}
+	if err != nil {
+		fetchErrors = multierror.Append(fetchErrors, err)
+	}
+	end := newResourceEventEnd(provider, resourceType, err, timedOut)
+	end.ResourcesCount = count
+	end.Throttles, end.Retries = stats.Throttles(), stats.Retries()
+	if err = ds.WriteEvent(ctx, end); err != nil {
+		fetchErrors = multierror.Append(fetchErrors, err)
+	}
+	if fetchErrors.ErrorOrNil() != nil {
+		logger.Sugar().Errorf("Received an error when trying to fetch resource  %v in provider %v: %v", resourceType, provider, fetchErrors)
+		return fetchErrors
+	}
+	return nil
+}
+
+//newResourceEventEnd returns the end event of a fetch, with the timeout status if the fetch timed out
+//the error of a failed fetch is classified
+func newResourceEventEnd(provider provider.Provider, resourceType string, err error, timedOut bool) model.Event {
+	end := model.NewResourceEventEnd(provider.String(), resourceType, err)
+	end.AccountId, end.Region = provider.AccountId(), provider.Region()
+	if timedOut {
+		end.Status = model.EventStatusTimeout
+	}
+	if err != nil {
+		fetchErr := types.ClassifyError(err)
+		end.ErrorKind, end.ErrorCode, end.ErrorOperation, end.ErrorAction = fetchErr.Kind, fetchErr.Code, fetchErr.Operation, fetchErr.Action
+	}
+	return end
+}
+
+//skipFetch records a fetch that didn't run as failed, or as timed out if the reason is a timeout
+func skipFetch(ctx context.Context, ds datastore.Datastore, provider provider.Provider, resourceType string, reason error) error {
+	start := model.NewResourceEventStart(provider.String(), resourceType)
+	start.AccountId, start.Region = provider.AccountId(), provider.Region()
+	if err := ds.WriteEvent(ctx, start); err != nil {
+		return err
+	}
+	return ds.WriteEvent(ctx, newResourceEventEnd(provider, resourceType, reason, errors.Is(reason, context.DeadlineExceeded)))
+}
+
+//ValidateTimeouts returns an error if a timeout is negative
+func ValidateTimeouts(timeouts config.Timeouts) error {
+	if timeouts.Run < 0 || timeouts.Fetch < 0 {
+		return fmt.Errorf("invalid timeouts %+v, the timeouts can't be negative", timeouts)
+	}
+	for resourceType, timeout := range timeouts.Types {
+		if timeout < 0 {
+			return fmt.Errorf("invalid timeout for the type %v: %v, the timeouts can't be negative", resourceType, timeout)
+		}
+		if _, err := path.Match(resourceType, ""); err != nil {
+			return fmt.Errorf("invalid timeout pattern %v: %w", resourceType, err)
+		}
+	}
+	return nil
+}
+
+//runDeadline returns the deadline of a run starting now, zero if there is no run timeout
+func runDeadline(timeouts config.Timeouts) time.Time {
+	if timeouts.Run <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeouts.Run)
+}
+
+//fetchTimeout returns the timeout of the fetch of a resource type, 0 if there is no timeout
+//the timeout of the type is used if set, then the timeout of the first matching pattern in alphabetical order, then the default fetch timeout
+func fetchTimeout(timeouts config.Timeouts, resourceType string) time.Duration {
+	if timeout, found := timeouts.Types[resourceType]; found {
+		return timeout
+	}
+	patterns := maps.Keys(timeouts.Types)
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, resourceType); match {
+			return timeouts.Types[pattern]
+		}
+	}
+	return timeouts.Fetch
+}
+
+//countResources runs a fetch function with the fetch context and returns the number of resources sent to the channel
+//the resources are sent until the context of the reader is done
+//the fetch function is abandoned if it doesn't return after its context is done
+func countResources(ctx context.Context, fetchCtx context.Context, fetchFunc provider.FetchFunc, resourceChan chan<- model.Resource) (int, error) {
+	fetchChan := make(chan model.Resource)
+	fetchDone := make(chan error, 1)
+	go func() {
+		fetchDone <- fetchFunc(fetchCtx, fetchChan)
+	}()
+	count := 0
+	var abandon <-chan time.Time
+	fetchCtxDone := fetchCtx.Done()
+	for {
+		select {
+		case resource := <-fetchChan:
+			//the resources are dropped once the context is done, the reader may have stopped
+			select {
+			case resourceChan <- resource:
+				count++
+			case <-ctx.Done():
+			}
+		case err := <-fetchDone:
+			return count, err
+		case <-fetchCtxDone:
+			fetchCtxDone = nil
+			timer := time.NewTimer(abandonDelay)
+			defer timer.Stop()
+			abandon = timer.C
+		case <-abandon:
+			return count, fmt.Errorf("the fetch function didn't stop after %v: %w", abandonDelay, fetchCtx.Err())
+		}
+	}
+}
+
+//Flush sets when the fetched resources are written to the datastore, the zero values use the defaults
+type Flush struct {
+	//BatchSize is the number of resources written at once
+	BatchSize int
+	//Interval is the maximum time a fetched resource is kept before being written
+	Interval time.Duration
+}
+
+const (
+	defaultBatchSize     = 1000
+	defaultFlushInterval = 5 * time.Second
+)
+
+func (f Flush) batchSize() int {
+	if f.BatchSize <= 0 {
+		return defaultBatchSize
+	}
+	return f.BatchSize
+}
+
+func (f Flush) interval() time.Duration {
+	if f.Interval <= 0 {
+		return defaultFlushInterval
+	}
+	return f.Interval
+}
+
+//readResourceChan reads the fetched resources until the channel is closed
+//the resources are written to the datastore in batches, when a batch is full or when the flush interval is reached
+func readResourceChan(ctx context.Context, logger *zap.Logger, flush Flush, doneCh chan<- struct{}, ds datastore.Datastore, resourceCh <-chan model.Resource, errOut *error) {
+	defer close(doneCh)
+	var errors *multierror.Error
+	batchSize := flush.batchSize()
+	resources := make([]*model.Resource, 0, batchSize)
+	write := func() {
+		if len(resources) == 0 {
+			return
+		}
+		if err := ds.WriteResources(ctx, resources); err != nil {
+			// TODO: Log the error in like the future error table in db or somehow tell the user in the UI idk figure it out
+			logger.Sugar().Errorf("Received an error when trying to write resources to data store %v", err)
+			errors = multierror.Append(errors, err)
+		}
+		resources = make([]*model.Resource, 0, batchSize)
+	}
+	ticker := time.NewTicker(flush.interval())
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			*errOut = multierror.Append(errors, ctx.Err()).ErrorOrNil()
+			return
+		case <-ticker.C:
+			write()
+		case resource, ok := <-resourceCh:
+			if !ok {
+				break loop
+			}
+			resources = append(resources, &resource)
+			if len(resources) >= batchSize {
+				write()
+			}
+		}
+	}
+	write()
+	*errOut = errors.ErrorOrNil()
+}
@@ -0,0 +1,243 @@
+package sequencer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/datastore"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	"go.uber.org/zap"
+)
+
+//PoolSequencer runs the fetch functions with a bounded number of workers
+//the accounts are served in turn, so that an account with many resource types can't delay the others
+type PoolSequencer struct {
+	Logger      *zap.Logger
+	Concurrency config.Concurrency
+	Flush       Flush
+	Timeouts    config.Timeouts
+}
+
+//NewPoolSequencer returns a sequencer running the fetches within the concurrency limits
+func NewPoolSequencer(logger *zap.Logger, cfg config.Concurrency) (PoolSequencer, error) {
+	if cfg.Workers < 0 || cfg.PerProvider < 0 || cfg.PerRegion < 0 {
+		return PoolSequencer{}, fmt.Errorf("invalid concurrency %+v, the limits can't be negative", cfg)
+	}
+	for service, limit := range cfg.PerService {
+		if limit < 0 {
+			return PoolSequencer{}, fmt.Errorf("invalid concurrency for the service %v: %v, the limits can't be negative", service, limit)
+		}
+	}
+	return PoolSequencer{Logger: logger, Concurrency: cfg}, nil
+}
+
+//poolTask is a fetch function waiting for a worker
+type poolTask struct {
+	provider     provider.Provider
+	providerIdx  int
+	resourceType string
+	fetchFunc    provider.FetchFunc
+}
+
+//service is the service of the resource type, ex: "ec2" for "ec2.Instance"
+func (t poolTask) service() string {
+	service, _, _ := strings.Cut(t.resourceType, ".")
+	return strings.ToLower(service)
+}
+
+//serviceKey identifies a service in an account and a region
+func (t poolTask) serviceKey() string {
+	return strings.Join([]string{t.provider.AccountId(), t.provider.Region(), t.service()}, "/")
+}
+
+//poolLimiter counts the running fetches, it must be used with the pool lock
+type poolLimiter struct {
+	cfg         config.Concurrency
+	running     int
+	perProvider map[int]int
+	perRegion   map[string]int
+	perService  map[string]int
+}
+
+func newPoolLimiter(cfg config.Concurrency) *poolLimiter {
+	return &poolLimiter{
+		cfg:         cfg,
+		perProvider: make(map[int]int),
+		perRegion:   make(map[string]int),
+		perService:  make(map[string]int),
+	}
+}
+
+func (l *poolLimiter) serviceLimit(service string) int {
+	if limit, found := l.cfg.PerService[service]; found {
+		return limit
+	}
+	return l.cfg.PerService["*"]
+}
+
+//allow returns true if the task can run without going over a limit
+func (l *poolLimiter) allow(t poolTask) bool {
+	under := func(count, limit int) bool {
+		return limit == 0 || count < limit
+	}
+	return under(l.running, l.cfg.Workers) &&
+		under(l.perProvider[t.providerIdx], l.cfg.PerProvider) &&
+		under(l.perRegion[t.provider.Region()], l.cfg.PerRegion) &&
+		under(l.perService[t.serviceKey()], l.serviceLimit(t.service()))
+}
+
+func (l *poolLimiter) acquire(t poolTask) {
+	l.running++
+	l.perProvider[t.providerIdx]++
+	l.perRegion[t.provider.Region()]++
+	l.perService[t.serviceKey()]++
+}
+
+func (l *poolLimiter) release(t poolTask) {
+	l.running--
+	l.perProvider[t.providerIdx]--
+	l.perRegion[t.provider.Region()]--
+	l.perService[t.serviceKey()]--
+}
+
+//accountQueues returns the tasks of each account, in the order the accounts are first seen
+func accountQueues(providers []provider.Provider) [][]poolTask {
+	var queues [][]poolTask
+	accountIdx := make(map[string]int)
+	for idx, p := range providers {
+		fetchFuncs := p.FetchFunctions()
+		resourceTypes := make([]string, 0, len(fetchFuncs))
+		for resourceType := range fetchFuncs {
+			resourceTypes = append(resourceTypes, resourceType)
+		}
+		sort.Strings(resourceTypes)
+		i, found := accountIdx[p.AccountId()]
+		if !found {
+			i = len(queues)
+			accountIdx[p.AccountId()] = i
+			queues = append(queues, nil)
+		}
+		for _, resourceType := range resourceTypes {
+			queues[i] = append(queues[i], poolTask{provider: p, providerIdx: idx, resourceType: resourceType, fetchFunc: fetchFuncs[resourceType]})
+		}
+	}
+	return queues
+}
+
+func (ps PoolSequencer) Run(ctx context.Context, ds datastore.Datastore, providers []provider.Provider) error {
+	resourceChan := make(chan model.Resource)
+	doneChan := make(chan struct{})
+
+	var errors *multierror.Error
+	var errorLock sync.Mutex
+
+	var readError error
+	go readResourceChan(ctx, ps.Logger, ps.Flush, doneChan, ds, resourceChan, &readError)
+
+	queues := accountQueues(providers)
+	limiter := newPoolLimiter(ps.Concurrency)
+	var lock sync.Mutex
+	cond := sync.NewCond(&lock)
+
+	//the fetches are not started after the deadline of the run
+	deadline := runDeadline(ps.Timeouts)
+	dispatchCtx := ctx
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		dispatchCtx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	//wake up the dispatcher when the context is canceled
+	dispatchDone := make(chan struct{})
+	go func() {
+		select {
+		case <-dispatchCtx.Done():
+			lock.Lock()
+			cond.Broadcast()
+			lock.Unlock()
+		case <-dispatchDone:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	lock.Lock()
+	next := 0
+	for len(queues) > 0 && dispatchCtx.Err() == nil {
+		task, found := nextTask(&queues, &next, limiter)
+		if !found {
+			//a task is always found when nothing runs, wait for a fetch to end
+			cond.Wait()
+			continue
+		}
+		limiter.acquire(task)
+		wg.Add(1)
+		go func(task poolTask) {
+			defer wg.Done()
+			if err := fetch(ctx, ps.Logger, ds, task.provider, task.resourceType, task.fetchFunc, fetchTimeout(ps.Timeouts, task.resourceType), deadline, resourceChan); err != nil {
+				errorLock.Lock()
+				errors = multierror.Append(errors, err)
+				errorLock.Unlock()
+			}
+			lock.Lock()
+			limiter.release(task)
+			cond.Broadcast()
+			lock.Unlock()
+		}(task)
+	}
+	lock.Unlock()
+	close(dispatchDone)
+
+	wg.Wait()
+	close(resourceChan)
+	<-doneChan
+
+	if len(queues) > 0 {
+		//the remaining fetches were not started, they are recorded as failed or timed out
+		errors = multierror.Append(errors, dispatchCtx.Err())
+		for _, queue := range queues {
+			for _, task := range queue {
+				if err := skipFetch(ctx, ds, task.provider, task.resourceType, dispatchCtx.Err()); err != nil {
+					errors = multierror.Append(errors, err)
+				}
+			}
+		}
+	}
+	if readError != nil {
+		errors = multierror.Append(errors, readError)
+	}
+
+	return errors.ErrorOrNil()
+}
+
+//nextTask removes and returns the first task allowed by the limiter, starting with the account at index next
+//the accounts without tasks left are removed and next is moved to the account after the one served
+func nextTask(queues *[][]poolTask, next *int, limiter *poolLimiter) (poolTask, bool) {
+	q := *queues
+	for n := 0; n < len(q); n++ {
+		i := (*next + n) % len(q)
+		for j, task := range q[i] {
+			if !limiter.allow(task) {
+				continue
+			}
+			q[i] = append(q[i][:j], q[i][j+1:]...)
+			*next = i + 1
+			if len(q[i]) == 0 {
+				q = append(q[:i], q[i+1:]...)
+				*next = i
+			}
+			if len(q) > 0 {
+				*next %= len(q)
+			}
+			*queues = q
+			return task, true
+		}
+	}
+	return poolTask{}, false
+}
@@ -0,0 +1,221 @@
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juandiegopalomino/cloudgrep/pkg/config"
+	"github.com/juandiegopalomino/cloudgrep/pkg/model"
+	"github.com/juandiegopalomino/cloudgrep/pkg/provider"
+	"github.com/juandiegopalomino/cloudgrep/pkg/testingutil/datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+//fetchTracker records the fetches running at the same time
+type fetchTracker struct {
+	lock    sync.Mutex
+	running map[string]int
+	max     map[string]int
+	order   []string
+}
+
+func newFetchTracker() *fetchTracker {
+	return &fetchTracker{running: make(map[string]int), max: make(map[string]int)}
+}
+
+func (t *fetchTracker) start(keys ...string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, key := range keys {
+		t.running[key]++
+		if t.running[key] > t.max[key] {
+			t.max[key] = t.running[key]
+		}
+	}
+}
+
+func (t *fetchTracker) end(keys ...string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, key := range keys {
+		t.running[key]--
+	}
+}
+
+func (t *fetchTracker) Max(key string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.max[key]
+}
+
+//poolProvider is a provider with a fetch function for each resource type, each fetch returns one resource
+type poolProvider struct {
+	account string
+	region  string
+	types   []string
+	delay   time.Duration
+	err     error
+	tracker *fetchTracker
+}
+
+func (p *poolProvider) AccountId() string {
+	return p.account
+}
+
+func (p *poolProvider) Region() string {
+	return p.region
+}
+
+func (p *poolProvider) String() string {
+	return fmt.Sprintf("%v-%v", p.account, p.region)
+}
+
+func (p *poolProvider) FetchFunctions() map[string]provider.FetchFunc {
+	funcs := make(map[string]provider.FetchFunc)
+	for _, resourceType := range p.types {
+		resourceType := resourceType
+		funcs[resourceType] = func(ctx context.Context, output chan<- model.Resource) error {
+			keys := []string{"all", "account:" + p.account, "region:" + p.region, "provider:" + p.String(), "type:" + resourceType}
+			p.tracker.lock.Lock()
+			p.tracker.order = append(p.tracker.order, p.account)
+			p.tracker.lock.Unlock()
+			p.tracker.start(keys...)
+			defer p.tracker.end(keys...)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(p.delay):
+			}
+			output <- model.Resource{Id: fmt.Sprintf("%v-%v", p, resourceType), Type: resourceType}
+			return p.err
+		}
+	}
+	return funcs
+}
+
+func newPool(t testing.TB, cfg config.Concurrency) PoolSequencer {
+	pool, err := NewPoolSequencer(zaptest.NewLogger(t), cfg)
+	require.NoError(t, err)
+	return pool
+}
+
+func TestNewPoolSequencer(t *testing.T) {
+	_, err := NewPoolSequencer(zaptest.NewLogger(t), config.Concurrency{Workers: -1})
+	assert.ErrorContains(t, err, "can't be negative")
+	_, err = NewPoolSequencer(zaptest.NewLogger(t), config.Concurrency{PerService: map[string]int{"ec2": -1}})
+	assert.ErrorContains(t, err, "service ec2")
+	_, err = NewPoolSequencer(zaptest.NewLogger(t), config.Concurrency{Workers: 1, PerService: map[string]int{"*": 2}})
+	assert.NoError(t, err)
+}
+
+func TestPoolRun_limits(t *testing.T) {
+	t.Parallel()
+
+	tracker := newFetchTracker()
+	types := []string{"ec2.Instance", "ec2.Volume", "ec2.Address", "s3.Bucket", "s3.Other", "lambda.Function", "sqs.Queue", "sns.Topic"}
+	var providers []provider.Provider
+	for _, account := range []string{"a1", "a2"} {
+		for _, region := range []string{"r1", "r2"} {
+			providers = append(providers, &poolProvider{account: account, region: region, types: types, delay: 20 * time.Millisecond, tracker: tracker})
+		}
+	}
+
+	ds := &datastore.Blackhole{}
+	pool := newPool(t, config.Concurrency{Workers: 6, PerProvider: 3, PerRegion: 4, PerService: map[string]int{"ec2": 1, "*": 2}})
+	require.NoError(t, pool.Run(context.Background(), ds, providers))
+	assert.Equal(t, 4*len(types), ds.Count())
+
+	assert.Equal(t, 6, tracker.Max("all"))
+	assert.LessOrEqual(t, tracker.Max("provider:a1-r1"), 3)
+	assert.LessOrEqual(t, tracker.Max("region:r1"), 4)
+	assert.LessOrEqual(t, tracker.Max("region:r2"), 4)
+}
+
+func TestPoolRun_perService(t *testing.T) {
+	t.Parallel()
+
+	tracker := newFetchTracker()
+	providers := []provider.Provider{
+		&poolProvider{account: "a1", region: "r1", types: []string{"ec2.Instance", "ec2.Volume", "ec2.Address", "ec2.Vpc"}, delay: 20 * time.Millisecond, tracker: tracker},
+	}
+	ds := &datastore.Blackhole{}
+	pool := newPool(t, config.Concurrency{PerService: map[string]int{"ec2": 1}})
+	require.NoError(t, pool.Run(context.Background(), ds, providers))
+	assert.Equal(t, 4, ds.Count())
+	assert.Equal(t, 1, tracker.Max("all"))
+}
+
+func TestPoolRun_fairness(t *testing.T) {
+	t.Parallel()
+
+	tracker := newFetchTracker()
+	var manyTypes []string
+	for i := 0; i < 10; i++ {
+		manyTypes = append(manyTypes, fmt.Sprintf("svc%v.Type", i))
+	}
+	providers := []provider.Provider{
+		&poolProvider{account: "big", region: "r1", types: manyTypes, tracker: tracker},
+		&poolProvider{account: "small", region: "r1", types: []string{"svc0.Type", "svc1.Type"}, tracker: tracker},
+	}
+	ds := &datastore.Blackhole{}
+	pool := newPool(t, config.Concurrency{Workers: 1})
+	require.NoError(t, pool.Run(context.Background(), ds, providers))
+	assert.Equal(t, 12, ds.Count())
+	//the accounts take turns, the small account doesn't wait for the big one
+	assert.Equal(t, []string{"big", "small", "big", "small"}, tracker.order[:4])
+}
+
+func TestPoolRun_fetchError(t *testing.T) {
+	tracker := newFetchTracker()
+	expectedErr := errors.New("foo")
+	providers := []provider.Provider{
+		&poolProvider{account: "a1", region: "r1", types: []string{"s3.Bucket"}, err: expectedErr, tracker: tracker},
+		&poolProvider{account: "a2", region: "r1", types: []string{"s3.Bucket"}, tracker: tracker},
+	}
+	ds := &datastore.Blackhole{}
+	pool := newPool(t, config.Concurrency{Workers: 1})
+	err := pool.Run(context.Background(), ds, providers)
+	assert.ErrorIs(t, err, expectedErr)
+	assert.Equal(t, 2, ds.Count())
+}
+
+func TestPoolRun_canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tracker := newFetchTracker()
+	providers := []provider.Provider{
+		&poolProvider{account: "a1", region: "r1", types: []string{"s3.Bucket", "s3.Other", "sqs.Queue"}, delay: time.Minute, tracker: tracker},
+	}
+	ds := &datastore.Blackhole{}
+	pool := newPool(t, config.Concurrency{Workers: 1})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err := pool.Run(ctx, ds, providers)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, ds.Count())
+	//the fetches not started are skipped
+	assert.Equal(t, 1, len(tracker.order))
+}
+
+func TestPoolRun_runTimeout(t *testing.T) {
+	tracker := newFetchTracker()
+	providers := []provider.Provider{
+		&poolProvider{account: "a1", region: "r1", types: []string{"s3.Bucket", "s3.Other", "sqs.Queue"}, delay: 200 * time.Millisecond, tracker: tracker},
+	}
+	ds := &datastore.Blackhole{}
+	pool := newPool(t, config.Concurrency{Workers: 1})
+	pool.Timeouts = config.Timeouts{Run: 300 * time.Millisecond}
+	err := pool.Run(context.Background(), ds, providers)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "timeout, the fetch was stopped after 0 resource(s)")
+	//the first fetch is done, the second one is stopped and the last one is not started
+	assert.Equal(t, 1, ds.Count())
+	assert.Equal(t, 2, len(tracker.order))
+}
@@ -13,7 +13,11 @@
 type Blackhole struct {
 	l          sync.Mutex
 	count      int
+	writes     int
 	writeError error
+	//writeErrorAt is the call to WriteResources returning the write error, starting at 1, all the calls fail if 0
+	writeErrorAt int
+	calls        int
 }
 
 var _ datastore.Datastore = &Blackhole{}
@@ -30,14 +34,24 @@ func (s *Blackhole) GetResources(ctx context.Context, query []byte) (model.Resou
 	return model.ResourcesResponse{}, nil
 }
 
+func (s *Blackhole) GetAllResources(ctx context.Context, query []byte) (model.Resources, error) {
+	return nil, nil
+}
+
+func (s *Blackhole) GetManagedSummary(ctx context.Context, query []byte) (model.ManagedSummary, error) {
+	return model.ManagedSummary{}, nil
+}
+
 func (s *Blackhole) WriteResources(ctx context.Context, resources model.Resources) error {
 	s.l.Lock()
 	defer s.l.Unlock()
-	if s.writeError != nil {
+	s.calls++
+	if s.writeError != nil && (s.writeErrorAt == 0 || s.writeErrorAt == s.calls) {
 		return s.writeError
 	}
 
 	s.count += len(resources)
+	s.writes++
 	return nil
 }
 
@@ -58,3 +72,15 @@ func (s *Blackhole) WriteEvent(ctx context.Context, event model.Event) error {
 func (s *Blackhole) EngineStatus(ctx context.Context) (model.Event, error) {
 	return model.Event{}, nil
 }
+
+func (s *Blackhole) WriteComplianceReport(ctx context.Context, report model.ComplianceReport) error {
+	return nil
+}
+
+func (s *Blackhole) GetComplianceReport(ctx context.Context) (*model.ComplianceReport, error) {
+	return nil, nil
+}
+
+func (s *Blackhole) GetRuns(ctx context.Context) (model.Events, error) {
+	return nil, nil
+}
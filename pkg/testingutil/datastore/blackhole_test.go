@@ -26,6 +26,7 @@ func TestBlackholeStats(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, len(resources), stats.ResourcesCount)
 	assert.Equal(t, len(resources), ds.Count())
+	assert.Equal(t, 1, ds.Writes())
 }
 
 func TestBlackholeEmptyFuncs(t *testing.T) {
@@ -80,3 +81,19 @@ func TestBlackholeWriteError(t *testing.T) {
 	err = ds.WriteResources(ctx, resources)
 	assert.NoError(t, err)
 }
+
+func TestBlackholeWriteErrorAt(t *testing.T) {
+	ctx := context.Background()
+	resources := []*model.Resource{
+		{},
+	}
+
+	ds := &Blackhole{}
+	expectedErr := errors.New("foo")
+	ds.SetWriteErrorAt(2, expectedErr)
+
+	assert.NoError(t, ds.WriteResources(ctx, resources))
+	assert.ErrorIs(t, ds.WriteResources(ctx, resources), expectedErr)
+	assert.NoError(t, ds.WriteResources(ctx, resources))
+	assert.Equal(t, 2, ds.Count())
+}
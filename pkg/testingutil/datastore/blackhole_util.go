@@ -7,9 +7,27 @@ func (s *Blackhole) Count() int {
 	return s.count
 }
 
+// Writes returns the number of successful calls to WriteResources
+func (s *Blackhole) Writes() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	return s.writes
+}
+
 func (s *Blackhole) SetWriteError(err error) {
 	s.l.Lock()
 	defer s.l.Unlock()
 
 	s.writeError = err
+	s.writeErrorAt = 0
+}
+
+// SetWriteErrorAt returns the error on the nth call to WriteResources only, starting at 1
+func (s *Blackhole) SetWriteErrorAt(n int, err error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.writeError = err
+	s.writeErrorAt = n
 }
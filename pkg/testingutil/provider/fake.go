@@ -14,6 +14,8 @@
 type FakeProvider struct {
 	// ID is used for prefixes on resource types and identifiers
 	ID string
+	// RegionID is returned by Region, the resources are not annotated with it
+	RegionID string
 
 	Foo FakeProviderResourceConfig
 	Bar FakeProviderResourceConfig
@@ -26,6 +28,10 @@ func (p *FakeProvider) AccountId() string {
 	return p.String()
 }
 
+func (p *FakeProvider) Region() string {
+	return p.RegionID
+}
+
 func (p *FakeProvider) String() string {
 	if p.ID == "" {
 		return "fake"